@@ -2,22 +2,20 @@ package bot
 
 import (
 	"fmt"
-	"math/rand"
+	"io"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
-	"time"
 
 	"github.com/bwmarrin/discordgo"
 
+	"github.com/ochaochaocha3/mapbot/pkg/command"
 	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
-	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
 )
 
-// ChannelToSquareMap はチャンネル -> マップの対応の型。
-type ChannelToSquareMap map[string]*rpgmap.SquareMap
+// ChannelToMapStore はチャンネル -> マップの格納先の対応の型。
+type ChannelToMapStore map[string]*command.MapStore
 
 // Bot はマップ管理ボットの構造体。
 type Bot struct {
@@ -25,25 +23,40 @@ type Bot struct {
 	config *Config
 	// fontCache はフォントデータの格納先。
 	fontCache *mapgen.FontCache
-	// channelToMap はチャンネル -> マップの対応。
-	channelToMap ChannelToSquareMap
-	// mux は排他制御用のミューテックス。
-	mux sync.Mutex
+	// theme はマップの描画の既定のテーマ。
+	theme *mapgen.Theme
+	// registry はボットのコマンドの登録先。
+	registry *command.Registry
+	// open は画像の取得元を開く関数。
+	open func(source string) (io.ReadCloser, error)
+	// channelToMapStore はチャンネル -> マップの格納先の対応。
+	channelToMapStore ChannelToMapStore
+	// mux は channelToMapStore の排他制御用の読み書きミューテックス。
+	mux sync.RWMutex
 }
 
 // New は新しいボットを返す。
 func New(c *Config) *Bot {
-	return &Bot{
-		config:       c,
-		channelToMap: ChannelToSquareMap{},
+	b := &Bot{
+		config:            c,
+		registry:          newRegistry(),
+		open:              openURL,
+		channelToMapStore: ChannelToMapStore{},
 	}
+
+	// 設定が無効な場合は既定のテーマを使う
+	if t, err := c.Theme.Theme(); err == nil {
+		b.theme = t
+	}
+
+	return b
 }
 
 // Start はボットを起動する。
 func (b *Bot) Start() error {
 	// フォントを読み込む
 	fc := mapgen.NewFontCache()
-	err := fc.StoreFontDataFromFile(b.config.FontPath)
+	err := fc.StoreFontFiles(b.config.FontFiles())
 	if err != nil {
 		return err
 	}
@@ -60,9 +73,6 @@ func (b *Bot) Start() error {
 		b.onMessageCreate(s, m)
 	})
 
-	// チットの色の生成に乱数を使うため、乱数のシードを設定する
-	rand.Seed(time.Now().UnixNano())
-
 	// 通信開始
 	err = dg.Open()
 	if err != nil {
@@ -80,6 +90,45 @@ func (b *Bot) Start() error {
 	return nil
 }
 
+// mapStore はチャンネル用のマップの格納先を返す。
+//
+// 格納先がまだなければ作成する。
+func (b *Bot) mapStore(channelID string) *command.MapStore {
+	b.mux.RLock()
+	s, found := b.channelToMapStore[channelID]
+	b.mux.RUnlock()
+
+	if found {
+		return s
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	// ロックを取り直す間に他のゴルーチンが作成している場合がある
+	if s, found := b.channelToMapStore[channelID]; found {
+		return s
+	}
+
+	s = command.NewMapStore()
+	b.channelToMapStore[channelID] = s
+
+	return s
+}
+
+// newEnv はチャンネル用のコマンド実行環境を返す。
+func (b *Bot) newEnv(channelID string) *command.Env {
+	// 利用者が指定した任意のURLをサーバーから取得しないように、
+	// 画像は添付ファイルとアバター画像からのみ読み込む
+	return &command.Env{
+		Store:           b.mapStore(channelID),
+		FontCache:       b.fontCache,
+		Theme:           b.theme,
+		AttachmentsOnly: true,
+		Open:            b.open,
+	}
+}
+
 // onMessageCreate は発言時の処理。
 //
 // 発言に対応するコマンドがあれば実行する。
@@ -89,23 +138,44 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
-	input := strings.TrimSpace(m.Content)
-	matches := commandRe.FindStringSubmatch(input)
-	if matches == nil {
-		return
+	b.handleMessage(s, m.Message)
+}
+
+// AVATAR_SIZE はチットの画像として取得するアバター画像の大きさ。
+const AVATAR_SIZE = "128"
+
+// avatars は、メッセージの送信者およびメンションされた利用者のアバター画像のURLを返す。
+//
+// 送信者は "me" で、メンションされた利用者はメンションの文字列で参照できる。
+func avatars(m *discordgo.Message) map[string]string {
+	result := map[string]string{}
+
+	if m.Author != nil {
+		result["me"] = m.Author.AvatarURL(AVATAR_SIZE)
 	}
 
-	commandName := matches[1]
-	cmd, ok := commandMap[commandName]
-	if !ok {
-		return
+	for _, u := range m.Mentions {
+		url := u.AvatarURL(AVATAR_SIZE)
+		result["<@"+u.ID+">"] = url
+		result["<@!"+u.ID+">"] = url
 	}
 
-	args := strings.TrimSpace(matches[2])
-	cmd.Handler(b, s, m, cmd, args)
+	return result
 }
 
-// init はパッケージを初期化する。
-func init() {
-	registerCommands()
+// handleMessage はメッセージに対応するコマンドを実行し、結果を返信する。
+func (b *Bot) handleMessage(s Session, m *discordgo.Message) {
+	env := b.newEnv(m.ChannelID)
+	for _, a := range m.Attachments {
+		env.Attachments = append(env.Attachments, a.URL)
+	}
+	env.Avatars = avatars(m)
+
+	c, res, err := b.registry.Execute(env, m.Content)
+	if err != nil {
+		// コマンドでなければ何もしない
+		return
+	}
+
+	b.reply(s, m.ChannelID, c, res)
 }
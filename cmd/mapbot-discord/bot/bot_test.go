@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// TestBot_ConcurrentCommands は、複数のチャンネルで並行してメッセージを
+// 処理してもデータ競合が起こらないことを確かめる。
+//
+// discordgo はメッセージのハンドラを別々のゴルーチンで実行するため、
+// 実際のメッセージ処理を並行して呼び出す。go test -race で実行すること。
+func TestBot_ConcurrentCommands(t *testing.T) {
+	b := newTestBot(t)
+	s := &sessionRecorder{}
+
+	const numOfWorkers = 8
+	const numOfIterations = 3
+
+	var wg sync.WaitGroup
+	for w := 0; w < numOfWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			// 2つのワーカーが同じチャンネルを操作するようにする
+			channelID := fmt.Sprintf("channel-%d", w/2)
+			inputs := []string{
+				".init! 10 x 10",
+				fmt.Sprintf(`.addc "C%d" (1, %d)`, w, w+1),
+				fmt.Sprintf(`.mvc "C%d" (2, %d)`, w, w+1),
+				".lsc",
+				".size",
+				".theme cell 24",
+				fmt.Sprintf(`.delc "C%d"`, w),
+				".clear!",
+			}
+
+			for i := 0; i < numOfIterations; i++ {
+				for _, input := range inputs {
+					b.handleMessage(s, &discordgo.Message{
+						ChannelID: channelID,
+						Content:   input,
+					})
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	if len(s.Sent) == 0 {
+		t.Fatal("no messages were sent")
+	}
+}
+
+func TestBot_MapStore_ReturnsSameStoreForSameChannel(t *testing.T) {
+	b := New(&Config{})
+
+	s1 := b.mapStore("1")
+	s2 := b.mapStore("1")
+	s3 := b.mapStore("2")
+
+	if s1 != s2 {
+		t.Fatal("different stores for the same channel")
+	}
+
+	if s1 == s3 {
+		t.Fatal("same store for different channels")
+	}
+}
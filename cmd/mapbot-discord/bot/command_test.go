@@ -0,0 +1,670 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/ochaochaocha3/mapbot/pkg/command"
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+)
+
+// testChannelID はテストで使用するチャンネルのID。
+const testChannelID = "channel"
+
+// testAttachmentURL はテストで使用する添付ファイルのURL。
+const testAttachmentURL = "https://cdn.discordapp.com/attachments/1/2/bg.png"
+
+// openTestImage は、テスト用の画像の取得元を開く。
+//
+// testAttachmentURL は 64 x 48 のPNG画像を返す。
+func openTestImage(source string) (io.ReadCloser, error) {
+	if source != testAttachmentURL {
+		return nil, fmt.Errorf("not found: %s", source)
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 48)))
+
+	return ioutil.NopCloser(&buf), nil
+}
+
+// newTestBot はテスト用のボットを返す。
+func newTestBot(t *testing.T) *Bot {
+	t.Helper()
+
+	fc := mapgen.NewFontCache()
+	err := fc.StoreFontData(goregular.TTF)
+	if err != nil {
+		t.Fatalf("failed to load font: %s", err)
+	}
+
+	b := New(&Config{
+		ImageDir:    t.TempDir(),
+		ImageFormat: IMAGE_FORMAT_PNG,
+	})
+	b.fontCache = fc
+	b.open = openTestImage
+
+	return b
+}
+
+// sendMessages はボットにメッセージを順に送信し、記録されたメッセージ操作を返す。
+func sendMessages(b *Bot, inputs ...string) *sessionRecorder {
+	s := &sessionRecorder{}
+	for _, input := range inputs {
+		b.handleMessage(s, &discordgo.Message{
+			ChannelID: testChannelID,
+			Content:   input,
+		})
+	}
+
+	return s
+}
+
+func TestBot_Commands(t *testing.T) {
+	testcases := []struct {
+		// Name はテストケースの名前。
+		Name string
+		// Setup は事前に送信するメッセージ。
+		Setup []string
+		// Input は送信するメッセージ。
+		Input string
+		// Attachments は送信するメッセージに添付するファイルのURL。
+		Attachments []string
+		// NoReply は返信しないことを期待するかどうか。
+		NoReply bool
+		// Expected は返信の内容として期待する文字列。
+		Expected string
+		// ExpectedPrefix は返信の内容の先頭として期待する文字列。
+		ExpectedPrefix string
+		// Image は画像が添付されることを期待するかどうか。
+		Image bool
+		// Animation はアニメーションGIFが添付されることを期待するかどうか。
+		Animation bool
+	}{
+		{
+			Name:     "init!",
+			Input:    ".init! 10 x 8",
+			Expected: "SquareMap (10 x 8)",
+			Image:    true,
+		},
+		{
+			Name:     "init! with invalid size",
+			Input:    ".init! 1 x 8",
+			Expected: ".init!: width must be greater than or equal to 2 (1)",
+		},
+		{
+			Name:     "init! without args",
+			Input:    ".init!",
+			Expected: "使用法: `.init! [マップ名] 幅 x 高さ`",
+		},
+		{
+			Name:     "clear!",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".clear!",
+			Expected: "マップを削除しました",
+		},
+		{
+			Name:     "clear! without map",
+			Input:    ".clear!",
+			Expected: "マップが作成されていません",
+		},
+		{
+			Name:     "init! with map name",
+			Input:    ".init! battle 20 x 15",
+			Expected: "battle: SquareMap (20 x 15)",
+			Image:    true,
+		},
+		{
+			Name:     "use",
+			Setup:    []string{".init! town 10 x 8", ".init! battle 20 x 15"},
+			Input:    ".use town",
+			Expected: "town: SquareMap (10 x 8)",
+			Image:    true,
+		},
+		{
+			Name:     "use not found",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".use town",
+			Expected: ".use: map not found: town",
+		},
+		{
+			Name:     "maps",
+			Setup:    []string{".init! 10 x 8", ".init! battle 20 x 15"},
+			Input:    ".maps",
+			Expected: "（名前なし） 10 x 8\nbattle 20 x 15（選択中）",
+		},
+		{
+			Name:     "maps without map",
+			Input:    ".maps",
+			Expected: "マップが作成されていません",
+		},
+		{
+			Name:     "size",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".size",
+			Expected: "10 x 8",
+		},
+		{
+			Name:     "size without map",
+			Input:    ".size",
+			Expected: "マップが作成されていません",
+		},
+		{
+			Name:     "lsc",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`, `.addc "B" (3, 4)`},
+			Input:    ".lsc",
+			Expected: "A (1, 2)\nB (3, 4)",
+		},
+		{
+			Name:     "lsc without chits",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".lsc",
+			Expected: "（チット未登録）",
+		},
+		{
+			Name:     "addc",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    `.addc "ゆうしゃ" (1, 2)`,
+			Expected: "ゆうしゃ (1, 2)",
+			Image:    true,
+		},
+		{
+			Name:     "addc out of range",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    `.addc "A" (11, 2)`,
+			Expected: ".addc: X is out of range: 10",
+		},
+		{
+			Name:     "addc with invalid args",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    `.addc A (1, 2)`,
+			Expected: "使用法: `.addc \"チット名\" [x個数] (x, y)[-(x2, y2)] [色]`",
+		},
+		{
+			Name:     "addc without map",
+			Input:    `.addc "A" (1, 2)`,
+			Expected: "マップが作成されていません",
+		},
+		{
+			Name:     "delc",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.delc "A"`,
+			Expected: "チット「A」を削除しました",
+			Image:    true,
+		},
+		{
+			Name:     "delc not found",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    `.delc "A"`,
+			Expected: ".delc: chit not found: A",
+		},
+		{
+			Name:     "delc with trailing args",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.delc "A" (1, 2)`,
+			Expected: "使用法: `.delc \"チット名\"`",
+		},
+		{
+			Name:     "mvc",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.mvc "A" (3, 4)`,
+			Expected: "A (3, 4)",
+			Image:    true,
+		},
+		{
+			Name:     "mvc not found",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    `.mvc "A" (3, 4)`,
+			Expected: ".mvc: chit not found: A",
+		},
+		{
+			Name:     "mvc without map",
+			Input:    `.mvc "A" (3, 4)`,
+			Expected: "マップが作成されていません",
+		},
+		{
+			Name:        "bg",
+			Setup:       []string{".init! 10 x 8"},
+			Input:       ".bg",
+			Attachments: []string{testAttachmentURL},
+			Expected:    "背景画像を設定しました（64 x 48）",
+			Image:       true,
+		},
+		{
+			Name:     "bg without attachment",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".bg",
+			Expected: "使用法: `.bg [ファイル名またはURL]`",
+		},
+		{
+			Name:        "chitimg",
+			Setup:       []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:       `.chitimg "A"`,
+			Attachments: []string{testAttachmentURL},
+			Expected:    "チット「A」の画像を設定しました",
+			Image:       true,
+		},
+		{
+			Name:     "chitimg with source argument",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.chitimg "A" ` + testAttachmentURL,
+			Expected: ".chitimg: " + command.ErrSourceNotAllowed.Error(),
+		},
+		{
+			Name:     "color",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.color "A" #f00`,
+			Expected: "チット「A」の色: #ff0000",
+			Image:    true,
+		},
+		{
+			Name:     "color not found",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    `.color "A" #f00`,
+			Expected: ".color: chit not found: A",
+		},
+		{
+			Name:           "palette",
+			Setup:          []string{".init! 10 x 8"},
+			Input:          ".palette okabe-ito",
+			ExpectedPrefix: "パレット: okabe-ito（1: #e69f00, ",
+			Image:          true,
+		},
+		{
+			Name:     "grp",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.grp "A" "味方"`,
+			Expected: "チット「A」のグループ: 味方",
+			Image:    true,
+		},
+		{
+			Name:     "mvg",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`, `.grp "A" "味方"`},
+			Input:    `.mvg "味方" (+1, +1)`,
+			Expected: "味方: A (2, 3)",
+			Image:    true,
+		},
+		{
+			Name:     "theme",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".theme dark",
+			Expected: "テーマを「dark」にしました",
+			Image:    true,
+		},
+		{
+			Name:     "theme unknown",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".theme sepia",
+			Expected: ".theme: unknown theme: sepia",
+		},
+		{
+			Name:     "view",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".view (2, 3)-(5, 6)",
+			Expected: "表示範囲: (2, 3)-(5, 6)",
+			Image:    true,
+		},
+		{
+			Name:     "view with padding out of range",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.view "A" +21`,
+			Expected: ".view: padding out of range (0-20): 21",
+		},
+		{
+			Name:     "renc",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.renc "A" "B"`,
+			Expected: "チット「A」の名前を「B」に変更しました",
+			Image:    true,
+		},
+		{
+			Name:     "dupc",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.dupc "A" "B" (5, 6)`,
+			Expected: "B (5, 6)",
+			Image:    true,
+		},
+		{
+			Name:     "dupc with same name",
+			Setup:    []string{".init! 10 x 8", `.addc "A" (1, 2)`},
+			Input:    `.dupc "A" "A"`,
+			Expected: `.dupc: chit "A" already exists`,
+		},
+		{
+			Name:     "resize",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".resize 12 x 8",
+			Expected: "SquareMap (12 x 8)",
+			Image:    true,
+		},
+		{
+			Name:     "resize without map",
+			Input:    ".resize 12 x 8",
+			Expected: "マップが作成されていません",
+		},
+		{
+			Name:     "round",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".round",
+			Expected: "ラウンド2を開始しました",
+		},
+		{
+			Name:      "replay",
+			Setup:     []string{".init! 10 x 8", `.addc "A" (1, 1)`, `.mvc "A" (3, 2)`},
+			Input:     ".replay",
+			Expected:  "ラウンド1の移動",
+			Animation: true,
+		},
+		{
+			Name:     "replay without moves",
+			Setup:    []string{".init! 10 x 8"},
+			Input:    ".replay",
+			Expected: ".replay: 再生する移動がありません",
+		},
+		{
+			Name:           "help",
+			Input:          ".help",
+			ExpectedPrefix: "`.init! [マップ名] 幅 x 高さ`\n    マップを指定された大きさで初期化し",
+		},
+		{
+			Name:    "not a command",
+			Input:   "こんにちは",
+			NoReply: true,
+		},
+		{
+			Name:    "unknown command",
+			Input:   ".unknown",
+			NoReply: true,
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			b := newTestBot(t)
+			sendMessages(b, test.Setup...)
+
+			m := &discordgo.Message{
+				ChannelID: testChannelID,
+				Content:   test.Input,
+			}
+			for _, u := range test.Attachments {
+				m.Attachments = append(m.Attachments, &discordgo.MessageAttachment{URL: u})
+			}
+
+			s := &sessionRecorder{}
+			b.handleMessage(s, m)
+
+			if test.NoReply {
+				if len(s.Sent) > 0 {
+					t.Fatalf("expected no reply, got %d", len(s.Sent))
+				}
+
+				return
+			}
+
+			if len(s.Sent) != 1 {
+				t.Fatalf("len(Sent): got %d, want %d", len(s.Sent), 1)
+			}
+
+			msg := s.Sent[0]
+
+			if msg.ChannelID != testChannelID {
+				t.Errorf("ChannelID: got %q, want %q", msg.ChannelID, testChannelID)
+			}
+
+			if test.ExpectedPrefix != "" {
+				if !strings.HasPrefix(msg.Content, test.ExpectedPrefix) {
+					t.Errorf("Content: got %q, want prefix %q", msg.Content, test.ExpectedPrefix)
+				}
+			} else if msg.Content != test.Expected {
+				t.Errorf("Content: got %q, want %q", msg.Content, test.Expected)
+			}
+
+			if test.Animation {
+				if msg.FileName != ANIMATION_FILENAME || msg.FileContentType != "image/gif" {
+					t.Errorf("File: got %q (%s)", msg.FileName, msg.FileContentType)
+				}
+
+				if _, err := gif.DecodeAll(bytes.NewReader(msg.FileData)); err != nil {
+					t.Errorf("invalid GIF: %s", err)
+				}
+
+				return
+			}
+
+			if !test.Image {
+				if msg.FileData != nil {
+					t.Error("unexpected image")
+				}
+
+				return
+			}
+
+			if msg.FileContentType != "image/png" {
+				t.Errorf("FileContentType: got %q, want %q", msg.FileContentType, "image/png")
+			}
+
+			if _, err := png.Decode(bytes.NewReader(msg.FileData)); err != nil {
+				t.Errorf("invalid PNG: %s", err)
+			}
+		})
+	}
+}
+
+func TestBot_Commands_ChannelsAreIndependent(t *testing.T) {
+	b := newTestBot(t)
+	sendMessages(b, ".init! 10 x 8")
+
+	s := &sessionRecorder{}
+	b.handleMessage(s, &discordgo.Message{
+		ChannelID: "other",
+		Content:   ".size",
+	})
+
+	if len(s.Sent) != 1 {
+		t.Fatalf("len(Sent): got %d, want %d", len(s.Sent), 1)
+	}
+
+	if s.Sent[0].Content != "マップが作成されていません" {
+		t.Fatalf("Content: got %q", s.Sent[0].Content)
+	}
+}
+
+func TestBot_Commands_ClearRemovesImageFile(t *testing.T) {
+	b := newTestBot(t)
+	sendMessages(b, ".init! 10 x 8")
+
+	filename := mapImageFilename(testChannelID, b.config.ImageDir, b.config.ImageFormat)
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatalf("image file is not saved: %s", err)
+	}
+
+	sendMessages(b, ".clear!")
+
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("image file is not removed")
+	}
+}
+
+func TestBot_Commands_SVGFormat(t *testing.T) {
+	b := newTestBot(t)
+	b.config.ImageFormat = IMAGE_FORMAT_SVG
+
+	s := sendMessages(b, ".init! 10 x 8")
+	if len(s.Sent) != 1 {
+		t.Fatalf("len(Sent): got %d, want %d", len(s.Sent), 1)
+	}
+
+	msg := s.Sent[0]
+	if msg.FileContentType != "image/svg+xml" {
+		t.Errorf("FileContentType: got %q, want %q", msg.FileContentType, "image/svg+xml")
+	}
+
+	if !strings.HasSuffix(msg.FileName, ".svg") {
+		t.Errorf("FileName: got %q", msg.FileName)
+	}
+
+	if !bytes.Contains(msg.FileData, []byte("<svg")) {
+		t.Error("invalid SVG")
+	}
+}
+
+func TestBot_Commands_BackgroundFromAttachment(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 48)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	b := newTestBot(t)
+	// テスト用のサーバーはDiscordのCDNではないため、直接取得する
+	b.open = func(source string) (io.ReadCloser, error) {
+		res, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+
+		return res.Body, nil
+	}
+	sendMessages(b, ".init! 10 x 8")
+
+	s := &sessionRecorder{}
+	b.handleMessage(s, &discordgo.Message{
+		ChannelID: testChannelID,
+		Content:   ".bg",
+		Attachments: []*discordgo.MessageAttachment{
+			{URL: server.URL + "/bg.png"},
+		},
+	})
+
+	if len(s.Sent) != 1 {
+		t.Fatalf("len(Sent): got %d, want %d", len(s.Sent), 1)
+	}
+
+	if s.Sent[0].Content != "背景画像を設定しました（64 x 48）" {
+		t.Fatalf("Content: got %q", s.Sent[0].Content)
+	}
+}
+
+func TestBot_Commands_BackgroundRejectsSourceArgument(t *testing.T) {
+	testcases := []string{
+		".bg /etc/passwd",
+		".bg http://169.254.169.254/latest/meta-data/",
+		".bg https://cdn.discordapp.com/attachments/1/2/bg.png",
+	}
+
+	for _, input := range testcases {
+		t.Run(input, func(t *testing.T) {
+			b := newTestBot(t)
+			sendMessages(b, ".init! 10 x 8")
+
+			s := sendMessages(b, input)
+			if len(s.Sent) != 1 {
+				t.Fatalf("len(Sent): got %d, want %d", len(s.Sent), 1)
+			}
+
+			expected := ".bg: " + command.ErrSourceNotAllowed.Error()
+			if s.Sent[0].Content != expected {
+				t.Fatalf("Content: got %q, want %q", s.Sent[0].Content, expected)
+			}
+		})
+	}
+}
+
+func TestOpenURL_RejectsOtherHosts(t *testing.T) {
+	testcases := []string{
+		"/etc/passwd",
+		"file:///etc/passwd",
+		"http://cdn.discordapp.com/attachments/1/2/bg.png",
+		"https://169.254.169.254/latest/meta-data/",
+		"https://localhost/bg.png",
+		"https://cdn.discordapp.com.example.com/bg.png",
+	}
+
+	for _, source := range testcases {
+		t.Run(source, func(t *testing.T) {
+			if rc, err := openURL(source); err == nil {
+				rc.Close()
+				t.Fatal("expected err")
+			}
+		})
+	}
+}
+
+func TestAvatars(t *testing.T) {
+	author := &discordgo.User{ID: "1", Avatar: "a"}
+	mentioned := &discordgo.User{ID: "2", Avatar: "b"}
+
+	a := avatars(&discordgo.Message{
+		Author:   author,
+		Mentions: []*discordgo.User{mentioned},
+	})
+
+	testcases := []struct {
+		Key      string
+		Expected string
+	}{
+		{Key: "me", Expected: author.AvatarURL(AVATAR_SIZE)},
+		{Key: "<@2>", Expected: mentioned.AvatarURL(AVATAR_SIZE)},
+		{Key: "<@!2>", Expected: mentioned.AvatarURL(AVATAR_SIZE)},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Key, func(t *testing.T) {
+			if a[test.Key] != test.Expected {
+				t.Errorf("got: %q, want: %q", a[test.Key], test.Expected)
+			}
+
+			// アバター画像はDiscordのCDNから取得できる
+			u, err := url.Parse(a[test.Key])
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if err := checkImageURL(u); err != nil {
+				t.Errorf("checkImageURL: got err: %s", err)
+			}
+		})
+	}
+}
+
+func TestBot_Commands_ReplayUploadsGIF(t *testing.T) {
+	b := newTestBot(t)
+	sendMessages(b, ".init! 10 x 8", `.addc "A" (1, 1)`, `.mvc "A" (3, 2)`)
+
+	s := sendMessages(b, ".replay")
+	if len(s.Sent) != 1 {
+		t.Fatalf("len(Sent): got %d, want %d", len(s.Sent), 1)
+	}
+
+	msg := s.Sent[0]
+	if msg.Content != "ラウンド1の移動" {
+		t.Errorf("Content: got %q", msg.Content)
+	}
+
+	if msg.FileName != ANIMATION_FILENAME || msg.FileContentType != "image/gif" {
+		t.Errorf("File: got %q (%s)", msg.FileName, msg.FileContentType)
+	}
+
+	if !bytes.HasPrefix(msg.FileData, []byte("GIF89a")) {
+		t.Error("invalid GIF")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
 )
 
 // Config はボットの設定の構造体。
@@ -12,10 +14,26 @@ type Config struct {
 	Token string
 	// ImageDir は画像を格納するディレクトリ。
 	ImageDir string
-	// FontPath はTrueTypeフォントファイルのパス。
+	// FontPath はTrueTypeフォントファイルのパス。空の場合は組み込みのフォントを使う。
 	FontPath string
+	// BoldFontPath は太字のTrueTypeフォントファイルのパス。
+	BoldFontPath string
+	// FallbackFontPaths は、フォントに含まれない文字を描画するための
+	// 代替フォントファイルのパスの配列。
+	FallbackFontPaths []string
+	// ImageFormat はアップロードする画像の形式（"png" または "svg"）。
+	ImageFormat string
+	// Theme はマップの描画のテーマの設定。
+	Theme mapgen.ThemeConfig
 }
 
+const (
+	// IMAGE_FORMAT_PNG はPNG形式を表す。
+	IMAGE_FORMAT_PNG = "png"
+	// IMAGE_FORMAT_SVG はSVG形式を表す。
+	IMAGE_FORMAT_SVG = "svg"
+)
+
 // LoadConfigFile は設定ファイルを読み込み、Config構造体を返す。
 func LoadConfigFile(filename string) (*Config, error) {
 	config := Config{}
@@ -25,13 +43,30 @@ func LoadConfigFile(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	if config.FontPath == "" {
-		return nil, fmt.Errorf("FontPath is not set")
-	}
-
 	if config.ImageDir == "" {
 		config.ImageDir = "."
 	}
 
+	if _, err := config.Theme.Theme(); err != nil {
+		return nil, err
+	}
+
+	switch config.ImageFormat {
+	case "":
+		config.ImageFormat = IMAGE_FORMAT_PNG
+	case IMAGE_FORMAT_PNG, IMAGE_FORMAT_SVG:
+	default:
+		return nil, fmt.Errorf("invalid ImageFormat: %s", config.ImageFormat)
+	}
+
 	return &config, nil
 }
+
+// FontFiles はマップの描画に使用するフォントファイルの設定を返す。
+func (c *Config) FontFiles() *mapgen.FontFiles {
+	return &mapgen.FontFiles{
+		Regular:   c.FontPath,
+		Bold:      c.BoldFontPath,
+		Fallbacks: c.FallbackFontPaths,
+	}
+}
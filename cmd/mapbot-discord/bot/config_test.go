@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigFile_ImageFormat(t *testing.T) {
+	testcases := []struct {
+		Input    string
+		expected string
+		Err      bool
+	}{
+		{Input: ``, expected: IMAGE_FORMAT_PNG},
+		{Input: `imageFormat = "png"`, expected: IMAGE_FORMAT_PNG},
+		{Input: `imageFormat = "svg"`, expected: IMAGE_FORMAT_SVG},
+		{Input: `imageFormat = "gif"`, Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "config.toml")
+			content := "fontPath = \"font.ttf\"\n" + test.Input + "\n"
+			if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := LoadConfigFile(filename)
+			if err != nil {
+				if test.Err {
+					return
+				}
+
+				t.Fatalf("got err: %s", err)
+			}
+
+			if test.Err {
+				t.Fatal("expected err")
+			}
+
+			if config.ImageFormat != test.expected {
+				t.Fatalf("got: %s, want: %s", config.ImageFormat, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_Theme(t *testing.T) {
+	testcases := []struct {
+		Input string
+		Err   bool
+	}{
+		{Input: ``},
+		{Input: "[theme]\nbase = \"dark\"\ncellSize = 48"},
+		{Input: "[theme]\nbase = \"sepia\"", Err: true},
+		{Input: "[theme]\ngridColor = \"nocolor\"", Err: true},
+		{Input: "[theme]\ncellSize = 1000", Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "config.toml")
+			content := "fontPath = \"font.ttf\"\n" + test.Input + "\n"
+			if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfigFile(filename)
+			if test.Err {
+				if err == nil {
+					t.Fatal("expected err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_FontPathIsOptional(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(filename, []byte("token = \"abc\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if config.FontFiles().Regular != "" {
+		t.Errorf("got: %s, want: empty", config.FontFiles().Regular)
+	}
+}
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// discordImageHosts は、画像の取得を許可するホスト（DiscordのCDN）。
+//
+// 添付ファイルとアバター画像はこれらのホストから配信される。
+var discordImageHosts = []string{
+	"cdn.discordapp.com",
+	"media.discordapp.net",
+}
+
+// httpClient は添付ファイルの取得に使用するHTTPクライアント。
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+	// 転送先が許可されたホストかを確かめる
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("too many redirects")
+		}
+
+		return checkImageURL(req.URL)
+	},
+}
+
+// openURL はURLで指定されたファイルを取得する。
+//
+// ボットが動作するマシン上のファイルや内部のサーバーを読ませないように、
+// DiscordのCDNの https のURLのみ受け付ける。
+func openURL(source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkImageURL(u); err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s: %s", source, res.Status)
+	}
+
+	return res.Body, nil
+}
+
+// checkImageURL は、画像を取得してよいURLかを確かめる。
+func checkImageURL(u *url.URL) error {
+	if u.Scheme != "https" {
+		return fmt.Errorf("invalid URL: %s", u)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, h := range discordImageHosts {
+		if host == h {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid URL: %s", u)
+}
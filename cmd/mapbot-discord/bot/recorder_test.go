@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sentMessage は記録されたメッセージの構造体。
+type sentMessage struct {
+	// ChannelID は送信先のチャンネルのID。
+	ChannelID string
+	// Content はメッセージの内容。
+	Content string
+	// FileName は添付ファイルの名前。
+	FileName string
+	// FileContentType は添付ファイルの種類。
+	FileContentType string
+	// FileData は添付ファイルの内容。
+	FileData []byte
+}
+
+// sessionRecorder はメッセージ操作を記録するだけの Session の実装。
+type sessionRecorder struct {
+	// Sent は送信されたメッセージ。
+	Sent []sentMessage
+	// mux は排他制御用のミューテックス。
+	mux sync.Mutex
+}
+
+var _ Session = (*sessionRecorder)(nil)
+
+// newMessage は送信されたメッセージを表す値を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (r *sessionRecorder) newMessage(channelID string, content string) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        fmt.Sprintf("%d", len(r.Sent)),
+		ChannelID: channelID,
+		Content:   content,
+	}
+}
+
+func (r *sessionRecorder) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	m := r.newMessage(channelID, content)
+	r.Sent = append(r.Sent, sentMessage{
+		ChannelID: channelID,
+		Content:   content,
+	})
+
+	return m, nil
+}
+
+func (r *sessionRecorder) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	msg := sentMessage{
+		ChannelID: channelID,
+		Content:   data.Content,
+	}
+
+	if data.File != nil {
+		b, err := ioutil.ReadAll(data.File.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.FileName = data.File.Name
+		msg.FileContentType = data.File.ContentType
+		msg.FileData = b
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	m := r.newMessage(channelID, data.Content)
+	r.Sent = append(r.Sent, msg)
+
+	return m, nil
+}
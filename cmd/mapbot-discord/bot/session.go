@@ -0,0 +1,18 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session はボットが使用するDiscordのメッセージ操作のインターフェース。
+//
+// *discordgo.Session はこのインターフェースを満たす。
+type Session interface {
+	// ChannelMessageSend はチャンネルにメッセージを送信する。
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	// ChannelMessageSendComplex はチャンネルにファイルなどを含むメッセージを送信する。
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+}
+
+// *discordgo.Session が Session を満たすことを確認する。
+var _ Session = (*discordgo.Session)(nil)
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/chzyer/readline"
 	"github.com/mattn/go-colorable"
 
 	"github.com/ochaochaocha3/mapbot/cmd/mapbot-repl/repl"
 )
 
 func main() {
+	// コマンドライン引数を解析する
+	scriptFile := flag.String("f", "", "コマンドを記述したスクリプトファイル（\"-\" で標準入力）")
+	continueOnError := flag.Bool("k", false, "スクリプトでエラーが発生しても実行を続ける")
+	flag.Parse()
+
 	// 設定ファイルを読み込む
 	configFile := "config.toml"
 	config, err := repl.LoadConfigFile(configFile)
@@ -20,7 +28,20 @@ func main() {
 
 	// WindowsでもANSIエスケープシーケンスが正しく解釈されるように
 	// colorable経由で標準出力を得る
+	// 標準出力が端末でなければ（ファイルやパイプであれば）、エスケープシーケンスを取り除く
 	out := colorable.NewColorableStdout()
+	if !readline.IsTerminal(int(os.Stdout.Fd())) {
+		out = colorable.NewNonColorable(os.Stdout)
+	}
+
+	// 標準入力が端末でなければ、パイプで渡されたスクリプトとして扱う
+	if *scriptFile == "" && !readline.IsTerminal(int(os.Stdin.Fd())) {
+		*scriptFile = "-"
+	}
+
+	if *scriptFile != "" {
+		os.Exit(runScript(*scriptFile, *continueOnError, out, config))
+	}
 
 	// REPLを作り、起動する
 	r := repl.New(os.Stdin, out, config)
@@ -30,3 +51,29 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runScript はスクリプトを実行し、終了コードを返す。
+func runScript(filename string, continueOnError bool, out io.Writer, config *repl.Config) int {
+	in := os.Stdin
+	name := "<stdin>"
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open script: %s\n", err)
+			return 1
+		}
+		defer f.Close()
+
+		in = f
+		name = filename
+	}
+
+	r := repl.New(in, out, config)
+	err := r.RunScript(name, continueOnError)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Script error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -1,16 +1,19 @@
 package repl
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
+	"image/gif"
+	"os"
+	"path/filepath"
 
-	"github.com/chzyer/readline"
 	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dsvg"
 
-	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/command"
 	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
 	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+	"github.com/ochaochaocha3/mapbot/pkg/scenario"
 )
 
 const (
@@ -30,119 +33,81 @@ const (
 	// 結果の初めに出力する文字列
 	RESULT_HEADER = ESC_CYAN + "=>" + ESC_RESET + " "
 
-	COMMAND_INIT        = "init"
-	COMMAND_PNG         = "png"
-	COMMAND_SIZE        = "size"
-	COMMAND_LIST_CHITS  = "lsc"
-	COMMAND_ADD_CHIT    = "addc"
-	COMMAND_DELETE_CHIT = "delc"
-	COMMAND_MOVE_CHIT   = "mvc"
-	COMMAND_HELP        = "help"
-	COMMAND_QUIT        = "quit"
-)
-
-// コマンドハンドラの型。
-type CommandHandler func(r *REPL, c *Command, input string)
-
-// REPLコマンドを表す構造体。
-type Command struct {
-	// コマンド名
-	Name string
-	// 引数の説明
-	ArgsDescription string
-	// 解説
-	Description string
-	// コマンドハンドラ
-	Handler CommandHandler
-	// 自動補完の候補
-	Completers []readline.PrefixCompleterInterface
-}
+	COMMAND_PNG       = "png"
+	COMMAND_SVG       = "svg"
+	COMMAND_SHOW      = "show"
+	COMMAND_AUTO_SHOW = "autoshow"
+	COMMAND_SAVE      = "save"
+	COMMAND_LOAD      = "load"
+	COMMAND_QUIT      = "quit"
 
-// Usage はコマンドの使用方法の説明を返す。
-func (c *Command) Usage() string {
-	if c.ArgsDescription == "" {
-		return c.Name
-	}
-
-	return c.Name + " " + c.ArgsDescription
-}
-
-var (
-	// commands は利用できるコマンド。
-	commands []Command
-	// commandMap はコマンド名とコマンドとの対応。
-	commandMap = map[string]*Command{}
-
-	// commandRe はコマンド実行を表す正規表現。
-	commandRe = regexp.MustCompile(`\A([-a-z]+)(?:\s+(.+))*`)
-	// tailSpacesRe は末尾の空白を表す正規表現。
-	tailSpacesRe = regexp.MustCompile(`\s+\z`)
+	// ANIMATION_FILENAME はアニメーションGIFを保存するファイル名。
+	ANIMATION_FILENAME = "replay.gif"
 )
 
-// registerCommands はREPLのコマンドを登録する。
-func registerCommands() {
-	commands = []Command{
-		{
-			Name:            COMMAND_INIT,
-			ArgsDescription: "幅 x 高さ",
-			Description:     "マップを指定された大きさで初期化します",
-			Handler:         initMap,
-		},
-		{
-			Name:        COMMAND_SIZE,
-			Description: "マップの大きさを出力します",
-			Handler:     printSize,
-		},
-		{
+// newRegistry はREPLのコマンドを登録した登録先を返す。
+func (r *REPL) newRegistry() *command.Registry {
+	reg := command.NewRegistry("")
+	reg.Register(command.MapCommands()...)
+	reg.Register(command.BackgroundCommands()...)
+	reg.Register(command.ChitImageCommands()...)
+	reg.Register(command.ChitColorCommands()...)
+	reg.Register(command.PaletteCommands()...)
+	reg.Register(command.GroupCommands()...)
+	reg.Register(command.InitiativeCommands()...)
+	reg.Register(command.ThemeCommands()...)
+	reg.Register(command.ViewCommands()...)
+	reg.Register(command.ReplayCommands()...)
+	reg.Register(
+		command.Command{
 			Name:            COMMAND_PNG,
 			ArgsDescription: "ファイル名",
 			Description:     "マップをPNGファイルに保存します",
 			Handler:         saveMapAsPng,
 		},
-		{
-			Name:        COMMAND_LIST_CHITS,
-			Description: "チットの一覧を出力します",
-			Handler:     listChits,
+		command.Command{
+			Name:            COMMAND_SVG,
+			ArgsDescription: "ファイル名",
+			Description:     "マップをSVGファイルに保存します",
+			Handler:         saveMapAsSvg,
 		},
-		{
-			Name:            COMMAND_ADD_CHIT,
-			ArgsDescription: `"チット名" (x, y)`,
-			Description:     "チットを追加します",
-			Handler:         addChit,
+		command.Command{
+			Name:            COMMAND_SHOW,
+			ArgsDescription: "[ascii]",
+			Description:     "マップを文字で表示します。ascii を指定すると色を付けません",
+			Handler:         r.showMap,
 		},
-		{
-			Name:            COMMAND_DELETE_CHIT,
-			ArgsDescription: `"チット名"`,
-			Description:     "チットを削除します",
-			Handler:         deleteChit,
+		command.Command{
+			Name:            COMMAND_AUTO_SHOW,
+			ArgsDescription: "[on|off]",
+			Description:     "マップを変更するコマンドの後に、マップを自動的に文字で表示するかを切り替えます",
+			Handler:         r.setAutoShow,
 		},
-		{
-			Name:            COMMAND_MOVE_CHIT,
-			ArgsDescription: `"チット名" (x, y)`,
-			Description:     "チットを移動します",
-			Handler:         moveChit,
+		command.Command{
+			Name:            COMMAND_SAVE,
+			ArgsDescription: "ファイル名",
+			Description:     "マップの状態をTOMLファイルに保存します",
+			Handler:         saveMap,
+		},
+		command.Command{
+			Name:            COMMAND_LOAD,
+			ArgsDescription: "ファイル名",
+			Description:     "TOMLファイルからマップの状態を読み込み、選択中のマップを置き換えます",
+			Handler:         loadMap,
 		},
-		{
-			Name:        COMMAND_HELP,
+		command.Command{
+			Name:        command.COMMAND_HELP,
 			Description: "利用できるコマンドの使用法と説明を出力します",
-			Handler:     printHelp,
+			Handler:     r.printHelp,
 		},
-		{
+		command.Command{
 			Name:        COMMAND_QUIT,
 			Description: "mapbot REPLを終了します",
-			Handler:     terminateREPL,
+			Handler:     r.terminateREPL,
 		},
-	}
-
-	for i, _ := range commands {
-		c := &commands[i]
-		commandMap[c.Name] = c
-	}
-}
+	)
 
-// printCommandUsage は、コマンドcの使用法を出力する。
-func (r *REPL) printCommandUsage(c *Command) {
-	fmt.Fprintf(r.out, "使用法: %s\n", c.Usage())
+	return reg
 }
 
 // printWelcomeMessage は起動時の歓迎メッセージを出力する。
@@ -153,55 +118,55 @@ func (r *REPL) printWelcomeMessage() {
 	fmt.Fprintln(r.out, "")
 }
 
-// printOK はコマンドの実行に成功した旨のメッセージを出力する。
-func (r *REPL) printOK() {
-	fmt.Fprintln(r.out, ESC_CYAN+"OK"+ESC_RESET)
-}
-
 // printError はエラーメッセージを強調して出力する。
 func (r *REPL) printError(err error) {
 	fmt.Fprintln(r.out, ESC_RED+err.Error()+ESC_RESET)
 }
 
-var initMapRe = regexp.MustCompile(`\A(\d+)\s*x\s*(\d+)\z`)
+// printResult はコマンドの実行結果を出力する。
+func (r *REPL) printResult(res *command.Result) {
+	if res.Err != nil {
+		var usageErr *command.UsageError
+		if errors.As(res.Err, &usageErr) {
+			fmt.Fprintf(r.out, "使用法: %s\n", r.registry.Usage(usageErr.Command))
+			return
+		}
 
-// initMap はマップを指定された大きさで初期化する。
-func initMap(r *REPL, c *Command, input string) {
-	m := initMapRe.FindStringSubmatch(input)
-	if m == nil {
-		r.printCommandUsage(c)
+		r.printError(res.Err)
 		return
 	}
 
-	width, _ := strconv.Atoi(m[1])
-	height, _ := strconv.Atoi(m[2])
-
-	newMap, err := rpgmap.NewSquareMap(width, height)
-	if err != nil {
-		r.printError(err)
+	if res.Text == "" {
+		// 出力済み、または出力するものがない
 		return
 	}
 
-	r.squareMap = newMap
+	fmt.Fprintf(r.out, "%s%s\n", RESULT_HEADER, res.Text)
 
-	r.printOK()
-}
+	if res.Animation != nil {
+		r.saveAnimation(res.Animation)
+	}
 
-// saveMapAsPng はマップの画像をPNGファイルとして保存する。
-func saveMapAsPng(r *REPL, c *Command, input string) {
-	filename := input
-	if filename == "" {
-		filename = "map.png"
+	if r.autoShow && res.Image != nil {
+		r.printMapText(res.Image.Map, true)
 	}
+}
 
-	i := mapgen.NewSquareMapImage(r.squareMap, r.fontCache)
-	dest, err := i.Render()
+// saveAnimation は、アニメーションGIFを画像のディレクトリに保存し、ファイル名を出力する。
+func (r *REPL) saveAnimation(g *gif.GIF) {
+	filename := filepath.Join(r.config.ImageDir, ANIMATION_FILENAME)
+
+	f, err := os.Create(filename)
 	if err != nil {
 		r.printError(err)
 		return
 	}
 
-	err = draw2dimg.SaveToPngFile(filename, dest)
+	err = gif.EncodeAll(f, g)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
 	if err != nil {
 		r.printError(err)
 		return
@@ -210,93 +175,152 @@ func saveMapAsPng(r *REPL, c *Command, input string) {
 	fmt.Fprintf(r.out, "%s%s\n", RESULT_HEADER, filename)
 }
 
-// printSize はマップの大きさを出力する。
-func printSize(r *REPL, _ *Command, _ string) {
-	fmt.Fprintf(r.out, "%s%s\n", RESULT_HEADER, r.squareMap.SizeStr())
+// printMapText はマップを文字で出力する。
+func (r *REPL) printMapText(m *rpgmap.SquareMap, colored bool) {
+	mText := mapgen.NewSquareMapText(m)
+	mText.Colored = colored
+
+	fmt.Fprintln(r.out, mText.Render())
 }
 
-// listChits はチットの一覧を出力する。
-func listChits(r *REPL, _ *Command, _ string) {
-	r.squareMap.ForEachChit(func(_ int, c *rpgmap.Chit) {
-		fmt.Fprintln(r.out, c)
-	})
+// showMap はマップを文字で表示する。
+func (r *REPL) showMap(env *command.Env, c *command.Command, argStr string) *command.Result {
+	colored := true
+	switch argStr {
+	case "":
+	case "ascii":
+		colored = false
+	default:
+		return &command.Result{Err: &command.UsageError{Command: c}}
+	}
+
+	sMap, found := env.Store.Map()
+	if !found {
+		return &command.Result{Err: command.ErrMapNotFound}
+	}
+
+	r.printMapText(sMap, colored)
+
+	return &command.Result{}
 }
 
-var addChitRe = regexp.MustCompile(`\A"([^"]+)"\s*\((\d+),\s*(\d+)\)\z`)
+// setAutoShow は、マップの自動表示を切り替える。
+//
+// 引数を省略した場合は、現在の設定を反転する。
+func (r *REPL) setAutoShow(_ *command.Env, c *command.Command, argStr string) *command.Result {
+	switch argStr {
+	case "":
+		r.autoShow = !r.autoShow
+	case "on":
+		r.autoShow = true
+	case "off":
+		r.autoShow = false
+	default:
+		return &command.Result{Err: &command.UsageError{Command: c}}
+	}
 
-// addChit はチットを追加する。
-func addChit(r *REPL, c *Command, input string) {
-	m := addChitRe.FindStringSubmatch(input)
-	if m == nil {
-		r.printCommandUsage(c)
-		return
+	if r.autoShow {
+		return &command.Result{Text: "マップの自動表示: オン"}
 	}
 
-	name := m[1]
-	x, _ := strconv.Atoi(m[2])
-	y, _ := strconv.Atoi(m[3])
+	return &command.Result{Text: "マップの自動表示: オフ"}
+}
+
+// saveMapAsPng はマップの画像をPNGファイルとして保存する。
+func saveMapAsPng(env *command.Env, _ *command.Command, argStr string) *command.Result {
+	filename := argStr
+	if filename == "" {
+		filename = "map.png"
+	}
 
-	chit := rpgmap.Chit{
-		Name:  name,
-		X:     x - 1,
-		Y:     y - 1,
-		Color: colorutil.RandomChitColor(),
+	sMap, found := env.Store.Map()
+	if !found {
+		return &command.Result{Err: command.ErrMapNotFound}
 	}
 
-	err := r.squareMap.AddChit(&chit)
+	i := env.NewMapImage(sMap)
+	dest, err := i.Render()
 	if err != nil {
-		r.printError(err)
-		return
+		return &command.Result{Err: err}
+	}
+
+	err = draw2dimg.SaveToPngFile(filename, dest)
+	if err != nil {
+		return &command.Result{Err: err}
 	}
 
-	fmt.Fprintf(r.out, "%s%s\n", RESULT_HEADER, chit.String())
+	return &command.Result{Text: filename}
 }
 
-var deleteChitRe = regexp.MustCompile(`\A"([^"]+)"`)
+// saveMapAsSvg はマップの画像をSVGファイルとして保存する。
+func saveMapAsSvg(env *command.Env, _ *command.Command, argStr string) *command.Result {
+	filename := argStr
+	if filename == "" {
+		filename = "map.svg"
+	}
 
-// deleteChit はチットを削除する。
-func deleteChit(r *REPL, c *Command, input string) {
-	m := deleteChitRe.FindStringSubmatch(input)
-	if m == nil {
-		r.printCommandUsage(c)
-		return
+	sMap, found := env.Store.Map()
+	if !found {
+		return &command.Result{Err: command.ErrMapNotFound}
 	}
 
-	name := m[1]
+	i := env.NewMapImage(sMap)
+	svg, err := i.RenderSVG()
+	if err != nil {
+		return &command.Result{Err: err}
+	}
 
-	err := r.squareMap.DeleteChit(name)
+	err = draw2dsvg.SaveToSvgFile(filename, svg)
 	if err != nil {
-		r.printError(err)
-		return
+		return &command.Result{Err: err}
 	}
 
-	r.printOK()
+	return &command.Result{Text: filename}
 }
 
-// moveChit はチットを移動する。
-func moveChit(r *REPL, c *Command, input string) {
-	m := addChitRe.FindStringSubmatch(input)
-	if m == nil {
-		r.printCommandUsage(c)
-		return
+// saveMap はマップの状態をファイルに保存する。
+func saveMap(env *command.Env, c *command.Command, argStr string) *command.Result {
+	filename := argStr
+	if filename == "" {
+		return &command.Result{Err: &command.UsageError{Command: c}}
 	}
 
-	name := m[1]
-	x, _ := strconv.Atoi(m[2])
-	y, _ := strconv.Atoi(m[3])
+	sMap, found := env.Store.Map()
+	if !found {
+		return &command.Result{Err: command.ErrMapNotFound}
+	}
 
-	chit, err := r.squareMap.MoveChit(name, x-1, y-1)
+	err := scenario.SaveFile(filename, sMap)
 	if err != nil {
-		r.printError(err)
-		return
+		return &command.Result{Err: err}
+	}
+
+	return &command.Result{Text: filename}
+}
+
+// loadMap はファイルからマップの状態を読み込み、選択中のマップを置き換える。
+func loadMap(env *command.Env, c *command.Command, argStr string) *command.Result {
+	filename := argStr
+	if filename == "" {
+		return &command.Result{Err: &command.UsageError{Command: c}}
+	}
+
+	newMap, err := scenario.LoadFile(filename)
+	if err != nil {
+		return &command.Result{Err: err}
 	}
 
-	fmt.Fprintf(r.out, "%s%s\n", RESULT_HEADER, chit.String())
+	name := env.Store.CurrentName()
+	env.Store.SetMap(name, newMap)
+
+	return &command.Result{
+		Text: fmt.Sprintf("%s: %s（チット%d個）", filename, newMap, newMap.NumOfChits()),
+	}
 }
 
 // printHelp は、利用できるコマンドの使用法と説明を出力する。
-func printHelp(r *REPL, _ *Command, _ string) {
-	for _, c := range commands {
+func (r *REPL) printHelp(_ *command.Env, _ *command.Command, _ string) *command.Result {
+	for _, c := range r.registry.Commands() {
 		fmt.Fprint(r.out, ESC_BOLD+c.Name+ESC_RESET)
 		if c.ArgsDescription != "" {
 			fmt.Fprint(r.out, " "+c.ArgsDescription)
@@ -305,14 +329,12 @@ func printHelp(r *REPL, _ *Command, _ string) {
 
 		fmt.Fprintln(r.out, "    "+c.Description)
 	}
+
+	return &command.Result{}
 }
 
 // terminateREPL はREPLを終了させる。
-func terminateREPL(r *REPL, _ *Command, _ string) {
+func (r *REPL) terminateREPL(_ *command.Env, _ *command.Command, _ string) *command.Result {
 	r.terminated = true
-}
-
-// init はパッケージを初期化する。
-func init() {
-	registerCommands()
+	return &command.Result{}
 }
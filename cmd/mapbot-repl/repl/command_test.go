@@ -0,0 +1,104 @@
+package repl
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestREPL はテスト用のREPLと出力先を返す。
+func newTestREPL() (*REPL, *bytes.Buffer) {
+	var out bytes.Buffer
+	r := New(strings.NewReader(""), &out, &Config{})
+
+	return r, &out
+}
+
+func TestREPL_SaveAndLoad(t *testing.T) {
+	r, out := newTestREPL()
+	filename := filepath.Join(t.TempDir(), "encounter.toml")
+
+	r.execute("init! 12 x 8")
+	r.execute(`addc "A" (1, 2)`)
+	r.execute(`addc "B" (12, 8)`)
+	r.execute("save " + filename)
+
+	r.execute("init! 5 x 5")
+	out.Reset()
+
+	r.execute("load " + filename)
+	if !strings.Contains(out.String(), "SquareMap (12 x 8)（チット2個）") {
+		t.Fatalf("load: got %q", out.String())
+	}
+
+	out.Reset()
+	r.execute("lsc")
+	if !strings.Contains(out.String(), "A (1, 2)\nB (12, 8)") {
+		t.Fatalf("lsc: got %q", out.String())
+	}
+}
+
+func TestREPL_LoadFailsWhenFileNotFound(t *testing.T) {
+	r, out := newTestREPL()
+
+	r.execute("load " + filepath.Join(t.TempDir(), "none.toml"))
+	if !strings.Contains(out.String(), ESC_RED) {
+		t.Fatalf("expected error, got %q", out.String())
+	}
+
+	out.Reset()
+	r.execute("size")
+	if !strings.Contains(out.String(), "10 x 10") {
+		t.Fatalf("map is changed: %q", out.String())
+	}
+}
+
+func TestREPL_SaveWithoutFilename(t *testing.T) {
+	r, out := newTestREPL()
+
+	r.execute("save")
+	if !strings.Contains(out.String(), "使用法: save ファイル名") {
+		t.Fatalf("got %q", out.String())
+	}
+}
+
+func TestREPL_Show(t *testing.T) {
+	r, out := newTestREPL()
+
+	r.execute("init! 3 x 2")
+	r.execute(`addc "A" (2, 1)`)
+	out.Reset()
+
+	r.execute("show ascii")
+	expected := "     1  2  3\n  1  .  A  .\n  2  .  .  .\n\nA: A (2, 1)\n"
+	if out.String() != expected {
+		t.Fatalf("got:\n%q\nwant:\n%q", out.String(), expected)
+	}
+}
+
+func TestREPL_AutoShow(t *testing.T) {
+	r, out := newTestREPL()
+
+	r.execute("init! 3 x 2")
+	r.execute(`addc "A" (2, 1)`)
+	if strings.Contains(out.String(), "  1  .") {
+		t.Fatal("map is shown before autoshow is enabled")
+	}
+
+	r.execute("autoshow on")
+	out.Reset()
+
+	r.execute(`mvc "A" (3, 2)`)
+	if !strings.Contains(out.String(), "     1  2  3\n") {
+		t.Fatalf("map is not shown: %q", out.String())
+	}
+
+	r.execute("autoshow")
+	out.Reset()
+
+	r.execute(`mvc "A" (1, 2)`)
+	if strings.Contains(out.String(), "     1  2  3\n") {
+		t.Fatalf("map is shown after autoshow is disabled: %q", out.String())
+	}
+}
@@ -1,17 +1,24 @@
 package repl
 
 import (
-	"fmt"
-
 	"github.com/BurntSushi/toml"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
 )
 
 // Config はREPLの設定の構造体。
 type Config struct {
 	// ImageDir は画像を格納するディレクトリ。
 	ImageDir string
-	// FontPath はTrueTypeフォントファイルのパス。
+	// FontPath はTrueTypeフォントファイルのパス。空の場合は組み込みのフォントを使う。
 	FontPath string
+	// BoldFontPath は太字のTrueTypeフォントファイルのパス。
+	BoldFontPath string
+	// FallbackFontPaths は、フォントに含まれない文字を描画するための
+	// 代替フォントファイルのパスの配列。
+	FallbackFontPaths []string
+	// Theme はマップの描画のテーマの設定。
+	Theme mapgen.ThemeConfig
 }
 
 // LoadConfigFile は設定ファイルを読み込み、Config構造体を返す。
@@ -23,13 +30,22 @@ func LoadConfigFile(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	if config.FontPath == "" {
-		return nil, fmt.Errorf("FontPath is not set")
-	}
-
 	if config.ImageDir == "" {
 		config.ImageDir = "."
 	}
 
+	if _, err := config.Theme.Theme(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// FontFiles はマップの描画に使用するフォントファイルの設定を返す。
+func (c *Config) FontFiles() *mapgen.FontFiles {
+	return &mapgen.FontFiles{
+		Regular:   c.FontPath,
+		Bold:      c.BoldFontPath,
+		Fallbacks: c.FallbackFontPaths,
+	}
+}
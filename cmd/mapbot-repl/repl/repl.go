@@ -1,14 +1,13 @@
 package repl
 
 import (
-	"fmt"
 	"io"
-	"math/rand"
+	"os"
 	"strings"
-	"time"
 
 	"github.com/chzyer/readline"
 
+	"github.com/ochaochaocha3/mapbot/pkg/command"
 	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
 	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
 )
@@ -21,6 +20,8 @@ type REPL struct {
 	out io.Writer
 	// terminated はREPLを終了するかを表す。
 	terminated bool
+	// registry はREPLのコマンドの登録先。
+	registry *command.Registry
 	// completer は自動補完機能。
 	completer *readline.PrefixCompleter
 
@@ -28,8 +29,12 @@ type REPL struct {
 	config *Config
 	// fontCache はフォントデータの格納先。
 	fontCache *mapgen.FontCache
-	// squareMap はREPLセッション中に使用するスクエアマップ。
-	squareMap *rpgmap.SquareMap
+	// theme はマップの描画の既定のテーマ。
+	theme *mapgen.Theme
+	// autoShow は、マップを変更するコマンドの後にマップを文字で表示するかどうか。
+	autoShow bool
+	// mapStore はREPLセッション中に使用するスクエアマップの格納先。
+	mapStore *command.MapStore
 }
 
 // New は新しいREPLを構築し、返す。
@@ -37,21 +42,34 @@ type REPL struct {
 // REPLは、inから入力された文字列をコマンドとして実行し、
 // outにその結果を出力する。
 func New(in io.Reader, out io.Writer, config *Config) *REPL {
-	completers := make([]readline.PrefixCompleterInterface, 0, len(commands))
-	for _, c := range commands {
-		completers = append(completers, readline.PcItem(c.Name, c.Completers...))
-	}
-
 	m, _ := rpgmap.NewSquareMap(10, 10)
+	s := command.NewMapStore()
+	s.SetMap(command.DEFAULT_MAP_NAME, m)
 
-	return &REPL{
+	r := &REPL{
 		in:         in,
 		out:        out,
 		terminated: false,
-		completer:  readline.NewPrefixCompleter(completers...),
 		config:     config,
-		squareMap:  m,
+		mapStore:   s,
+	}
+
+	// 設定が無効な場合は既定のテーマを使う
+	if t, err := config.Theme.Theme(); err == nil {
+		r.theme = t
+	}
+
+	r.registry = r.newRegistry()
+
+	commands := r.registry.Commands()
+	completers := make([]readline.PrefixCompleterInterface, 0, len(commands))
+	for _, c := range commands {
+		completers = append(completers, readline.PcItem(c.Name))
 	}
+
+	r.completer = readline.NewPrefixCompleter(completers...)
+
+	return r
 }
 
 // filterInput はreadlineでブロックする文字かどうかを判定する
@@ -65,17 +83,27 @@ func filterInput(r rune) (rune, bool) {
 	return r, true
 }
 
-// Start はREPLを開始する。
-func (r *REPL) Start() error {
+// prepare はコマンドを実行する準備をする。
+func (r *REPL) prepare() error {
 	// フォントを読み込む
 	fc := mapgen.NewFontCache()
-	err := fc.StoreFontDataFromFile(r.config.FontPath)
+	err := fc.StoreFontFiles(r.config.FontFiles())
 	if err != nil {
 		return err
 	}
 
 	r.fontCache = fc
 
+	return nil
+}
+
+// Start はREPLを開始する。
+func (r *REPL) Start() error {
+	err := r.prepare()
+	if err != nil {
+		return err
+	}
+
 	// 自動補完機能を用意する
 	l, err := readline.NewEx(&readline.Config{
 		Prompt:              PROMPT,
@@ -90,9 +118,6 @@ func (r *REPL) Start() error {
 	}
 	defer l.Close()
 
-	// チットの色の生成に乱数を使うため、乱数のシードを設定する
-	rand.Seed(time.Now().UnixNano())
-
 	// 動作開始
 	r.printWelcomeMessage()
 
@@ -116,27 +141,39 @@ func (r *REPL) Start() error {
 			break
 		}
 
-		matches := commandRe.FindStringSubmatch(line)
-		if matches == nil {
-			r.printError(fmt.Errorf("無効なコマンドです: %s", line))
-			continue
-		}
+		r.execute(line)
+	}
 
-		commandName := matches[1]
-		cmd, ok := commandMap[commandName]
-		if !ok {
-			r.printError(fmt.Errorf("無効なコマンドです: %s", commandName))
-			continue
+	return nil
+}
+
+// openFile はファイルを開く。
+func openFile(filename string) (io.ReadCloser, error) {
+	return os.Open(filename)
+}
+
+// execute は1行の入力をコマンドとして実行し、結果を出力する。
+//
+// コマンドの実行に失敗した場合は、そのエラーを返す。
+func (r *REPL) execute(line string) error {
+	env := &command.Env{
+		Store:     r.mapStore,
+		FontCache: r.fontCache,
+		Theme:     r.theme,
+		Open:      openFile,
+	}
+
+	_, res, err := r.registry.Execute(env, line)
+	if err != nil {
+		if err == command.ErrNotCommand {
+			err = &command.UnknownCommandError{Name: line}
 		}
 
-		args := tailSpacesRe.ReplaceAllString(matches[2], "")
-		cmd.Handler(r, cmd, args)
+		r.printError(err)
+		return err
 	}
 
-	return nil
-}
+	r.printResult(res)
 
-// init はパッケージを初期化する。
-func init() {
-	registerCommands()
+	return res.Err
 }
@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+const (
+	// COMMENT_PREFIX はスクリプトのコメント行の先頭の文字列。
+	COMMENT_PREFIX = "#"
+	// SCRIPT_ECHO_PREFIX は、実行するスクリプトの行を出力する際に付ける文字列。
+	//
+	// 出力先がファイルやパイプの場合があるため、エスケープシーケンスを含めない。
+	SCRIPT_ECHO_PREFIX = ">> "
+)
+
+// ScriptError はスクリプトの実行中に発生したエラーの構造体。
+type ScriptError struct {
+	// Name はスクリプトの名前。
+	Name string
+	// Line はエラーが発生した行番号。
+	Line int
+	// Err は発生したエラー。
+	Err error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Err)
+}
+
+// ScriptErrors は、エラー発生後も実行を続けた場合に発生したエラーの配列。
+type ScriptErrors []*ScriptError
+
+func (es ScriptErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+
+	return fmt.Sprintf("%d個のコマンドでエラーが発生しました\n%s", len(es), strings.Join(msgs, "\n"))
+}
+
+// RunScript は入力源から1行ずつコマンドを読み込み、非対話的に実行する。
+//
+// name はエラーメッセージに含めるスクリプトの名前。
+// 空行および "#" で始まる行は無視する。
+// continueOnError が false の場合、最初のエラーで実行を中止し、
+// そのエラーを *ScriptError として返す。
+// true の場合は最後まで実行し、発生したエラーを ScriptErrors として返す。
+func (r *REPL) RunScript(name string, continueOnError bool) error {
+	err := r.prepare()
+	if err != nil {
+		return err
+	}
+
+	return r.runScript(name, continueOnError)
+}
+
+// runScript はスクリプトを実行する。
+func (r *REPL) runScript(name string, continueOnError bool) error {
+	scriptErrs := ScriptErrors{}
+
+	scanner := bufio.NewScanner(r.in)
+	lineNo := 0
+	for !r.terminated && scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, COMMENT_PREFIX) {
+			continue
+		}
+
+		// REPL終了の "q" のみ特別扱い
+		if line == "q" {
+			break
+		}
+
+		fmt.Fprintln(r.out, SCRIPT_ECHO_PREFIX+line)
+
+		err := r.execute(line)
+		if err == nil {
+			continue
+		}
+
+		scriptErr := &ScriptError{Name: name, Line: lineNo, Err: err}
+		if !continueOnError {
+			return scriptErr
+		}
+
+		scriptErrs = append(scriptErrs, scriptErr)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(scriptErrs) > 0 {
+		return scriptErrs
+	}
+
+	return nil
+}
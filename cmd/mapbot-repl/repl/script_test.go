@@ -0,0 +1,124 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// newScriptREPL はスクリプトを入力源とするテスト用のREPLと出力先を返す。
+func newScriptREPL(script string) (*REPL, *bytes.Buffer) {
+	var out bytes.Buffer
+	r := New(strings.NewReader(script), &out, &Config{})
+
+	return r, &out
+}
+
+func TestREPL_RunScript(t *testing.T) {
+	script := `# 遭遇マップ
+init! 12 x 8
+
+addc "A" (1, 2)
+  # 字下げしたコメント
+mvc "A" (3, 4)
+`
+
+	r, out := newScriptREPL(script)
+	err := r.runScript("test", false)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	sMap, _ := r.mapStore.Map()
+	c, found := sMap.FindChit("A")
+	if !found {
+		t.Fatal("chit not found")
+	}
+
+	if c.CoordStr() != "(3, 4)" {
+		t.Fatalf("coord: got %s, want %s", c.CoordStr(), "(3, 4)")
+	}
+
+	if strings.Contains(out.String(), "遭遇マップ") {
+		t.Fatal("comment is executed")
+	}
+
+	if !strings.Contains(out.String(), SCRIPT_ECHO_PREFIX+`mvc "A" (3, 4)`+"\n") {
+		t.Errorf("line is not echoed: %q", out.String())
+	}
+
+	if strings.Contains(out.String(), PROMPT) {
+		t.Errorf("echo contains escape sequences: %q", out.String())
+	}
+}
+
+func TestREPL_RunScript_StopsOnError(t *testing.T) {
+	script := `init! 12 x 8
+mvc "B" (3, 4)
+addc "A" (1, 2)
+`
+
+	r, _ := newScriptREPL(script)
+	err := r.runScript("test", false)
+
+	scriptErr, ok := err.(*ScriptError)
+	if !ok {
+		t.Fatalf("got err: %v, want *ScriptError", err)
+	}
+
+	if scriptErr.Line != 2 {
+		t.Fatalf("Line: got %d, want %d", scriptErr.Line, 2)
+	}
+
+	sMap, _ := r.mapStore.Map()
+	if sMap.NumOfChits() != 0 {
+		t.Fatal("commands after error are executed")
+	}
+}
+
+func TestREPL_RunScript_ContinuesOnError(t *testing.T) {
+	script := `init! 12 x 8
+mvc "B" (3, 4)
+unknown
+addc "A" (1, 2)
+`
+
+	r, _ := newScriptREPL(script)
+	err := r.runScript("test", true)
+
+	scriptErrs, ok := err.(ScriptErrors)
+	if !ok {
+		t.Fatalf("got err: %v, want ScriptErrors", err)
+	}
+
+	if len(scriptErrs) != 2 {
+		t.Fatalf("len: got %d, want %d", len(scriptErrs), 2)
+	}
+
+	if scriptErrs[1].Line != 3 {
+		t.Fatalf("Line: got %d, want %d", scriptErrs[1].Line, 3)
+	}
+
+	sMap, _ := r.mapStore.Map()
+	if sMap.NumOfChits() != 1 {
+		t.Fatal("commands after error are not executed")
+	}
+}
+
+func TestREPL_RunScript_Quit(t *testing.T) {
+	script := `init! 12 x 8
+quit
+addc "A" (1, 2)
+`
+
+	r, _ := newScriptREPL(script)
+	err := r.runScript("test", false)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	sMap, _ := r.mapStore.Map()
+	if sMap.NumOfChits() != 0 {
+		t.Fatal("commands after quit are executed")
+	}
+}
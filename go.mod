@@ -10,4 +10,6 @@ require (
 	github.com/jyotiska/go-webcolors v0.0.0-20150821045656-d3232ed69418
 	github.com/llgcode/draw2d v0.0.0-20200110163050-b96d8208fcfc
 	github.com/mattn/go-colorable v0.1.6
+	golang.org/x/image v0.0.0-20180708004352-c73c2afc3b81
+	golang.org/x/text v0.3.6
 )
@@ -0,0 +1,66 @@
+package colorutil
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// ChitColorAllocator はチットの色を割り当てる構造体。
+//
+// パレットの中から、使用中のチットが最も少ない色を選ぶ。
+// 候補が複数ある場合は乱数で選ぶため、同じシードからは同じ順で色が割り当てられる。
+// 並行して使用する場合は、呼び出し側で排他制御を行うこと。
+type ChitColorAllocator struct {
+	// palette は割り当てる色の一覧。
+	palette []color.RGBA
+	// rnd は候補の色を選ぶための乱数生成器。
+	rnd *rand.Rand
+}
+
+// NewChitColorAllocator は、ChitColors から色を割り当てる新しい割り当て器を返す。
+func NewChitColorAllocator(seed int64) *ChitColorAllocator {
+	return &ChitColorAllocator{
+		palette: ChitColors,
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Palette は割り当てる色の一覧を返す。
+func (a *ChitColorAllocator) Palette() []color.RGBA {
+	return append([]color.RGBA{}, a.palette...)
+}
+
+// SetPalette は割り当てる色の一覧を設定する。
+func (a *ChitColorAllocator) SetPalette(palette []color.RGBA) {
+	a.palette = append([]color.RGBA{}, palette...)
+}
+
+// Seed は乱数のシードを設定する。
+func (a *ChitColorAllocator) Seed(seed int64) {
+	a.rnd.Seed(seed)
+}
+
+// Allocate は、使用中の色 used に含まれる数が最も少ないパレットの色を返す。
+//
+// 未使用の色があればその中から選び、すべて使用中であれば最も使われていない色から選ぶ。
+func (a *ChitColorAllocator) Allocate(used []color.RGBA) color.RGBA {
+	counts := map[color.RGBA]int{}
+	for _, c := range used {
+		counts[c]++
+	}
+
+	candidates := []color.RGBA{}
+	minCount := -1
+	for _, c := range a.palette {
+		n := counts[c]
+		switch {
+		case minCount < 0 || n < minCount:
+			candidates = []color.RGBA{c}
+			minCount = n
+		case n == minCount:
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates[a.rnd.Intn(len(candidates))]
+}
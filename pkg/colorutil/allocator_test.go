@@ -0,0 +1,48 @@
+package colorutil
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestChitColorAllocator_Allocate(t *testing.T) {
+	a := NewChitColorAllocator(1)
+
+	// すべての色が使われるまでは重複しない
+	used := []color.RGBA{}
+	for k := 0; k < len(ChitColors); k++ {
+		c := a.Allocate(used)
+		for _, u := range used {
+			if c == u {
+				t.Fatalf("color %s is allocated twice", RGBAToHex(c))
+			}
+		}
+
+		used = append(used, c)
+	}
+
+	// すべて使われたら、最も使われていない色を選ぶ
+	used = append(used, ChitColors[1:]...)
+	if c := a.Allocate(used); c != ChitColors[0] {
+		t.Errorf("got: %s, want: %s", RGBAToHex(c), RGBAToHex(ChitColors[0]))
+	}
+}
+
+func TestChitColorAllocator_Seed(t *testing.T) {
+	a := NewChitColorAllocator(42)
+	b := NewChitColorAllocator(0)
+	b.Seed(42)
+
+	usedA := []color.RGBA{}
+	usedB := []color.RGBA{}
+	for k := 0; k < 2*len(ChitColors); k++ {
+		ca := a.Allocate(usedA)
+		cb := b.Allocate(usedB)
+		if ca != cb {
+			t.Fatalf("%d: got: %s, want: %s", k, RGBAToHex(cb), RGBAToHex(ca))
+		}
+
+		usedA = append(usedA, ca)
+		usedB = append(usedB, cb)
+	}
+}
@@ -3,7 +3,6 @@ package colorutil
 
 import (
 	"image/color"
-	"math/rand"
 
 	"github.com/jyotiska/go-webcolors"
 )
@@ -32,8 +31,3 @@ func RGBToRGBA(rgb []int) color.RGBA {
 func CSS3NameToRGBA(name string) color.RGBA {
 	return RGBToRGBA(webcolors.NameToRGB(name, "css3"))
 }
-
-func RandomChitColor() color.RGBA {
-	i := rand.Intn(len(ChitColors))
-	return ChitColors[i]
-}
@@ -0,0 +1,39 @@
+package colorutil
+
+import (
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+)
+
+// hexColorRe は #RRGGBB または #RGB 形式の色を表す正規表現。
+var hexColorRe = regexp.MustCompile(`\A#([0-9A-Fa-f]{6}|[0-9A-Fa-f]{3})\z`)
+
+// RGBAToHex は色を #rrggbb 形式の文字列に変換する。
+func RGBAToHex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// HexToRGBA は #RRGGBB または #RGB 形式の文字列を色に変換する。
+//
+// #RGB 形式の各桁は、CSSと同様に2桁に展開する（#1af -> #11aaff）。
+func HexToRGBA(s string) (color.RGBA, error) {
+	matches := hexColorRe.FindStringSubmatch(s)
+	if matches == nil {
+		return color.RGBA{}, fmt.Errorf("invalid hex color: %s", s)
+	}
+
+	digits := matches[1]
+	if len(digits) == 3 {
+		digits = string([]byte{
+			digits[0], digits[0],
+			digits[1], digits[1],
+			digits[2], digits[2],
+		})
+	}
+
+	v, _ := strconv.ParseUint(digits, 16, 32)
+
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}, nil
+}
@@ -0,0 +1,61 @@
+package colorutil
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRGBAToHex(t *testing.T) {
+	testcases := []struct {
+		Color    color.RGBA
+		expected string
+	}{
+		{Color: color.RGBA{0x1E, 0x90, 0xFF, 0xFF}, expected: "#1e90ff"},
+		{Color: color.RGBA{0x00, 0x00, 0x00, 0xFF}, expected: "#000000"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.expected, func(t *testing.T) {
+			actual := RGBAToHex(test.Color)
+			if actual != test.expected {
+				t.Fatalf("got: %s, want: %s", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestHexToRGBA(t *testing.T) {
+	testcases := []struct {
+		Input    string
+		expected color.RGBA
+		Err      bool
+	}{
+		{Input: "#1e90ff", expected: color.RGBA{0x1E, 0x90, 0xFF, 0xFF}},
+		{Input: "#1E90FF", expected: color.RGBA{0x1E, 0x90, 0xFF, 0xFF}},
+		{Input: "#1af", expected: color.RGBA{0x11, 0xAA, 0xFF, 0xFF}},
+		{Input: "1e90ff", Err: true},
+		{Input: "#1e90f", Err: true},
+		{Input: "#1e90fg", Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			actual, err := HexToRGBA(test.Input)
+			if err != nil {
+				if test.Err {
+					return
+				}
+
+				t.Fatalf("got err: %s", err)
+			}
+
+			if test.Err {
+				t.Fatal("expected err")
+			}
+
+			if actual != test.expected {
+				t.Fatalf("got: %v, want: %v", actual, test.expected)
+			}
+		})
+	}
+}
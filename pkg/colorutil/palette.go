@@ -0,0 +1,77 @@
+package colorutil
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// チットの色のパレットの名前。
+const (
+	// PALETTE_DEFAULT は既定のパレット（ChitColors）。
+	PALETTE_DEFAULT = "default"
+	// PALETTE_OKABE_ITO は、色覚の多様性に配慮した岡部・伊藤のパレット。
+	PALETTE_OKABE_ITO = "okabe-ito"
+	// PALETTE_TOL は、色覚の多様性に配慮したPaul Tolのパレット（bright）。
+	PALETTE_TOL = "tol"
+	// PALETTE_HIGH_CONTRAST は、明るさの差が大きい色からなるパレット。
+	PALETTE_HIGH_CONTRAST = "high-contrast"
+)
+
+// PaletteNames はパレットの名前の一覧。
+var PaletteNames = []string{
+	PALETTE_DEFAULT,
+	PALETTE_OKABE_ITO,
+	PALETTE_TOL,
+	PALETTE_HIGH_CONTRAST,
+}
+
+// paletteHexColors はパレットの名前 -> #RRGGBB 形式の色の配列の対応。
+var paletteHexColors = map[string][]string{
+	PALETTE_OKABE_ITO: {
+		"#e69f00", // orange
+		"#56b4e9", // sky blue
+		"#009e73", // bluish green
+		"#f0e442", // yellow
+		"#0072b2", // blue
+		"#d55e00", // vermillion
+		"#cc79a7", // reddish purple
+		"#000000", // black
+	},
+	PALETTE_TOL: {
+		"#4477aa", // blue
+		"#ee6677", // red
+		"#228833", // green
+		"#ccbb44", // yellow
+		"#66ccee", // cyan
+		"#aa3377", // purple
+		"#bbbbbb", // grey
+	},
+	PALETTE_HIGH_CONTRAST: {
+		"#004488", // blue
+		"#ddaa33", // yellow
+		"#bb5566", // red
+		"#000000", // black
+	},
+}
+
+// Palette は名前に対応するパレットの色の配列を返す。
+//
+// 返り値は複製であり、変更してもパレットには反映されない。
+func Palette(name string) ([]color.RGBA, error) {
+	if name == PALETTE_DEFAULT {
+		return append([]color.RGBA{}, ChitColors...), nil
+	}
+
+	hexColors, found := paletteHexColors[name]
+	if !found {
+		return nil, fmt.Errorf("unknown palette: %s", name)
+	}
+
+	colors := make([]color.RGBA, 0, len(hexColors))
+	for _, h := range hexColors {
+		c, _ := HexToRGBA(h)
+		colors = append(colors, c)
+	}
+
+	return colors, nil
+}
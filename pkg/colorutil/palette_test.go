@@ -0,0 +1,76 @@
+package colorutil
+
+import (
+	"testing"
+)
+
+func TestPalette(t *testing.T) {
+	testcases := []struct {
+		Name        string
+		ExpectedLen int
+		Err         bool
+	}{
+		{Name: PALETTE_DEFAULT, ExpectedLen: len(ChitColors)},
+		{Name: PALETTE_OKABE_ITO, ExpectedLen: 8},
+		{Name: PALETTE_TOL, ExpectedLen: 7},
+		{Name: PALETTE_HIGH_CONTRAST, ExpectedLen: 4},
+		{Name: "rainbow", Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			palette, err := Palette(test.Name)
+			if test.Err {
+				if err == nil {
+					t.Fatal("expected err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if len(palette) != test.ExpectedLen {
+				t.Fatalf("got: %d, want: %d", len(palette), test.ExpectedLen)
+			}
+
+			seen := map[string]bool{}
+			for _, c := range palette {
+				h := RGBAToHex(c)
+				if seen[h] {
+					t.Errorf("duplicated color: %s", h)
+				}
+
+				seen[h] = true
+			}
+		})
+	}
+}
+
+func TestPalette_ReturnsCopy(t *testing.T) {
+	palette, _ := Palette(PALETTE_DEFAULT)
+	palette[0] = palette[1]
+
+	if ChitColors[0] == ChitColors[1] {
+		t.Error("ChitColors is changed")
+	}
+}
+
+func TestParseColorWithPalette(t *testing.T) {
+	palette, _ := Palette(PALETTE_OKABE_ITO)
+
+	actual, err := ParseColorWithPalette("2", palette)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if h := RGBAToHex(actual); h != "#56b4e9" {
+		t.Errorf("got: %s, want: %s", h, "#56b4e9")
+	}
+
+	if _, err := ParseColorWithPalette("9", palette); err == nil {
+		t.Error("expected err")
+	}
+}
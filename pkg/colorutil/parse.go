@@ -0,0 +1,80 @@
+package colorutil
+
+import (
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jyotiska/go-webcolors"
+)
+
+// rgbFuncRe は rgb(r, g, b) 形式の色を表す正規表現。
+var rgbFuncRe = regexp.MustCompile(`\A(?i:rgb)\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*\)\z`)
+
+// paletteIndexRe はチットの色のパレットの番号を表す正規表現。
+var paletteIndexRe = regexp.MustCompile(`\A\d+\z`)
+
+// ParseColor は文字列を色に変換する。
+//
+// 以下の形式に対応する。
+//
+//	CSS3の色名（dodgerblue など）
+//	#RRGGBB または #RGB
+//	rgb(r, g, b)（各成分は0〜255）
+//	チットの色のパレット ChitColors の番号（1始まり）
+func ParseColor(s string) (color.RGBA, error) {
+	return ParseColorWithPalette(s, ChitColors)
+}
+
+// ParseColorWithPalette は文字列を色に変換する。
+//
+// 対応する形式は ParseColor と同じだが、番号は palette の色の番号とみなす。
+func ParseColorWithPalette(s string, palette []color.RGBA) (color.RGBA, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "#") {
+		return HexToRGBA(s)
+	}
+
+	if matches := rgbFuncRe.FindStringSubmatch(s); matches != nil {
+		return parseRGBFunc(matches[1:])
+	}
+
+	if paletteIndexRe.MatchString(s) {
+		return PaletteColor(s, palette)
+	}
+
+	hex, found := webcolors.CSS3NamesToHex[strings.ToLower(s)]
+	if !found {
+		return color.RGBA{}, fmt.Errorf("unknown color: %s", s)
+	}
+
+	return HexToRGBA(hex)
+}
+
+// parseRGBFunc は rgb(r, g, b) 形式の各成分の文字列を色に変換する。
+func parseRGBFunc(components []string) (color.RGBA, error) {
+	rgb := make([]int, 0, 3)
+	for _, c := range components {
+		v, err := strconv.Atoi(c)
+		if err != nil || v > 0xFF {
+			return color.RGBA{}, fmt.Errorf("color component is out of range: %s", c)
+		}
+
+		rgb = append(rgb, v)
+	}
+
+	return RGBToRGBA(rgb), nil
+}
+
+// PaletteColor は、1始まりの番号が表すパレットの色を返す。
+func PaletteColor(s string, palette []color.RGBA) (color.RGBA, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 1 || i > len(palette) {
+		return color.RGBA{}, fmt.Errorf("palette index must be between 1 and %d: %s", len(palette), s)
+	}
+
+	return palette[i-1], nil
+}
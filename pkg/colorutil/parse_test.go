@@ -0,0 +1,52 @@
+package colorutil
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	testcases := []struct {
+		Input    string
+		Expected color.RGBA
+		Err      bool
+	}{
+		{Input: "white", Expected: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+		{Input: "DodgerBlue", Expected: color.RGBA{0x1E, 0x90, 0xFF, 0xFF}},
+		{Input: " dimgray ", Expected: color.RGBA{0x69, 0x69, 0x69, 0xFF}},
+		{Input: "#1a2B3c", Expected: color.RGBA{0x1A, 0x2B, 0x3C, 0xFF}},
+		{Input: "#1aF", Expected: color.RGBA{0x11, 0xAA, 0xFF, 0xFF}},
+		{Input: "rgb(30, 144, 255)", Expected: color.RGBA{0x1E, 0x90, 0xFF, 0xFF}},
+		{Input: "RGB(0,0,0)", Expected: color.RGBA{0, 0, 0, 0xFF}},
+		{Input: "rgb(256, 0, 0)", Err: true},
+		{Input: "rgb(1, 2)", Err: true},
+		{Input: "1", Expected: ChitColors[0]},
+		{Input: "10", Expected: ChitColors[9]},
+		{Input: "0", Err: true},
+		{Input: "11", Err: true},
+		{Input: "#12345", Err: true},
+		{Input: "unknown", Err: true},
+		{Input: "", Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			actual, err := ParseColor(test.Input)
+			if test.Err {
+				if err == nil {
+					t.Fatalf("got: %v, want: error", actual)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if actual != test.Expected {
+				t.Errorf("got: %v, want: %v", actual, test.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	COMMAND_SET_BACKGROUND    = "bg"
+	COMMAND_BACKGROUND_OPTION = "bgopt"
+	COMMAND_CLEAR_BACKGROUND  = "bgclear"
+
+	// MAX_IMAGE_SIZE は読み込む画像ファイルの最大サイズ（バイト）。
+	MAX_IMAGE_SIZE = 16 * 1024 * 1024
+	// MAX_IMAGE_PIXELS は読み込む画像の最大の画素数（幅 x 高さ）。
+	//
+	// 小さなファイルでも展開すると巨大になる画像を読み込まないように、
+	// 展開する前に画像の大きさを確かめる。
+	MAX_IMAGE_PIXELS = 4096 * 4096
+)
+
+var (
+	// ErrCannotOpen は外部の資源を読み込めないことを示すエラー。
+	ErrCannotOpen = errors.New("この環境ではファイルを読み込めません")
+	// ErrSourceNotAllowed は、引数で画像の取得元を指定できないことを示すエラー。
+	ErrSourceNotAllowed = errors.New("この環境ではファイル名やURLを指定できません。画像を添付してください")
+	// ErrBackgroundNotFound は背景画像が設定されていないことを示すエラー。
+	ErrBackgroundNotFound = errors.New("背景画像が設定されていません")
+)
+
+// BackgroundCommands は背景画像を操作するコマンドを返す。
+func BackgroundCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_SET_BACKGROUND,
+			ArgsDescription: "[ファイル名またはURL]",
+			Description:     "マップの背景画像（PNG/JPEG）を設定します。省略すると添付された画像を使用します",
+			Handler:         setBackground,
+		},
+		{
+			Name:            COMMAND_BACKGROUND_OPTION,
+			ArgsDescription: "fit on|off / cell 1マスのピクセル数 / offset (x, y) / opacity 0〜1",
+			Description:     "背景画像の配置を設定します",
+			Handler:         setBackgroundOption,
+		},
+		{
+			Name:        COMMAND_CLEAR_BACKGROUND,
+			Description: "マップの背景画像を削除します",
+			Handler:     clearBackground,
+		},
+	}
+}
+
+// loadImage は取得元から画像を読み込む。
+func (env *Env) loadImage(source string) (image.Image, error) {
+	if env.Open == nil {
+		return nil, ErrCannotOpen
+	}
+
+	rc, err := env.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(rc, MAX_IMAGE_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > MAX_IMAGE_SIZE {
+		return nil, fmt.Errorf("image is too large: %s", source)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", source, err)
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > MAX_IMAGE_PIXELS {
+		return nil, fmt.Errorf("image is too large: %s (%d x %d)", source, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", source, err)
+	}
+
+	return img, nil
+}
+
+// errNoImageSource は、画像の取得元が指定されていないことを示すエラー。
+var errNoImageSource = errors.New("no image source")
+
+// imageSource は、引数または添付ファイルから画像の取得元を返す。
+//
+// 取得元がなければ errNoImageSource を返す。
+// AttachmentsOnly が true の場合、引数で指定された取得元は受け付けない。
+func (env *Env) imageSource(argStr string) (string, error) {
+	if argStr != "" {
+		if env.AttachmentsOnly {
+			return "", ErrSourceNotAllowed
+		}
+
+		return argStr, nil
+	}
+
+	if len(env.Attachments) > 0 {
+		return env.Attachments[0], nil
+	}
+
+	return "", errNoImageSource
+}
+
+// setBackground はマップの背景画像を設定する。
+//
+// 既に背景が設定されている場合は、配置の設定を引き継ぐ。
+func setBackground(env *Env, c *Command, argStr string) *Result {
+	source, err := env.imageSource(argStr)
+	if err == errNoImageSource {
+		return usageError(c)
+	}
+
+	if err != nil {
+		return errorResult(err)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	img, err := env.loadImage(source)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	bg, found := sMap.Background()
+	if found {
+		bg.Image = img
+		bg.Source = source
+	} else {
+		bg = rpgmap.NewBackground(img, source)
+	}
+
+	err = sMap.SetBackground(bg)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	size := img.Bounds().Size()
+
+	return &Result{
+		Text:  fmt.Sprintf("背景画像を設定しました（%d x %d）", size.X, size.Y),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+var (
+	backgroundOptionRe = regexp.MustCompile(`\A(fit|cell|offset|opacity)\s+(.+)\z`)
+	onOffRe            = regexp.MustCompile(`\A(on|off)\z`)
+	nonNegativeIntRe   = regexp.MustCompile(`\A(\d+)\z`)
+	offsetRe           = regexp.MustCompile(`\A\((\d+),\s*(\d+)\)\z`)
+	opacityRe          = regexp.MustCompile(`\A(\d+(?:\.\d+)?|\.\d+)\z`)
+)
+
+// setBackgroundOption は背景画像の配置を設定する。
+func setBackgroundOption(env *Env, c *Command, argStr string) *Result {
+	matches := backgroundOptionRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	bg, found := sMap.Background()
+	if !found {
+		return errorResult(ErrBackgroundNotFound)
+	}
+
+	key := matches[1]
+	value := matches[2]
+	switch key {
+	case "fit":
+		m := onOffRe.FindStringSubmatch(value)
+		if m == nil {
+			return usageError(c)
+		}
+
+		bg.Fit = m[1] == "on"
+	case "cell":
+		m := nonNegativeIntRe.FindStringSubmatch(value)
+		if m == nil {
+			return usageError(c)
+		}
+
+		bg.CellSize, _ = strconv.Atoi(m[1])
+	case "offset":
+		m := offsetRe.FindStringSubmatch(value)
+		if m == nil {
+			return usageError(c)
+		}
+
+		bg.OffsetX, _ = strconv.Atoi(m[1])
+		bg.OffsetY, _ = strconv.Atoi(m[2])
+	case "opacity":
+		m := opacityRe.FindStringSubmatch(value)
+		if m == nil {
+			return usageError(c)
+		}
+
+		bg.Opacity, _ = strconv.ParseFloat(m[1], 64)
+	}
+
+	err = sMap.SetBackground(bg)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("背景画像: %s = %s", key, value),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// clearBackground はマップの背景画像を削除する。
+func clearBackground(env *Env, _ *Command, _ string) *Result {
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	if !sMap.ClearBackground() {
+		return errorResult(ErrBackgroundNotFound)
+	}
+
+	return &Result{
+		Text:  "背景画像を削除しました",
+		Image: env.NewMapImage(sMap),
+	}
+}
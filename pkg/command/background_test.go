@@ -0,0 +1,170 @@
+package command
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// newTestPNG は指定された大きさのPNG画像のデータを返す。
+func newTestPNG(w int, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+
+	return buf.Bytes()
+}
+
+// newHugePNGHeader は、幅と高さが大きなPNG画像の先頭部分のデータを返す。
+//
+// 画像データは含まないため、展開はできない。
+func newHugePNGHeader(w uint32, h uint32) []byte {
+	b := newTestPNG(1, 1)
+
+	// シグネチャ（8バイト）の後にIHDRチャンクが続く
+	ihdr := b[8 : 8+8+13+4]
+	binary.BigEndian.PutUint32(ihdr[8:12], w)
+	binary.BigEndian.PutUint32(ihdr[12:16], h)
+	binary.BigEndian.PutUint32(ihdr[21:25], crc32.ChecksumIEEE(ihdr[4:21]))
+
+	return b[:8+len(ihdr)]
+}
+
+// openTestImage は、テスト用の画像の取得元を開く。
+//
+// "bg.png" と "https://example.com/attachment.png" は 64 x 48 のPNG画像、
+// "huge.png" は大きさだけが巨大なPNG画像、"broken.png" は壊れた画像を返す。
+func openTestImage(source string) (io.ReadCloser, error) {
+	switch source {
+	case "bg.png", "https://example.com/attachment.png":
+		return ioutil.NopCloser(bytes.NewReader(newTestPNG(64, 48))), nil
+	case "huge.png":
+		return ioutil.NopCloser(bytes.NewReader(newHugePNGHeader(100000, 100000))), nil
+	case "broken.png":
+		return ioutil.NopCloser(bytes.NewReader([]byte("broken"))), nil
+	default:
+		return nil, fmt.Errorf("not found: %s", source)
+	}
+}
+
+func TestBackgroundCommands(t *testing.T) {
+	testcases := []struct {
+		Input        string
+		Setup        []string
+		Attachments  []string
+		NoOpen       bool
+		Restricted   bool
+		ExpectedText string
+		Err          error
+	}{
+		{Input: "bg bg.png", ExpectedText: "背景画像を設定しました（64 x 48）"},
+		{Input: "bg", Attachments: []string{"https://example.com/attachment.png"}, ExpectedText: "背景画像を設定しました（64 x 48）"},
+		{Input: "bg", Err: errUsage},
+		{Input: "bg none.png", Err: errAny},
+		{Input: "bg broken.png", Err: errAny},
+		{Input: "bg huge.png", Err: errAny},
+		{Input: "bg bg.png", NoOpen: true, Err: ErrCannotOpen},
+		{Input: "bg bg.png", Restricted: true, Err: ErrSourceNotAllowed},
+		{Input: "bg", Attachments: []string{"https://example.com/attachment.png"}, Restricted: true, ExpectedText: "背景画像を設定しました（64 x 48）"},
+		{Input: "bgopt fit on", Setup: []string{"bg bg.png"}, ExpectedText: "背景画像: fit = on"},
+		{Input: "bgopt fit yes", Setup: []string{"bg bg.png"}, Err: errUsage},
+		{Input: "bgopt cell 64", Setup: []string{"bg bg.png"}, ExpectedText: "背景画像: cell = 64"},
+		{Input: "bgopt offset (3, 4)", Setup: []string{"bg bg.png"}, ExpectedText: "背景画像: offset = (3, 4)"},
+		{Input: "bgopt opacity 0.5", Setup: []string{"bg bg.png"}, ExpectedText: "背景画像: opacity = 0.5"},
+		{Input: "bgopt opacity 1.5", Setup: []string{"bg bg.png"}, Err: errAny},
+		{Input: "bgopt size 10", Setup: []string{"bg bg.png"}, Err: errUsage},
+		{Input: "bgopt fit on", Err: ErrBackgroundNotFound},
+		{Input: "bgclear", Setup: []string{"bg bg.png"}, ExpectedText: "背景画像を削除しました"},
+		{Input: "bgclear", Err: ErrBackgroundNotFound},
+	}
+
+	r := NewRegistry("")
+	r.Register(BackgroundCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s, Open: openTestImage}
+			for _, input := range test.Setup {
+				r.Execute(env, input)
+			}
+
+			env.Attachments = test.Attachments
+			if test.NoOpen {
+				env.Open = nil
+			}
+
+			env.AttachmentsOnly = test.Restricted
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			if res.Image == nil {
+				t.Error("Image is not set")
+			}
+		})
+	}
+}
+
+func TestBackgroundCommands_KeepsOptionsWhenImageIsReplaced(t *testing.T) {
+	r := NewRegistry("")
+	r.Register(BackgroundCommands()...)
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s, Open: openTestImage}
+	r.Execute(env, "bg bg.png")
+	r.Execute(env, "bgopt opacity 0.25")
+	r.Execute(env, "bg bg.png")
+
+	bg, _ := m.Background()
+	if bg.Opacity != 0.25 {
+		t.Fatalf("Opacity: got %g, want %g", bg.Opacity, 0.25)
+	}
+}
+
+func TestLoadImage_RejectsHugeImage(t *testing.T) {
+	env := &Env{Store: NewMapStore(), Open: openTestImage}
+
+	_, err := env.loadImage("huge.png")
+	if err == nil {
+		t.Fatal("expected err")
+	}
+
+	// 展開する前に大きさで拒否する
+	if !strings.Contains(err.Error(), "too large") {
+		t.Errorf("got err: %s", err)
+	}
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+)
+
+const (
+	COMMAND_SET_CHIT_COLOR = "color"
+)
+
+// ChitColorCommands はチットの色を操作するコマンドを返す。
+func ChitColorCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_SET_CHIT_COLOR,
+			ArgsDescription: `"チット名" 色`,
+			Description:     "チットの色を変更します。色はCSS3の色名、#RRGGBB、#RGB、rgb(r, g, b) またはマップのパレットの番号（1始まり）で指定します",
+			Handler:         setChitColor,
+		},
+	}
+}
+
+var chitAndColorRe = regexp.MustCompile(`\A"([^"]+)"\s+(\S.*)\z`)
+
+// setChitColor はチットの色を変更する。
+func setChitColor(env *Env, c *Command, argStr string) *Result {
+	matches := chitAndColorRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	_, palette := sMap.ChitPalette()
+	color, err := colorutil.ParseColorWithPalette(matches[2], palette)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name, err := lookupChitName(sMap, matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chit, err := sMap.SetChitColor(name, color)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("チット「%s」の色: %s", chit.Name, colorutil.RGBAToHex(chit.Color)),
+		Image: env.NewMapImage(sMap),
+	}
+}
@@ -0,0 +1,63 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestChitColorCommands(t *testing.T) {
+	testcases := []struct {
+		Input         string
+		ExpectedText  string
+		ExpectedColor string
+		Err           error
+	}{
+		{Input: `color "A" dodgerblue`, ExpectedText: "チット「A」の色: #1e90ff", ExpectedColor: "#1e90ff"},
+		{Input: `color "A" #f00`, ExpectedText: "チット「A」の色: #ff0000", ExpectedColor: "#ff0000"},
+		{Input: `color "A" rgb(0, 128, 0)`, ExpectedText: "チット「A」の色: #008000", ExpectedColor: "#008000"},
+		{Input: `color "A" 1`, ExpectedText: "チット「A」の色: #ff1493", ExpectedColor: "#ff1493"},
+		{Input: `color "A" nocolor`, Err: errAny},
+		{Input: `color "B" red`, Err: errAny},
+		{Input: `color "A"`, Err: errUsage},
+	}
+
+	r := NewRegistry("")
+	r.Register(ChitColorCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			c, _ := m.FindChit("A")
+			if actual := colorutil.RGBAToHex(c.Color); actual != test.ExpectedColor {
+				t.Errorf("Color: got %s, want %s", actual, test.ExpectedColor)
+			}
+		})
+	}
+}
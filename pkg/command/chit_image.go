@@ -0,0 +1,84 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	COMMAND_CHIT_IMAGE = "chitimg"
+)
+
+// ChitImageCommands はチットの画像を操作するコマンドを返す。
+func ChitImageCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_CHIT_IMAGE,
+			ArgsDescription: "\"チット名\" [ファイル名/URL/メンション|off]",
+			Description:     "チットに画像（PNG/JPEG）を設定します。省略すると添付された画像を、offで画像を削除します",
+			Handler:         setChitImage,
+		},
+	}
+}
+
+var chitImageRe = regexp.MustCompile(`\A"([^"]+)"(?:\s+(\S+))?\z`)
+
+// setChitImage はチットの画像を設定する。
+func setChitImage(env *Env, c *Command, argStr string) *Result {
+	matches := chitImageRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	arg := matches[2]
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name, err := lookupChitName(sMap, matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	if arg == "off" {
+		chit, err := sMap.SetChitImage(name, nil, "")
+		if err != nil {
+			return errorResult(err)
+		}
+
+		return &Result{
+			Text:  fmt.Sprintf("チット「%s」の画像を削除しました", chit.Name),
+			Image: env.NewMapImage(sMap),
+		}
+	}
+
+	// アバター画像は、引数で取得元を指定できない環境でも使える
+	source, ok := env.Avatars[arg]
+	if !ok {
+		source, err = env.imageSource(arg)
+		if err == errNoImageSource {
+			return usageError(c)
+		}
+
+		if err != nil {
+			return errorResult(err)
+		}
+	}
+
+	img, err := env.loadImage(source)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chit, err := sMap.SetChitImage(name, img, source)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("チット「%s」の画像を設定しました", chit.Name),
+		Image: env.NewMapImage(sMap),
+	}
+}
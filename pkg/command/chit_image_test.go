@@ -0,0 +1,107 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestChitImageCommands(t *testing.T) {
+	testcases := []struct {
+		Input          string
+		Setup          []string
+		Attachments    []string
+		Avatars        map[string]string
+		Restricted     bool
+		ExpectedText   string
+		ExpectedSource string
+		Err            error
+	}{
+		{Input: `chitimg "A" bg.png`, ExpectedText: "チット「A」の画像を設定しました", ExpectedSource: "bg.png"},
+		{
+			Input:          `chitimg "A"`,
+			Attachments:    []string{"https://example.com/attachment.png"},
+			ExpectedText:   "チット「A」の画像を設定しました",
+			ExpectedSource: "https://example.com/attachment.png",
+		},
+		{
+			Input:          `chitimg "A" <@123>`,
+			Avatars:        map[string]string{"<@123>": "https://example.com/attachment.png"},
+			ExpectedText:   "チット「A」の画像を設定しました",
+			ExpectedSource: "https://example.com/attachment.png",
+		},
+		{
+			Input:          `chitimg "A"`,
+			Attachments:    []string{"https://example.com/attachment.png"},
+			Restricted:     true,
+			ExpectedText:   "チット「A」の画像を設定しました",
+			ExpectedSource: "https://example.com/attachment.png",
+		},
+		{
+			Input:          `chitimg "A" <@123>`,
+			Avatars:        map[string]string{"<@123>": "https://example.com/attachment.png"},
+			Restricted:     true,
+			ExpectedText:   "チット「A」の画像を設定しました",
+			ExpectedSource: "https://example.com/attachment.png",
+		},
+		{Input: `chitimg "A" bg.png`, Restricted: true, Err: ErrSourceNotAllowed},
+		{Input: `chitimg "A" <@456>`, Avatars: map[string]string{"<@123>": "https://example.com/attachment.png"}, Restricted: true, Err: ErrSourceNotAllowed},
+		{Input: `chitimg "A" huge.png`, Err: errAny},
+		{Input: `chitimg "A" off`, Setup: []string{`chitimg "A" bg.png`}, ExpectedText: "チット「A」の画像を削除しました"},
+		{Input: `chitimg "A"`, Err: errUsage},
+		{Input: `chitimg A bg.png`, Err: errUsage},
+		{Input: `chitimg "B" bg.png`, Err: errAny},
+		{Input: `chitimg "B" off`, Err: errAny},
+		{Input: `chitimg "A" none.png`, Err: errAny},
+		{Input: `chitimg "A" broken.png`, Err: errAny},
+	}
+
+	r := NewRegistry("")
+	r.Register(ChitImageCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s, Open: openTestImage}
+			for _, input := range test.Setup {
+				r.Execute(env, input)
+			}
+
+			env.Attachments = test.Attachments
+			env.Avatars = test.Avatars
+			env.AttachmentsOnly = test.Restricted
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			c, _ := m.FindChit("A")
+			if c.ImageSource != test.ExpectedSource {
+				t.Errorf("ImageSource: got %q, want %q", c.ImageSource, test.ExpectedSource)
+			}
+
+			if (c.Image != nil) != (test.ExpectedSource != "") {
+				t.Errorf("Image: got %v", c.Image)
+			}
+		})
+	}
+}
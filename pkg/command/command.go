@@ -0,0 +1,188 @@
+// command はメッセンジャーに依存しないマップ操作コマンドの機能を提供するパッケージ。
+//
+// Discordボットや REPL は、このパッケージのコマンドを実行し、
+// 返された結果をそれぞれの方法で出力する。
+package command
+
+import (
+	"errors"
+	"fmt"
+	"image/gif"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+)
+
+// Handler はコマンドハンドラの型。
+//
+// argStr はコマンド名に続く引数の文字列（前後の空白は除去済み）。
+type Handler func(env *Env, c *Command, argStr string) *Result
+
+// Command はコマンドを表す構造体。
+type Command struct {
+	// Name はコマンド名。
+	Name string
+	// ArgsDescription は引数の説明。
+	ArgsDescription string
+	// Description は解説。
+	Description string
+	// Handler はコマンドハンドラ。
+	Handler Handler
+}
+
+// Result はコマンドの実行結果の構造体。
+type Result struct {
+	// Text は出力する文字列。
+	Text string
+	// Image は出力するマップ画像の描画情報。nil ならば画像を出力しない。
+	Image *mapgen.SquareMapImage
+	// Animation は出力するアニメーションGIF。nil ならばアニメーションを出力しない。
+	Animation *gif.GIF
+	// Err はコマンドの実行中に発生したエラー。
+	Err error
+}
+
+// Env はコマンドの実行環境の構造体。
+type Env struct {
+	// Store は操作対象のマップの格納先。
+	Store *MapStore
+	// FontCache はフォントデータの格納先。
+	FontCache *mapgen.FontCache
+	// Theme は既定のテーマ。nil の場合は mapgen.DefaultTheme() を使う。
+	Theme *mapgen.Theme
+	// Attachments はコマンドとともに添付されたファイルの取得元（URLなど）。
+	Attachments []string
+	// Avatars は、メンションなどの利用者を表す文字列からアバター画像の取得元への対応。
+	Avatars map[string]string
+	// AttachmentsOnly は、引数で指定された取得元（ファイル名やURL）を受け付けないかどうか。
+	//
+	// true の場合、画像は添付ファイルまたはアバター画像からのみ読み込む。
+	AttachmentsOnly bool
+	// Open は、ファイル名やURLで指定された取得元を開く関数。
+	//
+	// nil の場合、コマンドは外部の資源を読み込めない。
+	Open func(source string) (io.ReadCloser, error)
+}
+
+var (
+	// ErrNotCommand は入力がコマンド実行を表していないことを示すエラー。
+	ErrNotCommand = errors.New("コマンドではありません")
+	// ErrMapNotFound はマップが作成されていないことを示すエラー。
+	ErrMapNotFound = errors.New("マップが作成されていません")
+)
+
+// UnknownCommandError は存在しないコマンドが指定されたことを示すエラー。
+type UnknownCommandError struct {
+	// Name は指定されたコマンド名。
+	Name string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("無効なコマンドです: %s", e.Name)
+}
+
+// UsageError はコマンドの引数が正しくないことを示すエラー。
+type UsageError struct {
+	// Command は実行されたコマンド。
+	Command *Command
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s: 引数が正しくありません", e.Command.Name)
+}
+
+// Registry はコマンドの登録先。
+type Registry struct {
+	// prefix はコマンド名の前に付ける文字列。
+	prefix string
+	// commandRe はコマンド実行を表す正規表現。
+	commandRe *regexp.Regexp
+	// commands は登録されたコマンド。
+	commands []*Command
+	// commandMap はコマンド名とコマンドとの対応。
+	commandMap map[string]*Command
+}
+
+// NewRegistry は新しいコマンドの登録先を返す。
+//
+// prefix は、コマンド名の前に付ける文字列（Discordボットでは "." など）。
+func NewRegistry(prefix string) *Registry {
+	return &Registry{
+		prefix:     prefix,
+		commandRe:  regexp.MustCompile(`\A` + regexp.QuoteMeta(prefix) + `([-!a-z]+)(?:\s+(.+))?`),
+		commands:   []*Command{},
+		commandMap: map[string]*Command{},
+	}
+}
+
+// Register はコマンドを登録する。
+//
+// 同じ名前のコマンドが既に登録されていた場合は置き換える。
+func (r *Registry) Register(commands ...Command) {
+	for i := range commands {
+		c := commands[i]
+
+		if old, found := r.commandMap[c.Name]; found {
+			*old = c
+			continue
+		}
+
+		r.commands = append(r.commands, &c)
+		r.commandMap[c.Name] = &c
+	}
+}
+
+// Commands は登録されたコマンドを登録順に返す。
+func (r *Registry) Commands() []*Command {
+	commands := make([]*Command, len(r.commands))
+	copy(commands, r.commands)
+
+	return commands
+}
+
+// Find は名前からコマンドを検索する。
+func (r *Registry) Find(name string) (*Command, bool) {
+	c, found := r.commandMap[name]
+	return c, found
+}
+
+// Usage はコマンドの使用方法の説明を返す。
+func (r *Registry) Usage(c *Command) string {
+	if c.ArgsDescription == "" {
+		return r.prefix + c.Name
+	}
+
+	return r.prefix + c.Name + " " + c.ArgsDescription
+}
+
+// Parse は入力を解析し、実行するコマンドと引数の文字列を返す。
+//
+// 入力がコマンド実行を表していない場合は ErrNotCommand を、
+// コマンドが見つからなかった場合は *UnknownCommandError を返す。
+func (r *Registry) Parse(input string) (*Command, string, error) {
+	matches := r.commandRe.FindStringSubmatch(strings.TrimSpace(input))
+	if matches == nil {
+		return nil, "", ErrNotCommand
+	}
+
+	c, found := r.Find(matches[1])
+	if !found {
+		return nil, "", &UnknownCommandError{Name: matches[1]}
+	}
+
+	return c, strings.TrimSpace(matches[2]), nil
+}
+
+// Execute は入力を解析し、対応するコマンドを実行する。
+//
+// 入力を解析できなかった場合は、Parse が返したエラーを返す。
+func (r *Registry) Execute(env *Env, input string) (*Command, *Result, error) {
+	c, argStr, err := r.Parse(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, c.Handler(env, c, argStr), nil
+}
@@ -0,0 +1,128 @@
+package command
+
+import (
+	"testing"
+)
+
+func newTestRegistry(prefix string) *Registry {
+	r := NewRegistry(prefix)
+	r.Register(MapCommands()...)
+
+	return r
+}
+
+func TestRegistry_Parse(t *testing.T) {
+	testcases := []struct {
+		Prefix       string
+		Input        string
+		ExpectedName string
+		ExpectedArgs string
+	}{
+		{Prefix: ".", Input: ".size", ExpectedName: "size", ExpectedArgs: ""},
+		{Prefix: ".", Input: "  .size  ", ExpectedName: "size", ExpectedArgs: ""},
+		{Prefix: ".", Input: ".init! 10 x 20", ExpectedName: "init!", ExpectedArgs: "10 x 20"},
+		{Prefix: ".", Input: `.addc "A" (1, 2)  `, ExpectedName: "addc", ExpectedArgs: `"A" (1, 2)`},
+		{Prefix: "", Input: "init! 10 x 20", ExpectedName: "init!", ExpectedArgs: "10 x 20"},
+		{Prefix: "", Input: `delc "A"`, ExpectedName: "delc", ExpectedArgs: `"A"`},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			r := newTestRegistry(test.Prefix)
+
+			c, args, err := r.Parse(test.Input)
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if c.Name != test.ExpectedName {
+				t.Errorf("Name: got %q, want %q", c.Name, test.ExpectedName)
+			}
+
+			if args != test.ExpectedArgs {
+				t.Errorf("Args: got %q, want %q", args, test.ExpectedArgs)
+			}
+		})
+	}
+}
+
+func TestRegistry_Parse_NotCommand(t *testing.T) {
+	testcases := []struct {
+		Prefix string
+		Input  string
+	}{
+		{Prefix: ".", Input: "size"},
+		{Prefix: ".", Input: "hello"},
+		{Prefix: ".", Input: ""},
+		{Prefix: "", Input: ""},
+		{Prefix: "", Input: "123"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			r := newTestRegistry(test.Prefix)
+
+			_, _, err := r.Parse(test.Input)
+			if err != ErrNotCommand {
+				t.Fatalf("got err: %v, want: %v", err, ErrNotCommand)
+			}
+		})
+	}
+}
+
+func TestRegistry_Parse_UnknownCommand(t *testing.T) {
+	r := newTestRegistry(".")
+
+	_, _, err := r.Parse(".unknown 1 2")
+	unknownErr, ok := err.(*UnknownCommandError)
+	if !ok {
+		t.Fatalf("got err: %v, want *UnknownCommandError", err)
+	}
+
+	if unknownErr.Name != "unknown" {
+		t.Fatalf("Name: got %q, want %q", unknownErr.Name, "unknown")
+	}
+}
+
+func TestRegistry_Register_ReplacesSameNamedCommand(t *testing.T) {
+	r := newTestRegistry(".")
+	numOfCommands := len(r.Commands())
+
+	r.Register(Command{
+		Name:        COMMAND_SIZE,
+		Description: "replaced",
+	})
+
+	if len(r.Commands()) != numOfCommands {
+		t.Fatalf("len(Commands): got %d, want %d", len(r.Commands()), numOfCommands)
+	}
+
+	c, _ := r.Find(COMMAND_SIZE)
+	if c.Description != "replaced" {
+		t.Fatalf("Description: got %q, want %q", c.Description, "replaced")
+	}
+}
+
+func TestRegistry_Usage(t *testing.T) {
+	testcases := []struct {
+		Prefix   string
+		Name     string
+		expected string
+	}{
+		{Prefix: ".", Name: COMMAND_SIZE, expected: ".size"},
+		{Prefix: ".", Name: COMMAND_ADD_CHIT, expected: `.addc "チット名" [x個数] (x, y)[-(x2, y2)] [色]`},
+		{Prefix: "", Name: COMMAND_INIT, expected: "init! [マップ名] 幅 x 高さ"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.expected, func(t *testing.T) {
+			r := newTestRegistry(test.Prefix)
+			c, _ := r.Find(test.Name)
+
+			actual := r.Usage(c)
+			if actual != test.expected {
+				t.Fatalf("got: %s, want: %s", actual, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	COMMAND_SET_GROUP    = "grp"
+	COMMAND_LIST_GROUPS  = "lsg"
+	COMMAND_MOVE_GROUP   = "mvg"
+	COMMAND_DELETE_GROUP = "delg"
+)
+
+// GroupCommands はチットのグループ（陣営）を操作するコマンドを返す。
+func GroupCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_SET_GROUP,
+			ArgsDescription: `"チット名" ["グループ名"]`,
+			Description:     "チットのグループを設定します。グループ名を省略するとグループから外します",
+			Handler:         setGroup,
+		},
+		{
+			Name:        COMMAND_LIST_GROUPS,
+			Description: "グループごとにチットの一覧を出力します",
+			Handler:     listGroups,
+		},
+		{
+			Name:            COMMAND_MOVE_GROUP,
+			ArgsDescription: `"グループ名" (x, y)|(±dx, ±dy)`,
+			Description: "グループのチットをまとめて移動します。" +
+				"(x, y) の場合はグループの左上を (x, y) に、(±dx, ±dy) の場合は相対的に移動します",
+			Handler: moveGroup,
+		},
+		{
+			Name:            COMMAND_DELETE_GROUP,
+			ArgsDescription: `"グループ名"`,
+			Description:     "グループのチットをすべて削除します",
+			Handler:         deleteGroup,
+		},
+	}
+}
+
+var chitAndOptionalGroupRe = regexp.MustCompile(`\A"([^"]+)"(?:\s+"([^"]+)")?\z`)
+
+// setGroup はチットのグループを設定する。
+func setGroup(env *Env, c *Command, argStr string) *Result {
+	matches := chitAndOptionalGroupRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name, err := lookupChitName(sMap, matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chit, err := sMap.SetChitGroup(name, matches[2])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	text := fmt.Sprintf("チット「%s」のグループ: %s", chit.Name, chit.Group)
+	if chit.Group == "" {
+		text = fmt.Sprintf("チット「%s」をグループから外しました", chit.Name)
+	}
+
+	return &Result{
+		Text:  text,
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// listGroups はグループごとにチットの一覧を返す。
+func listGroups(env *Env, _ *Command, _ string) *Result {
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	if sMap.NumOfChits() < 1 {
+		return &Result{Text: "（チット未登録）"}
+	}
+
+	lines := []string{}
+	for _, g := range sMap.Groups() {
+		lines = append(lines, groupLine(g, sMap.ChitsInGroup(g)))
+	}
+
+	if ungrouped := sMap.ChitsInGroup(""); len(ungrouped) > 0 {
+		lines = append(lines, groupLine("（グループなし）", ungrouped))
+	}
+
+	return &Result{Text: strings.Join(lines, "\n")}
+}
+
+// groupLine はグループのチットの一覧を表す1行の文字列を返す。
+func groupLine(name string, chits []*rpgmap.Chit) string {
+	chitStrs := make([]string, 0, len(chits))
+	for _, c := range chits {
+		chitStrs = append(chitStrs, c.String())
+	}
+
+	return fmt.Sprintf("%s: %s", name, strings.Join(chitStrs, ", "))
+}
+
+var groupAndCoordRe = regexp.MustCompile(`\A"([^"]+)"\s*\(([+-]?)(\d+),\s*([+-]?)(\d+)\)\z`)
+
+// moveGroup はグループのチットをまとめて移動する。
+func moveGroup(env *Env, c *Command, argStr string) *Result {
+	matches := groupAndCoordRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	// 符号は両方に付けるか、両方とも付けない
+	relative := matches[2] != ""
+	if relative != (matches[4] != "") {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	group := matches[1]
+	chits := sMap.ChitsInGroup(group)
+	if len(chits) < 1 {
+		return errorResult(fmt.Errorf("group not found: %s", group))
+	}
+
+	x, _ := strconv.Atoi(matches[2] + matches[3])
+	y, _ := strconv.Atoi(matches[4] + matches[5])
+
+	dx, dy := x, y
+	if !relative {
+		// グループの左上が (x, y) に来るように移動する
+		minX, minY := chits[0].X, chits[0].Y
+		for _, chit := range chits[1:] {
+			if chit.X < minX {
+				minX = chit.X
+			}
+
+			if chit.Y < minY {
+				minY = chit.Y
+			}
+		}
+
+		dx = x - 1 - minX
+		dy = y - 1 - minY
+	}
+
+	moved, err := sMap.MoveGroup(group, dx, dy)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  groupLine(group, moved),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+var groupNameRe = regexp.MustCompile(`\A"([^"]+)"\z`)
+
+// deleteGroup はグループのチットをすべて削除する。
+func deleteGroup(env *Env, c *Command, argStr string) *Result {
+	matches := groupNameRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	group := matches[1]
+
+	names, err := sMap.DeleteGroup(group)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("グループ「%s」のチット（%s）を削除しました", group, strings.Join(names, ", ")),
+		Image: env.NewMapImage(sMap),
+	}
+}
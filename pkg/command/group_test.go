@@ -0,0 +1,75 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestGroupCommands(t *testing.T) {
+	testcases := []struct {
+		Input        string
+		Setup        []string
+		ExpectedText string
+		Image        bool
+		Err          error
+	}{
+		{Input: `grp "A" "味方"`, ExpectedText: "チット「A」のグループ: 味方", Image: true},
+		{Input: `grp "G1"`, ExpectedText: "チット「G1」をグループから外しました", Image: true},
+		{Input: `grp "B" "味方"`, Err: errAny},
+		{Input: `grp A`, Err: errUsage},
+		{Input: "lsg", ExpectedText: "敵: G1 (5, 5), G2 (6, 7)\n（グループなし）: A (1, 2)"},
+		{Input: "lsg", Setup: []string{`grp "A" "味方"`}, ExpectedText: "味方: A (1, 2)\n敵: G1 (5, 5), G2 (6, 7)"},
+		{Input: `mvg "敵" (+1, -2)`, ExpectedText: "敵: G1 (6, 3), G2 (7, 5)", Image: true},
+		{Input: `mvg "敵" (1, 1)`, ExpectedText: "敵: G1 (1, 1), G2 (2, 3)", Image: true},
+		{Input: `mvg "敵" (+5, +0)`, Err: errAny},
+		{Input: `mvg "敵" (+1, 2)`, Err: errUsage},
+		{Input: `mvg "味方" (1, 1)`, Err: errAny},
+		{Input: `mvg "敵"`, Err: errUsage},
+		{Input: `delg "敵"`, ExpectedText: "グループ「敵」のチット（G1, G2）を削除しました", Image: true},
+		{Input: `delg "味方"`, Err: errAny},
+		{Input: `delg`, Err: errUsage},
+	}
+
+	r := NewRegistry("")
+	r.Register(GroupCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+			m.AddChit(&rpgmap.Chit{Name: "G1", X: 4, Y: 4, Group: "敵"})
+			m.AddChit(&rpgmap.Chit{Name: "G2", X: 5, Y: 6, Group: "敵"})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+			for _, input := range test.Setup {
+				r.Execute(env, input)
+			}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			if (res.Image != nil) != test.Image {
+				t.Errorf("Image: got %t, want %t", res.Image != nil, test.Image)
+			}
+		})
+	}
+}
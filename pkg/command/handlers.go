@@ -0,0 +1,735 @@
+package command
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	COMMAND_INIT        = "init!"
+	COMMAND_CLEAR       = "clear!"
+	COMMAND_SIZE        = "size"
+	COMMAND_RESIZE      = "resize"
+	COMMAND_RESIZE_DROP = "resize!"
+	COMMAND_CROP        = "crop"
+	COMMAND_CROP_DROP   = "crop!"
+	COMMAND_SHIFT       = "shift"
+	COMMAND_SHIFT_DROP  = "shift!"
+	COMMAND_LIST_CHITS  = "lsc"
+	COMMAND_ADD_CHIT    = "addc"
+	COMMAND_DELETE_CHIT = "delc"
+	COMMAND_MOVE_CHIT   = "mvc"
+	COMMAND_RENAME_CHIT = "renc"
+	COMMAND_CLONE_CHIT  = "dupc"
+	COMMAND_USE_MAP     = "use"
+	COMMAND_LIST_MAPS   = "maps"
+	COMMAND_HELP        = "help"
+)
+
+// MAX_BULK_CHITS は、一度にまとめて追加できるチットの最大数。
+const MAX_BULK_CHITS = 50
+
+// MapCommands はマップを操作する共通のコマンドを返す。
+func MapCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_INIT,
+			ArgsDescription: "[マップ名] 幅 x 高さ",
+			Description:     "マップを指定された大きさで初期化し、選択します。マップ名を省略すると選択中のマップを初期化します（要注意！）",
+			Handler:         initMap,
+		},
+		{
+			Name:            COMMAND_CLEAR,
+			ArgsDescription: "[マップ名]",
+			Description:     "マップを削除します。マップ名を省略すると選択中のマップを削除します（要注意！）",
+			Handler:         clearMap,
+		},
+		{
+			Name:            COMMAND_USE_MAP,
+			ArgsDescription: "[マップ名]",
+			Description:     "操作するマップを選択します。マップ名を省略すると名前のないマップを選択します",
+			Handler:         useMap,
+		},
+		{
+			Name:        COMMAND_LIST_MAPS,
+			Description: "マップの一覧を出力します",
+			Handler:     listMaps,
+		},
+		{
+			Name:        COMMAND_SIZE,
+			Description: "マップの大きさを返します",
+			Handler:     mapSize,
+		},
+		{
+			Name:            COMMAND_RESIZE,
+			ArgsDescription: "幅 x 高さ [基準位置]",
+			Description: "チットを残したままマップの大きさを変更します。" +
+				"基準位置（" + strings.Join(rpgmap.Anchors, ", ") + "）を固定し、省略すると左上を固定します。" +
+				"範囲外に出るチットがある場合は変更しません",
+			Handler: resizeMap,
+		},
+		{
+			Name:            COMMAND_RESIZE_DROP,
+			ArgsDescription: "幅 x 高さ [基準位置]",
+			Description:     "マップの大きさを変更し、範囲外に出るチットを削除します（要注意！）",
+			Handler:         resizeMap,
+		},
+		{
+			Name:            COMMAND_CROP,
+			ArgsDescription: "(x1, y1)-(x2, y2)",
+			Description: "マップを指定した範囲に切り抜きます。範囲の左上が新しいマップの左上になります。" +
+				"範囲外に出るチットがある場合は変更しません",
+			Handler: cropMap,
+		},
+		{
+			Name:            COMMAND_CROP_DROP,
+			ArgsDescription: "(x1, y1)-(x2, y2)",
+			Description:     "マップを指定した範囲に切り抜き、範囲外に出るチットを削除します（要注意！）",
+			Handler:         cropMap,
+		},
+		{
+			Name:            COMMAND_SHIFT,
+			ArgsDescription: "(±dx, ±dy)",
+			Description: "マップの大きさを変えずに、チットと背景画像をまとめてずらします。" +
+				"範囲外に出るチットがある場合は変更しません",
+			Handler: shiftMap,
+		},
+		{
+			Name:            COMMAND_SHIFT_DROP,
+			ArgsDescription: "(±dx, ±dy)",
+			Description:     "チットと背景画像をまとめてずらし、範囲外に出るチットを削除します（要注意！）",
+			Handler:         shiftMap,
+		},
+		{
+			Name:        COMMAND_LIST_CHITS,
+			Description: "チットの一覧を出力します",
+			Handler:     listChits,
+		},
+		{
+			Name:            COMMAND_ADD_CHIT,
+			ArgsDescription: `"チット名" [x個数] (x, y)[-(x2, y2)] [色]`,
+			Description: "チットを追加します。" +
+				"個数を指定すると「チット名 1」から順に番号を付けてまとめて追加し、" +
+				"(x, y)-(x2, y2) の範囲内または (x, y) の近くの空いているマスに配置します",
+			Handler: addChit,
+		},
+		{
+			Name:            COMMAND_DELETE_CHIT,
+			ArgsDescription: `"チット名"`,
+			Description:     "チットを削除します",
+			Handler:         deleteChit,
+		},
+		{
+			Name:            COMMAND_MOVE_CHIT,
+			ArgsDescription: `"チット名" (x, y)`,
+			Description:     "チットを移動します",
+			Handler:         moveChit,
+		},
+		{
+			Name:            COMMAND_RENAME_CHIT,
+			ArgsDescription: `"チット名" "新しいチット名"`,
+			Description:     "チットの名前を変更します。色などの設定はそのまま引き継ぎます",
+			Handler:         renameChit,
+		},
+		{
+			Name:            COMMAND_CLONE_CHIT,
+			ArgsDescription: `"チット名" "新しいチット名" [(x, y)]`,
+			Description: "チットを複製します。" +
+				"(x, y) またはチットの近くの空いているマスに、同じ色などの設定のチットを追加します",
+			Handler: cloneChit,
+		},
+	}
+}
+
+// usageError はコマンドcの使用法の誤りを表す結果を返す。
+func usageError(c *Command) *Result {
+	return &Result{Err: &UsageError{Command: c}}
+}
+
+// errorResult はエラーを表す結果を返す。
+func errorResult(err error) *Result {
+	return &Result{Err: err}
+}
+
+// lookupChitName は、利用者が入力した名前に対応するチットの名前を返す。
+//
+// 名前の先頭だけが一致するチットが1つだけの場合も、そのチットの名前を返す。
+// 削除や名前の変更では、取り違えを防ぐためにこの補完を使わないこと。
+func lookupChitName(sMap *rpgmap.SquareMap, name string) (string, error) {
+	c, err := sMap.LookupChit(name)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Name, nil
+}
+
+// currentMap は操作対象のマップを返す。
+//
+// マップが作成されていない場合は ErrMapNotFound を返す。
+func (env *Env) currentMap() (*rpgmap.SquareMap, error) {
+	m, found := env.Store.Map()
+	if !found {
+		return nil, ErrMapNotFound
+	}
+
+	return m, nil
+}
+
+// NewMapImage は、実行環境のテーマを適用したマップの描画情報を返す。
+func (env *Env) NewMapImage(m *rpgmap.SquareMap) *mapgen.SquareMapImage {
+	i := mapgen.NewSquareMapImage(m, env.FontCache)
+	i.ApplyTheme(env.theme())
+
+	return i
+}
+
+// theme はマップの描画に使うテーマを返す。
+//
+// 格納先にテーマが設定されていればそれを、そうでなければ既定のテーマを返す。
+func (env *Env) theme() *mapgen.Theme {
+	if t, found := env.Store.Theme(); found {
+		return t
+	}
+
+	if env.Theme != nil {
+		return env.Theme.Clone()
+	}
+
+	return mapgen.DefaultTheme()
+}
+
+// mapText は、マップについての出力にマップ名を付けた文字列を返す。
+//
+// 名前のないマップの場合は text をそのまま返す。
+func mapText(name string, text string) string {
+	if name == DEFAULT_MAP_NAME {
+		return text
+	}
+
+	return name + ": " + text
+}
+
+var initMapRe = regexp.MustCompile(`\A(?:(\S+)\s+)?(\d+)\s*x\s*(\d+)\z`)
+
+// initMap はマップを指定された大きさで初期化する。
+func initMap(env *Env, c *Command, argStr string) *Result {
+	matches := initMapRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	name := matches[1]
+	if name == "" {
+		name = env.Store.CurrentName()
+	}
+
+	width, _ := strconv.Atoi(matches[2])
+	height, _ := strconv.Atoi(matches[3])
+
+	newMap, err := rpgmap.NewSquareMap(width, height)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	env.Store.SetMap(name, newMap)
+
+	return &Result{
+		Text:  mapText(name, newMap.String()),
+		Image: env.NewMapImage(newMap),
+	}
+}
+
+var mapNameRe = regexp.MustCompile(`\A(\S*)\z`)
+
+// clearMap はマップを削除する。
+func clearMap(env *Env, c *Command, argStr string) *Result {
+	matches := mapNameRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	name := matches[1]
+	if name == "" {
+		name = env.Store.CurrentName()
+	}
+
+	if !env.Store.DeleteMap(name) {
+		return errorResult(ErrMapNotFound)
+	}
+
+	return &Result{Text: mapText(name, "マップを削除しました")}
+}
+
+// useMap は操作するマップを選択する。
+func useMap(env *Env, c *Command, argStr string) *Result {
+	matches := mapNameRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	name := matches[1]
+
+	err := env.Store.Use(name)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	sMap, _ := env.Store.FindMap(name)
+
+	return &Result{
+		Text:  mapText(name, sMap.String()),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// listMaps はマップの一覧を返す。
+func listMaps(env *Env, _ *Command, _ string) *Result {
+	names := env.Store.Names()
+	if len(names) < 1 {
+		return errorResult(ErrMapNotFound)
+	}
+
+	currentName := env.Store.CurrentName()
+
+	mapStrs := make([]string, 0, len(names))
+	for _, name := range names {
+		sMap, found := env.Store.FindMap(name)
+		if !found {
+			// 一覧の取得後に削除された
+			continue
+		}
+
+		s := MapLabel(name) + " " + sMap.SizeStr()
+		if name == currentName {
+			s += "（選択中）"
+		}
+
+		mapStrs = append(mapStrs, s)
+	}
+
+	return &Result{Text: strings.Join(mapStrs, "\n")}
+}
+
+// mapSize はマップの大きさを返す。
+func mapSize(env *Env, _ *Command, _ string) *Result {
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{Text: sMap.SizeStr()}
+}
+
+var resizeMapRe = regexp.MustCompile(`\A(\d+)\s*x\s*(\d+)(?:\s+(\S+))?\z`)
+
+// resizeMap はマップの大きさを変更する。
+//
+// COMMAND_RESIZE_DROP の場合は、範囲外に出るチットを削除する。
+func resizeMap(env *Env, c *Command, argStr string) *Result {
+	matches := resizeMapRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	width, _ := strconv.Atoi(matches[1])
+	height, _ := strconv.Atoi(matches[2])
+
+	anchor := rpgmap.ANCHOR_TOP_LEFT
+	if matches[3] != "" {
+		anchor = strings.ToLower(matches[3])
+	}
+
+	dropped, err := sMap.Resize(width, height, anchor, c.Name == COMMAND_RESIZE_DROP)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return resizedMapResult(env, sMap, dropped)
+}
+
+var cropMapRe = regexp.MustCompile(`\A\((\d+),\s*(\d+)\)\s*-\s*\((\d+),\s*(\d+)\)\z`)
+
+// cropMap はマップを指定した範囲に切り抜く。
+//
+// COMMAND_CROP_DROP の場合は、範囲外に出るチットを削除する。
+func cropMap(env *Env, c *Command, argStr string) *Result {
+	matches := cropMapRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	x1, _ := strconv.Atoi(matches[1])
+	y1, _ := strconv.Atoi(matches[2])
+	x2, _ := strconv.Atoi(matches[3])
+	y2, _ := strconv.Atoi(matches[4])
+
+	// 両端のマスを含む矩形にする
+	r := image.Rect(x1-1, y1-1, x2-1, y2-1).Canon()
+	r.Max = r.Max.Add(image.Pt(1, 1))
+
+	dropped, err := sMap.Crop(r, c.Name == COMMAND_CROP_DROP)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return resizedMapResult(env, sMap, dropped)
+}
+
+var shiftMapRe = regexp.MustCompile(`\A\(([+-]?\d+),\s*([+-]?\d+)\)\z`)
+
+// shiftMap はチットと背景画像をまとめてずらす。
+//
+// COMMAND_SHIFT_DROP の場合は、範囲外に出るチットを削除する。
+func shiftMap(env *Env, c *Command, argStr string) *Result {
+	matches := shiftMapRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	dx, _ := strconv.Atoi(matches[1])
+	dy, _ := strconv.Atoi(matches[2])
+
+	dropped, err := sMap.Shift(dx, dy, c.Name == COMMAND_SHIFT_DROP)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return resizedMapResult(env, sMap, dropped)
+}
+
+// resizedMapResult は、マップの大きさや配置を変更した結果を返す。
+//
+// dropped は範囲外に出て削除したチットの名前。
+func resizedMapResult(env *Env, sMap *rpgmap.SquareMap, dropped []string) *Result {
+	text := mapText(env.Store.CurrentName(), sMap.String())
+	if len(dropped) > 0 {
+		text += fmt.Sprintf("\n範囲外のチット（%s）を削除しました", strings.Join(dropped, ", "))
+	}
+
+	return &Result{
+		Text:  text,
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// listChits はチットの一覧を返す。
+func listChits(env *Env, _ *Command, _ string) *Result {
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chitStrs := []string{}
+	sMap.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		chitStrs = append(chitStrs, c.String())
+	})
+
+	if len(chitStrs) < 1 {
+		return &Result{Text: "（チット未登録）"}
+	}
+
+	return &Result{Text: strings.Join(chitStrs, "\n")}
+}
+
+var chitAndCoordRe = regexp.MustCompile(`\A"([^"]+)"\s*\((\d+),\s*(\d+)\)\z`)
+
+var chitCoordAndColorRe = regexp.MustCompile(`\A"([^"]+)"\s*\((\d+),\s*(\d+)\)(?:\s+(\S.*))?\z`)
+
+var bulkChitsRe = regexp.MustCompile(
+	`\A"([^"]+)"\s+x(\d+)\s*\((\d+),\s*(\d+)\)(?:\s*-\s*\((\d+),\s*(\d+)\))?(?:\s+(\S.*))?\z`)
+
+// addChit はチットを追加する。
+//
+// 色が指定されなかった場合は、マップで使われていない色を割り当てる。
+func addChit(env *Env, c *Command, argStr string) *Result {
+	if matches := bulkChitsRe.FindStringSubmatch(argStr); matches != nil {
+		return addBulkChits(env, c, matches)
+	}
+
+	matches := chitCoordAndColorRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name := matches[1]
+	x, _ := strconv.Atoi(matches[2])
+	y, _ := strconv.Atoi(matches[3])
+
+	chit := rpgmap.Chit{
+		Name: name,
+		X:    x - 1,
+		Y:    y - 1,
+	}
+
+	if matches[4] != "" {
+		_, palette := sMap.ChitPalette()
+		chit.Color, err = colorutil.ParseColorWithPalette(matches[4], palette)
+		if err != nil {
+			return errorResult(err)
+		}
+	}
+
+	err = sMap.AddChit(&chit)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  chit.String(),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// addBulkChits は、番号を付けたチットをまとめて追加する。
+//
+// matches は bulkChitsRe にマッチした結果。
+// 範囲が指定されなかった場合は、指定されたマスの近くに配置する。
+// 色が指定された場合は、すべてのチットをその色にする。
+func addBulkChits(env *Env, c *Command, matches []string) *Result {
+	n, err := strconv.Atoi(matches[2])
+	if err != nil || n < 1 || n > MAX_BULK_CHITS {
+		return errorResult(fmt.Errorf("number of chits must be between 1 and %d: %s", MAX_BULK_CHITS, matches[2]))
+	}
+
+	x1, _ := strconv.Atoi(matches[3])
+	y1, _ := strconv.Atoi(matches[4])
+	x2, y2 := x1, y1
+	if matches[5] != "" {
+		x2, _ = strconv.Atoi(matches[5])
+		y2, _ = strconv.Atoi(matches[6])
+	}
+
+	if x1 < 1 || y1 < 1 || x2 < 1 || y2 < 1 {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	var chitColor color.RGBA
+	if matches[7] != "" {
+		_, palette := sMap.ChitPalette()
+		chitColor, err = colorutil.ParseColorWithPalette(matches[7], palette)
+		if err != nil {
+			return errorResult(err)
+		}
+	}
+
+	name := matches[1]
+	first := nextChitNumber(sMap, name)
+
+	chits := make([]*rpgmap.Chit, 0, n)
+	for i := 0; i < n; i++ {
+		chits = append(chits, &rpgmap.Chit{
+			Name:  fmt.Sprintf("%s %d", name, first+i),
+			Color: chitColor,
+		})
+	}
+
+	// 座標は1始まりで、範囲の右下のマスを含む
+	corners := image.Rect(x1-1, y1-1, x2-1, y2-1)
+	area := image.Rectangle{Min: corners.Min, Max: corners.Max.Add(image.Pt(1, 1))}
+
+	err = sMap.PlaceChits(chits, area)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chitStrs := make([]string, 0, len(chits))
+	for _, chit := range chits {
+		chitStrs = append(chitStrs, chit.String())
+	}
+
+	return &Result{
+		Text:  strings.Join(chitStrs, "\n"),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// nextChitNumber は、「name 番号」という名前のチットに次に付ける番号を返す。
+//
+// 既存のチットの最大の番号の次の番号を返す。該当するチットがなければ1を返す。
+// チットの名前と同様に、名前は正規化して比較する。
+func nextChitNumber(sMap *rpgmap.SquareMap, name string) int {
+	prefix := rpgmap.NormalizeChitName(name) + " "
+	max := 0
+	sMap.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		normalized := rpgmap.NormalizeChitName(c.Name)
+		if !strings.HasPrefix(normalized, prefix) {
+			return
+		}
+
+		k, err := strconv.Atoi(strings.TrimPrefix(normalized, prefix))
+		if err == nil && k > max {
+			max = k
+		}
+	})
+
+	return max + 1
+}
+
+var chitNameRe = regexp.MustCompile(`\A"([^"]+)"\z`)
+
+// deleteChit はチットを削除する。
+func deleteChit(env *Env, c *Command, argStr string) *Result {
+	matches := chitNameRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	// 名前の先頭だけが一致するチットは削除しない
+	name := matches[1]
+	if chit, found := sMap.FindChit(name); found {
+		name = chit.Name
+	}
+
+	err = sMap.DeleteChit(name)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("チット「%s」を削除しました", name),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// moveChit はチットを移動する。
+func moveChit(env *Env, c *Command, argStr string) *Result {
+	matches := chitAndCoordRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name, err := lookupChitName(sMap, matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	x, _ := strconv.Atoi(matches[2])
+	y, _ := strconv.Atoi(matches[3])
+
+	chit, err := sMap.MoveChit(name, x-1, y-1)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	mImg := env.NewMapImage(sMap)
+	mImg.LastMovedChit = chit.Name
+
+	return &Result{
+		Text:  chit.String(),
+		Image: mImg,
+	}
+}
+
+var chitAndNewNameRe = regexp.MustCompile(`\A"([^"]+)"\s+"([^"]+)"\z`)
+
+// renameChit はチットの名前を変更する。
+func renameChit(env *Env, c *Command, argStr string) *Result {
+	matches := chitAndNewNameRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	// 名前の先頭だけが一致するチットの名前は変更しない
+	name := matches[1]
+	if chit, found := sMap.FindChit(name); found {
+		name = chit.Name
+	}
+
+	chit, err := sMap.RenameChit(name, matches[2])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("チット「%s」の名前を「%s」に変更しました", name, chit.Name),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+var chitNewNameAndOptionalCoordRe = regexp.MustCompile(`\A"([^"]+)"\s+"([^"]+)"(?:\s*\((\d+),\s*(\d+)\))?\z`)
+
+// cloneChit はチットを複製する。
+//
+// 座標が指定されなかった場合は、元のチットの近くに置く。
+func cloneChit(env *Env, c *Command, argStr string) *Result {
+	matches := chitNewNameAndOptionalCoordRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	orig, err := sMap.LookupChit(matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	near := image.Pt(orig.X, orig.Y)
+	if matches[3] != "" {
+		x, _ := strconv.Atoi(matches[3])
+		y, _ := strconv.Atoi(matches[4])
+		near = image.Pt(x-1, y-1)
+	}
+
+	chit, err := sMap.CloneChit(orig.Name, matches[2], near)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  chit.String(),
+		Image: env.NewMapImage(sMap),
+	}
+}
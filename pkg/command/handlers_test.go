@@ -0,0 +1,390 @@
+package command
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestMapCommands(t *testing.T) {
+	testcases := []struct {
+		Input        string
+		NoMap        bool
+		ExpectedText string
+		Image        bool
+		Err          error
+	}{
+		{Input: "init! 20 x 15", ExpectedText: "SquareMap (20 x 15)", Image: true},
+		{Input: "init! 1 x 15", Err: errAny},
+		{Input: "init! 20", Err: errUsage},
+		{Input: "init! battle 20 x 15", ExpectedText: "battle: SquareMap (20 x 15)", Image: true},
+		{Input: "init! battle 20", Err: errUsage},
+		{Input: "clear!", ExpectedText: "マップを削除しました"},
+		{Input: "clear!", NoMap: true, Err: ErrMapNotFound},
+		{Input: "clear! battle", Err: ErrMapNotFound},
+		{Input: "clear! a b", Err: errUsage},
+		{Input: "use", ExpectedText: "SquareMap (10 x 10)", Image: true},
+		{Input: "use town", Err: errAny},
+		{Input: "use a b", Err: errUsage},
+		{Input: "maps", ExpectedText: "（名前なし） 10 x 10（選択中）"},
+		{Input: "maps", NoMap: true, Err: ErrMapNotFound},
+		{Input: "size", ExpectedText: "10 x 10"},
+		{Input: "size", NoMap: true, Err: ErrMapNotFound},
+		{Input: "resize 12 x 8", ExpectedText: "SquareMap (12 x 8)", Image: true},
+		{Input: "resize 12 x 12 bottom-right", ExpectedText: "SquareMap (12 x 12)", Image: true},
+		{Input: "resize 8 x 8 right", Err: errAny},
+		{Input: "resize 8 x 8 middle", Err: errAny},
+		{Input: "resize! 8 x 8 right", ExpectedText: "SquareMap (8 x 8)\n範囲外のチット（A）を削除しました", Image: true},
+		{Input: "resize 12", Err: errUsage},
+		{Input: "resize 12 x 8", NoMap: true, Err: ErrMapNotFound},
+		{Input: "crop (1, 1)-(6, 5)", ExpectedText: "SquareMap (6 x 5)", Image: true},
+		{Input: "crop (6, 5)-(1, 2)", ExpectedText: "SquareMap (6 x 4)", Image: true},
+		{Input: "crop (2, 3)-(5, 6)", Err: errAny},
+		{Input: "crop! (2, 3)-(5, 6)", ExpectedText: "SquareMap (4 x 4)\n範囲外のチット（A）を削除しました", Image: true},
+		{Input: "crop (1, 1)-(1, 5)", Err: errAny},
+		{Input: "crop (1, 1)", Err: errUsage},
+		{Input: "crop (1, 1)-(6, 5)", NoMap: true, Err: ErrMapNotFound},
+		{Input: "shift (2, -1)", ExpectedText: "SquareMap (10 x 10)", Image: true},
+		{Input: "shift (+1, +1)", ExpectedText: "SquareMap (10 x 10)", Image: true},
+		{Input: "shift (-1, 0)", Err: errAny},
+		{Input: "shift! (0, 9)", ExpectedText: "SquareMap (10 x 10)\n範囲外のチット（A）を削除しました", Image: true},
+		{Input: "shift 2", Err: errUsage},
+		{Input: "shift (2, -1)", NoMap: true, Err: ErrMapNotFound},
+		{Input: "lsc", ExpectedText: "A (1, 2)"},
+		{Input: "lsc", NoMap: true, Err: ErrMapNotFound},
+		{Input: `addc "B" (3, 4)`, ExpectedText: "B (3, 4)", Image: true},
+		{Input: `addc "A" (3, 4)`, Err: errAny},
+		{Input: `addc "B" (11, 4)`, Err: errAny},
+		{Input: `addc "B" (3, 4) dodgerblue`, ExpectedText: "B (3, 4)", Image: true},
+		{Input: `addc "B" (3, 4) rgb(1, 2, 3)`, ExpectedText: "B (3, 4)", Image: true},
+		{Input: `addc "B" (3, 4) nocolor`, Err: errAny},
+		{Input: `addc "G" x3 (1, 1)-(2, 2)`, ExpectedText: "G 1 (1, 1)\nG 2 (2, 1)\nG 3 (2, 2)", Image: true},
+		{Input: `addc "G" x2 (1, 2)`, ExpectedText: "G 1 (1, 1)\nG 2 (2, 1)", Image: true},
+		{Input: `addc "G" x2 (5, 5) red`, ExpectedText: "G 1 (5, 5)\nG 2 (4, 4)", Image: true},
+		{Input: `addc "G" x4 (1, 1)-(2, 2)`, Err: errAny},
+		{Input: `addc "G" x2 (10, 10)-(11, 10)`, Err: errAny},
+		{Input: `addc "G" x0 (1, 1)`, Err: errAny},
+		{Input: `addc "G" x51 (1, 1)`, Err: errAny},
+		{Input: `addc "B"`, Err: errUsage},
+		{Input: `addc "B" (3, 4)`, NoMap: true, Err: ErrMapNotFound},
+		{Input: `delc "A"`, ExpectedText: "チット「A」を削除しました", Image: true},
+		{Input: `delc "ａ"`, ExpectedText: "チット「A」を削除しました", Image: true},
+		{Input: `delc "B"`, Err: errAny},
+		{Input: `delc "A" (1, 2)`, Err: errUsage},
+		{Input: `delc "A"`, NoMap: true, Err: ErrMapNotFound},
+		{Input: `mvc "A" (5, 6)`, ExpectedText: "A (5, 6)", Image: true},
+		{Input: `mvc "a" (5, 6)`, ExpectedText: "A (5, 6)", Image: true},
+		{Input: `mvc "B" (5, 6)`, Err: errAny},
+		{Input: `mvc "A" (0, 6)`, Err: errAny},
+		{Input: `mvc "A"`, Err: errUsage},
+		{Input: `mvc "A" (5, 6)`, NoMap: true, Err: ErrMapNotFound},
+		{Input: `renc "A" "B"`, ExpectedText: "チット「A」の名前を「B」に変更しました", Image: true},
+		{Input: `renc "B" "C"`, Err: errAny},
+		{Input: `renc "A"`, Err: errUsage},
+		{Input: `renc "A" "B"`, NoMap: true, Err: ErrMapNotFound},
+		{Input: `dupc "A" "B"`, ExpectedText: "B (1, 1)", Image: true},
+		{Input: `dupc "A" "B" (5, 6)`, ExpectedText: "B (5, 6)", Image: true},
+		{Input: `dupc "A" "A"`, Err: errAny},
+		{Input: `dupc "B" "C"`, Err: errAny},
+		{Input: `dupc "A" "B" (11, 6)`, Err: errAny},
+		{Input: `dupc "A"`, Err: errUsage},
+		{Input: `dupc "A" "B"`, NoMap: true, Err: ErrMapNotFound},
+	}
+
+	r := newTestRegistry("")
+
+	for _, test := range testcases {
+		name := test.Input
+		if test.NoMap {
+			name += " (no map)"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+			if test.NoMap {
+				env.Store.DeleteMap(DEFAULT_MAP_NAME)
+			}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			if (res.Image != nil) != test.Image {
+				t.Errorf("Image: got %v, want %v", res.Image != nil, test.Image)
+			}
+		})
+	}
+}
+
+func TestMapCommands_AddBulkChitsContinuesNumbering(t *testing.T) {
+	testcases := []struct {
+		Input    string
+		Expected string
+	}{
+		{Input: `addc "G" x2 (1, 5)-(2, 5)`, Expected: "G 3 (1, 5)\nG 4 (2, 5)"},
+		// 名前は正規化して比較する
+		{Input: `addc "ｇ" x2 (1, 5)-(2, 5)`, Expected: "ｇ 3 (1, 5)\nｇ 4 (2, 5)"},
+	}
+
+	r := newTestRegistry("")
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+			for _, input := range []string{`addc "G 2" (5, 5)`, `addc "G 10x" (6, 6)`} {
+				if _, res, _ := r.Execute(env, input); res.Err != nil {
+					t.Fatalf("%s: got err: %s", input, res.Err)
+				}
+			}
+
+			_, res, _ := r.Execute(env, test.Input)
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.Expected {
+				t.Errorf("got: %q, want: %q", res.Text, test.Expected)
+			}
+		})
+	}
+}
+
+func TestMapCommands_SuggestsChitNames(t *testing.T) {
+	r := newTestRegistry("")
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s}
+
+	if _, res, _ := r.Execute(env, `addc "Goblin 1" (5, 5)`); res.Err != nil {
+		t.Fatalf("got err: %s", res.Err)
+	}
+
+	_, res, _ := r.Execute(env, `mvc "Goblin 2" (6, 6)`)
+	if res.Err == nil {
+		t.Fatal("expected err")
+	}
+
+	expected := `chit not found: Goblin 2 (did you mean "Goblin 1"?)`
+	if res.Err.Error() != expected {
+		t.Errorf("got: %s, want: %s", res.Err, expected)
+	}
+}
+
+func TestMapCommands_PrefixMatch(t *testing.T) {
+	testcases := []struct {
+		Input        string
+		ExpectedText string
+		Err          error
+	}{
+		{Input: `mvc "al" (5, 6)`, ExpectedText: "Alice (5, 6)"},
+		{Input: `dupc "al" "Bob"`, ExpectedText: "Bob (2, 2)"},
+		{Input: `renc "alice" "Bob"`, ExpectedText: "チット「Alice」の名前を「Bob」に変更しました"},
+		// 削除と名前の変更では、名前の先頭だけが一致するチットを選ばない
+		{Input: `delc "al"`, Err: errAny},
+		{Input: `renc "al" "Bob"`, Err: errAny},
+	}
+
+	r := newTestRegistry("")
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "Alice", X: 2, Y: 2})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+			env := &Env{Store: s}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+
+				if _, found := m.FindChit("Alice"); !found {
+					t.Error("Alice was changed")
+				}
+
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+		})
+	}
+}
+
+func TestMapCommands_MoveChitHighlightsMovedChit(t *testing.T) {
+	r := newTestRegistry("")
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s}
+
+	_, res, _ := r.Execute(env, `mvc "A" (5, 6)`)
+	if res.Err != nil {
+		t.Fatalf("got err: %s", res.Err)
+	}
+
+	if res.Image.LastMovedChit != "A" {
+		t.Errorf("LastMovedChit: got %q, want %q", res.Image.LastMovedChit, "A")
+	}
+}
+
+var (
+	// errAny は任意のエラーを期待することを表す。
+	errAny = errors.New("any error")
+	// errUsage は *UsageError を期待することを表す。
+	errUsage = errors.New("usage error")
+)
+
+// assertErr は、エラーが期待されたものかを確かめる。
+func assertErr(t *testing.T, actual error, expected error) {
+	t.Helper()
+
+	if actual == nil {
+		t.Fatal("expected err")
+	}
+
+	switch expected {
+	case errAny:
+		return
+	case errUsage:
+		var usageErr *UsageError
+		if !errors.As(actual, &usageErr) {
+			t.Fatalf("got err: %v, want *UsageError", actual)
+		}
+	default:
+		if !errors.Is(actual, expected) {
+			t.Fatalf("got err: %v, want: %v", actual, expected)
+		}
+	}
+}
+
+func TestMapCommands_InitReplacesMap(t *testing.T) {
+	r := newTestRegistry("")
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s}
+
+	r.Execute(env, "init! 20 x 15")
+
+	m, _ = env.Store.Map()
+	if m.NumOfChits() != 0 {
+		t.Fatalf("NumOfChits: got %d, want %d", m.NumOfChits(), 0)
+	}
+}
+
+func TestMapCommands_NamedMaps(t *testing.T) {
+	r := newTestRegistry("")
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s}
+
+	inputs := []string{
+		"init! battle 20 x 15",
+		`addc "B" (3, 4)`,
+		"init! town 8 x 6",
+		"use battle",
+	}
+	for _, input := range inputs {
+		_, res, _ := r.Execute(env, input)
+		if res.Err != nil {
+			t.Fatalf("%s: got err: %s", input, res.Err)
+		}
+	}
+
+	_, res, _ := r.Execute(env, "lsc")
+	if res.Text != "B (3, 4)" {
+		t.Errorf("lsc: got %q, want %q", res.Text, "B (3, 4)")
+	}
+
+	_, res, _ = r.Execute(env, "maps")
+	expected := "（名前なし） 10 x 10\nbattle 20 x 15（選択中）\ntown 8 x 6"
+	if res.Text != expected {
+		t.Errorf("maps: got %q, want %q", res.Text, expected)
+	}
+
+	// 名前のないマップのチットは残っている
+	r.Execute(env, "use")
+	_, res, _ = r.Execute(env, "lsc")
+	if res.Text != "A (1, 2)" {
+		t.Errorf("lsc: got %q, want %q", res.Text, "A (1, 2)")
+	}
+}
+
+func TestMapCommands_InitWithoutNameReinitializesCurrentMap(t *testing.T) {
+	r := newTestRegistry("")
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s}
+
+	r.Execute(env, "init! battle 20 x 15")
+	_, res, _ := r.Execute(env, "init! 5 x 5")
+	if res.Text != "battle: SquareMap (5 x 5)" {
+		t.Fatalf("got %q", res.Text)
+	}
+
+	defaultMap, _ := env.Store.FindMap(DEFAULT_MAP_NAME)
+	if defaultMap.SizeStr() != "10 x 10" {
+		t.Fatalf("default map: got %s, want %s", defaultMap.SizeStr(), "10 x 10")
+	}
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	COMMAND_SET_INITIATIVE = "ini"
+)
+
+// InitiativeCommands はチットのイニシアチブを操作するコマンドを返す。
+func InitiativeCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_SET_INITIATIVE,
+			ArgsDescription: "\"チット名\" 値",
+			Description:     "チットのイニシアチブを設定します。凡例をイニシアチブ順に並べるときに使います",
+			Handler:         setInitiative,
+		},
+	}
+}
+
+var chitAndInitiativeRe = regexp.MustCompile(`\A"([^"]+)"\s+(-?\d+)\z`)
+
+// setInitiative はチットのイニシアチブを設定する。
+func setInitiative(env *Env, c *Command, argStr string) *Result {
+	matches := chitAndInitiativeRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	initiative, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return usageError(c)
+	}
+
+	name, err := lookupChitName(sMap, matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chit, err := sMap.SetChitInitiative(name, initiative)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("チット「%s」のイニシアチブ: %d", chit.Name, chit.Initiative),
+		Image: env.NewMapImage(sMap),
+	}
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestInitiativeCommands(t *testing.T) {
+	testcases := []struct {
+		Input              string
+		ExpectedText       string
+		ExpectedInitiative int
+		Err                error
+	}{
+		{Input: `ini "A" 15`, ExpectedText: "チット「A」のイニシアチブ: 15", ExpectedInitiative: 15},
+		{Input: `ini "A" -2`, ExpectedText: "チット「A」のイニシアチブ: -2", ExpectedInitiative: -2},
+		{Input: `ini "B" 15`, Err: errAny},
+		{Input: `ini "A"`, Err: errUsage},
+		{Input: `ini "A" high`, Err: errUsage},
+		{Input: `ini "A" 99999999999999999999`, Err: errUsage},
+	}
+
+	r := NewRegistry("")
+	r.Register(InitiativeCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			c, _ := m.FindChit("A")
+			if c.Initiative != test.ExpectedInitiative {
+				t.Errorf("Initiative: got %d, want %d", c.Initiative, test.ExpectedInitiative)
+			}
+		})
+	}
+}
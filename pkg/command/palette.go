@@ -0,0 +1,108 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	COMMAND_PALETTE        = "palette"
+	COMMAND_SET_CHIT_SHAPE = "shape"
+)
+
+// PaletteCommands は、チットの色のパレットと形を操作するコマンドを返す。
+func PaletteCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_PALETTE,
+			ArgsDescription: "[パレット名]",
+			Description: "チットの色のパレットを変更します。" +
+				"パレット名: " + strings.Join(colorutil.PaletteNames, ", ") + "。" +
+				"省略すると現在のパレットを出力します",
+			Handler: setPalette,
+		},
+		{
+			Name:            COMMAND_SET_CHIT_SHAPE,
+			ArgsDescription: `"チット名" 形`,
+			Description: "チットの形を変更します。" +
+				"形: " + strings.Join(rpgmap.ChitShapes, ", "),
+			Handler: setChitShape,
+		},
+	}
+}
+
+var paletteNameRe = regexp.MustCompile(`\A(\S*)\z`)
+
+// setPalette はチットの色のパレットを変更する。
+func setPalette(env *Env, c *Command, argStr string) *Result {
+	matches := paletteNameRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name := matches[1]
+	if name == "" {
+		return &Result{Text: paletteText(sMap)}
+	}
+
+	err = sMap.SetChitPalette(name)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  paletteText(sMap),
+		Image: env.NewMapImage(sMap),
+	}
+}
+
+// paletteText はマップのパレットを表す文字列を返す。
+func paletteText(sMap *rpgmap.SquareMap) string {
+	name, palette := sMap.ChitPalette()
+
+	hexColors := make([]string, 0, len(palette))
+	for i, c := range palette {
+		hexColors = append(hexColors, fmt.Sprintf("%d: %s", i+1, colorutil.RGBAToHex(c)))
+	}
+
+	return fmt.Sprintf("パレット: %s（%s）", name, strings.Join(hexColors, ", "))
+}
+
+var chitAndShapeRe = regexp.MustCompile(`\A"([^"]+)"\s+(\S+)\z`)
+
+// setChitShape はチットの形を変更する。
+func setChitShape(env *Env, c *Command, argStr string) *Result {
+	matches := chitAndShapeRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	name, err := lookupChitName(sMap, matches[1])
+	if err != nil {
+		return errorResult(err)
+	}
+
+	chit, err := sMap.SetChitShape(name, strings.ToLower(matches[2]))
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:  fmt.Sprintf("チット「%s」の形: %s", chit.Name, chit.Shape),
+		Image: env.NewMapImage(sMap),
+	}
+}
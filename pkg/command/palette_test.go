@@ -0,0 +1,64 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestPaletteCommands(t *testing.T) {
+	testcases := []struct {
+		Input        string
+		ExpectedText string
+		Image        bool
+		Err          error
+	}{
+		{Input: "palette", ExpectedText: "パレット: default（1: #ff1493, "},
+		{Input: "palette okabe-ito", ExpectedText: "パレット: okabe-ito（1: #e69f00, ", Image: true},
+		{Input: "palette rainbow", Err: errAny},
+		{Input: "palette a b", Err: errUsage},
+		{Input: `shape "A" triangle`, ExpectedText: "チット「A」の形: triangle", Image: true},
+		{Input: `shape "A" Diamond`, ExpectedText: "チット「A」の形: diamond", Image: true},
+		{Input: `shape "A" star`, Err: errAny},
+		{Input: `shape "B" square`, Err: errAny},
+		{Input: `shape "A"`, Err: errUsage},
+	}
+
+	r := NewRegistry("")
+	r.Register(PaletteCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if !strings.HasPrefix(res.Text, test.ExpectedText) {
+				t.Errorf("Text: got %q, want prefix %q", res.Text, test.ExpectedText)
+			}
+
+			if (res.Image != nil) != test.Image {
+				t.Errorf("Image: got %t, want %t", res.Image != nil, test.Image)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	COMMAND_NEXT_ROUND = "round"
+	COMMAND_REPLAY     = "replay"
+
+	// MAX_REPLAY_MOVES は再生する移動の最大数。
+	MAX_REPLAY_MOVES = 20
+)
+
+// ReplayCommands はチットの移動を再生するコマンドを返す。
+func ReplayCommands() []Command {
+	return []Command{
+		{
+			Name:        COMMAND_NEXT_ROUND,
+			Description: "次のラウンドに進みます",
+			Handler:     nextRound,
+		},
+		{
+			Name:            COMMAND_REPLAY,
+			ArgsDescription: "[移動数]",
+			Description: fmt.Sprintf(
+				"チットの移動をアニメーションGIFで再生します。省略すると現在のラウンド（移動がなければ前のラウンド）の移動を再生します（最大%d件）",
+				MAX_REPLAY_MOVES,
+			),
+			Handler: replayMoves,
+		},
+	}
+}
+
+// nextRound は次のラウンドに進む。
+func nextRound(env *Env, _ *Command, _ string) *Result {
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	round := sMap.NextRound()
+
+	return &Result{Text: fmt.Sprintf("ラウンド%dを開始しました", round)}
+}
+
+var replayRe = regexp.MustCompile(`\A(\d+)?\z`)
+
+// replayMoves はチットの移動を再生するアニメーションを返す。
+func replayMoves(env *Env, c *Command, argStr string) *Result {
+	matches := replayRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	var moves []rpgmap.Move
+	var text string
+	if matches[1] == "" {
+		round := sMap.Round()
+		moves = sMap.RoundMoves(round)
+		if len(moves) < 1 && round > 1 {
+			round--
+			moves = sMap.RoundMoves(round)
+		}
+
+		if len(moves) > MAX_REPLAY_MOVES {
+			moves = moves[len(moves)-MAX_REPLAY_MOVES:]
+		}
+
+		text = fmt.Sprintf("ラウンド%dの移動", round)
+	} else {
+		n, _ := strconv.Atoi(matches[1])
+		if n < 1 || n > MAX_REPLAY_MOVES {
+			return errorResult(fmt.Errorf("number of moves out of range (1-%d): %d", MAX_REPLAY_MOVES, n))
+		}
+
+		moves = sMap.LastMoves(n)
+		text = fmt.Sprintf("最近の%d件の移動", len(moves))
+	}
+
+	g, err := env.NewMapImage(sMap).RenderReplay(moves)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	return &Result{
+		Text:      text,
+		Animation: g,
+	}
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestReplayCommands(t *testing.T) {
+	testcases := []struct {
+		Input         string
+		Setup         []string
+		ExpectedText  string
+		ExpectedMoves int
+		Err           error
+	}{
+		{Input: "round", ExpectedText: "ラウンド2を開始しました"},
+		{Input: "replay", Setup: []string{`mvc "A" (2, 2)`}, ExpectedText: "ラウンド1の移動", ExpectedMoves: 1},
+		{Input: "replay", Setup: []string{`mvc "A" (2, 2)`, "round"}, ExpectedText: "ラウンド1の移動", ExpectedMoves: 1},
+		{
+			Input:         "replay",
+			Setup:         []string{`mvc "A" (2, 2)`, "round", `mvc "A" (3, 3)`, `mvc "A" (4, 4)`},
+			ExpectedText:  "ラウンド2の移動",
+			ExpectedMoves: 2,
+		},
+		{
+			Input:         "replay 3",
+			Setup:         []string{`mvc "A" (2, 2)`, "round", `mvc "A" (3, 3)`},
+			ExpectedText:  "最近の2件の移動",
+			ExpectedMoves: 2,
+		},
+		{Input: "replay", Err: errAny},
+		{Input: "replay 0", Err: errAny},
+		{Input: "replay 100", Err: errAny},
+		{Input: "replay all", Err: errUsage},
+	}
+
+	fc := mapgen.NewFontCache()
+	if err := fc.StoreFontData(goregular.TTF); err != nil {
+		t.Fatalf("failed to load font: %s", err)
+	}
+
+	r := NewRegistry("")
+	r.Register(MapCommands()...)
+	r.Register(ReplayCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s, FontCache: fc}
+			for _, input := range test.Setup {
+				r.Execute(env, input)
+			}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			if test.ExpectedMoves == 0 {
+				if res.Animation != nil {
+					t.Error("Animation is set")
+				}
+
+				return
+			}
+
+			if res.Animation == nil {
+				t.Fatal("Animation is not set")
+			}
+
+			// 最初のフレームと、各移動のフレーム
+			expectedLen := 1 + test.ExpectedMoves*mapgen.REPLAY_FRAMES_PER_MOVE
+			if len(res.Animation.Image) != expectedLen {
+				t.Errorf("len(Image): got %d, want %d", len(res.Animation.Image), expectedLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	// DEFAULT_MAP_NAME は名前を指定しなかった場合のマップ名。
+	DEFAULT_MAP_NAME = ""
+)
+
+// stringSquareMapMap は、マップ名 -> スクエアマップの対応の型。
+type stringSquareMapMap map[string]*rpgmap.SquareMap
+
+// MapStore はコマンドが操作するマップの格納先。
+//
+// 名前を付けて複数のマップを格納することができ、
+// そのうちの1つが選択中のマップとしてコマンドの操作対象となる。
+// 複数のゴルーチンから同時に使用することができる。
+type MapStore struct {
+	// nameToMap はマップ名 -> スクエアマップの対応。
+	nameToMap stringSquareMapMap
+	// currentName は選択中のマップの名前。
+	currentName string
+	// theme はマップの描画に使うテーマ。設定されていなければ nil。
+	theme *mapgen.Theme
+	// mux は排他制御用の読み書きミューテックス。
+	mux sync.RWMutex
+}
+
+// NewMapStore は新しいマップの格納先を返す。
+func NewMapStore() *MapStore {
+	return &MapStore{
+		nameToMap:   stringSquareMapMap{},
+		currentName: DEFAULT_MAP_NAME,
+	}
+}
+
+// Map は選択中のマップを返す。
+func (s *MapStore) Map() (*rpgmap.SquareMap, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	m, found := s.nameToMap[s.currentName]
+	return m, found
+}
+
+// CurrentName は選択中のマップの名前を返す。
+func (s *MapStore) CurrentName() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.currentName
+}
+
+// FindMap は名前からマップを検索する。
+func (s *MapStore) FindMap(name string) (*rpgmap.SquareMap, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	m, found := s.nameToMap[name]
+	return m, found
+}
+
+// SetMap は名前を付けてマップを格納し、選択する。
+//
+// 同じ名前のマップが既に格納されていた場合は置き換える。
+func (s *MapStore) SetMap(name string, m *rpgmap.SquareMap) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.nameToMap[name] = m
+	s.currentName = name
+}
+
+// Use は指定された名前のマップを選択する。
+func (s *MapStore) Use(name string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, found := s.nameToMap[name]; !found {
+		return fmt.Errorf("map not found: %s", MapLabel(name))
+	}
+
+	s.currentName = name
+
+	return nil
+}
+
+// DeleteMap は指定された名前のマップを削除する。
+//
+// 選択中のマップを削除した場合は、名前のないマップを選択する。
+// マップが格納されていた場合は true を返す。
+func (s *MapStore) DeleteMap(name string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	_, found := s.nameToMap[name]
+	if !found {
+		return false
+	}
+
+	delete(s.nameToMap, name)
+	if s.currentName == name {
+		s.currentName = DEFAULT_MAP_NAME
+	}
+
+	return true
+}
+
+// Names は格納されているマップの名前を昇順に並べて返す。
+//
+// 名前のないマップが格納されている場合は、それが先頭になる。
+func (s *MapStore) Names() []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	names := make([]string, 0, len(s.nameToMap))
+	for name := range s.nameToMap {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Theme は設定されているテーマの複製を返す。
+//
+// テーマが設定されていなければ、false を返す。
+func (s *MapStore) Theme() (*mapgen.Theme, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if s.theme == nil {
+		return nil, false
+	}
+
+	return s.theme.Clone(), true
+}
+
+// SetTheme はマップの描画に使うテーマを設定する。
+//
+// t が nil の場合はテーマの設定を解除する。
+func (s *MapStore) SetTheme(t *mapgen.Theme) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if t == nil {
+		s.theme = nil
+		return
+	}
+
+	s.theme = t.Clone()
+}
+
+// MapLabel は出力用のマップ名を返す。
+func MapLabel(name string) string {
+	if name == DEFAULT_MAP_NAME {
+		return "（名前なし）"
+	}
+
+	return name
+}
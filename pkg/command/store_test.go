@@ -0,0 +1,87 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestMapStore_SetMap_SelectsMap(t *testing.T) {
+	s := NewMapStore()
+	m1, _ := rpgmap.NewSquareMap(10, 10)
+	m2, _ := rpgmap.NewSquareMap(20, 20)
+
+	s.SetMap(DEFAULT_MAP_NAME, m1)
+	s.SetMap("battle", m2)
+
+	if s.CurrentName() != "battle" {
+		t.Fatalf("CurrentName: got %q, want %q", s.CurrentName(), "battle")
+	}
+
+	actual, _ := s.Map()
+	if actual != m2 {
+		t.Fatal("Map: selected map is not returned")
+	}
+}
+
+func TestMapStore_Use(t *testing.T) {
+	s := NewMapStore()
+	m1, _ := rpgmap.NewSquareMap(10, 10)
+	m2, _ := rpgmap.NewSquareMap(20, 20)
+
+	s.SetMap(DEFAULT_MAP_NAME, m1)
+	s.SetMap("battle", m2)
+
+	if err := s.Use(DEFAULT_MAP_NAME); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	actual, _ := s.Map()
+	if actual != m1 {
+		t.Fatal("Map: selected map is not returned")
+	}
+
+	if err := s.Use("town"); err == nil {
+		t.Fatal("expected err")
+	}
+
+	if s.CurrentName() != DEFAULT_MAP_NAME {
+		t.Fatalf("CurrentName: got %q, want %q", s.CurrentName(), DEFAULT_MAP_NAME)
+	}
+}
+
+func TestMapStore_DeleteMap_SelectsDefaultMapWhenCurrentMapIsDeleted(t *testing.T) {
+	s := NewMapStore()
+	m1, _ := rpgmap.NewSquareMap(10, 10)
+	m2, _ := rpgmap.NewSquareMap(20, 20)
+
+	s.SetMap(DEFAULT_MAP_NAME, m1)
+	s.SetMap("battle", m2)
+
+	if !s.DeleteMap("battle") {
+		t.Fatal("DeleteMap returned false")
+	}
+
+	if s.CurrentName() != DEFAULT_MAP_NAME {
+		t.Fatalf("CurrentName: got %q, want %q", s.CurrentName(), DEFAULT_MAP_NAME)
+	}
+
+	if s.DeleteMap("battle") {
+		t.Fatal("DeleteMap returned true for a deleted map")
+	}
+}
+
+func TestMapStore_Names(t *testing.T) {
+	s := NewMapStore()
+	for _, name := range []string{"town", DEFAULT_MAP_NAME, "battle"} {
+		m, _ := rpgmap.NewSquareMap(10, 10)
+		s.SetMap(name, m)
+	}
+
+	expected := []string{DEFAULT_MAP_NAME, "battle", "town"}
+	actual := s.Names()
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got: %q, want: %q", actual, expected)
+	}
+}
@@ -0,0 +1,102 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+)
+
+const (
+	COMMAND_THEME = "theme"
+
+	// THEME_RESET はテーマの設定を解除する引数。
+	THEME_RESET = "reset"
+)
+
+// ThemeCommands は描画のテーマを操作するコマンドを返す。
+func ThemeCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_THEME,
+			ArgsDescription: "[テーマ名|reset / 項目 値]",
+			Description: "マップの描画のテーマを設定します。" +
+				"テーマ名: " + strings.Join(mapgen.ThemePresetNames(), ", ") + "、" +
+				"項目: " + strings.Join(mapgen.ThemeKeys, ", ") + "。" +
+				"省略すると現在の設定を出力します",
+			Handler: setTheme,
+		},
+	}
+}
+
+var (
+	themeNameRe = regexp.MustCompile(`\A(\S+)\z`)
+	themeKeyRe  = regexp.MustCompile(`\A(\S+)\s+(\S+)\z`)
+)
+
+// setTheme はマップの描画のテーマを設定する。
+func setTheme(env *Env, c *Command, argStr string) *Result {
+	if argStr == "" {
+		return &Result{Text: themeText(env.theme())}
+	}
+
+	if matches := themeNameRe.FindStringSubmatch(argStr); matches != nil {
+		name := matches[1]
+		if name == THEME_RESET {
+			env.Store.SetTheme(nil)
+			return env.themeResult("テーマの設定を解除しました")
+		}
+
+		t, found := mapgen.ThemePreset(name)
+		if !found {
+			return errorResult(fmt.Errorf("unknown theme: %s", name))
+		}
+
+		env.Store.SetTheme(t)
+		return env.themeResult(fmt.Sprintf("テーマを「%s」にしました", name))
+	}
+
+	matches := themeKeyRe.FindStringSubmatch(argStr)
+	if matches == nil {
+		return usageError(c)
+	}
+
+	key := matches[1]
+	value := matches[2]
+
+	t := env.theme()
+	err := t.Set(key, value)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	env.Store.SetTheme(t)
+
+	formatted, _ := t.Get(key)
+	return env.themeResult(fmt.Sprintf("テーマ: %s = %s", key, formatted))
+}
+
+// themeResult は、テーマの変更後の結果を返す。
+//
+// マップが作成されていれば、新しいテーマで描画した画像を付ける。
+func (env *Env) themeResult(text string) *Result {
+	res := &Result{Text: text}
+
+	if m, found := env.Store.Map(); found {
+		res.Image = env.NewMapImage(m)
+	}
+
+	return res
+}
+
+// themeText はテーマの設定を表す文字列を返す。
+func themeText(t *mapgen.Theme) string {
+	lines := make([]string, 0, len(mapgen.ThemeKeys))
+	for _, key := range mapgen.ThemeKeys {
+		value, _ := t.Get(key)
+		lines = append(lines, key+" = "+value)
+	}
+
+	return strings.Join(lines, "\n")
+}
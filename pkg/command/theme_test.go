@@ -0,0 +1,122 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/mapgen"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestThemeCommands(t *testing.T) {
+	testcases := []struct {
+		Input        string
+		Setup        []string
+		ExpectedText string
+		ExpectedCell int
+		Err          error
+	}{
+		{Input: "theme dark", ExpectedText: "テーマを「dark」にしました", ExpectedCell: 32},
+		{Input: "theme cell 48", ExpectedText: "テーマ: cell = 48", ExpectedCell: 48},
+		{Input: "theme grid DodgerBlue", ExpectedText: "テーマ: grid = #1e90ff", ExpectedCell: 24},
+		{Input: "theme reset", Setup: []string{"theme cell 48"}, ExpectedText: "テーマの設定を解除しました", ExpectedCell: 24},
+		{Input: "theme cell 48", Setup: []string{"theme dark"}, ExpectedText: "テーマ: cell = 48", ExpectedCell: 48},
+		{Input: "theme sepia", Err: errAny},
+		{Input: "theme cell 1", Err: errAny},
+		{Input: "theme size 1", Err: errAny},
+		{Input: "theme cell 1 2", Err: errUsage},
+	}
+
+	r := NewRegistry("")
+	r.Register(ThemeCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+
+			// 既定のテーマ
+			env.Theme = mapgen.DefaultTheme()
+			env.Theme.CellSize = 24
+
+			for _, input := range test.Setup {
+				r.Execute(env, input)
+			}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			if res.Image == nil {
+				t.Fatal("Image is not set")
+			}
+
+			if res.Image.GridWidth != test.ExpectedCell {
+				t.Errorf("GridWidth: got %d, want %d", res.Image.GridWidth, test.ExpectedCell)
+			}
+		})
+	}
+}
+
+func TestThemeCommands_Show(t *testing.T) {
+	r := NewRegistry("")
+	r.Register(ThemeCommands()...)
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+
+	s := NewMapStore()
+	s.SetMap(DEFAULT_MAP_NAME, m)
+
+	env := &Env{Store: s}
+	r.Execute(env, "theme background black")
+
+	_, res, _ := r.Execute(env, "theme")
+	if res.Err != nil {
+		t.Fatalf("got err: %s", res.Err)
+	}
+
+	if !strings.Contains(res.Text, "background = #000000\n") {
+		t.Errorf("got: %q", res.Text)
+	}
+
+	if res.Image != nil {
+		t.Error("Image is set")
+	}
+}
+
+func TestThemeCommands_IsolatedPerStore(t *testing.T) {
+	r := NewRegistry("")
+	r.Register(ThemeCommands()...)
+
+	env1 := &Env{Store: NewMapStore()}
+	env2 := &Env{Store: NewMapStore()}
+
+	if _, res, _ := r.Execute(env1, "theme cell 64"); res.Err != nil {
+		t.Fatalf("got err: %s", res.Err)
+	}
+
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	if env2.NewMapImage(m).GridWidth != 32 {
+		t.Error("theme is shared between stores")
+	}
+}
@@ -0,0 +1,128 @@
+package command
+
+import (
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	COMMAND_VIEW = "view"
+
+	// DEFAULT_VIEW_PADDING は、チットに合わせて表示する場合の既定の余白（マス）。
+	DEFAULT_VIEW_PADDING = 2
+	// MAX_VIEW_PADDING は、チットに合わせて表示する場合の余白（マス）の最大値。
+	MAX_VIEW_PADDING = 20
+	// MIN_VIEW_SCALE は表示の拡大率の最小値。
+	MIN_VIEW_SCALE = 0.25
+	// MAX_VIEW_SCALE は表示の拡大率の最大値。
+	MAX_VIEW_SCALE = 4.0
+)
+
+// ViewCommands はマップの一部を表示するコマンドを返す。
+func ViewCommands() []Command {
+	return []Command{
+		{
+			Name:            COMMAND_VIEW,
+			ArgsDescription: "[(x1, y1)-(x2, y2) / \"チット名\" ... [+余白]] [x倍率]",
+			Description:     "マップの指定した範囲またはチットの周囲を拡大・縮小して表示します",
+			Handler:         showView,
+		},
+	}
+}
+
+var (
+	viewRe = regexp.MustCompile(
+		`\A(?:\((\d+),\s*(\d+)\)\s*-\s*\((\d+),\s*(\d+)\)|((?:"[^"]+"\s*)+)(?:\+(-?\d+))?)?\s*(?:x(\d+(?:\.\d+)?|\.\d+))?\z`)
+	quotedNameRe = regexp.MustCompile(`"([^"]+)"`)
+)
+
+// showView はマップの指定した範囲を表示する。
+func showView(env *Env, c *Command, argStr string) *Result {
+	matches := viewRe.FindStringSubmatch(argStr)
+	if argStr == "" || matches == nil {
+		return usageError(c)
+	}
+
+	sMap, err := env.currentMap()
+	if err != nil {
+		return errorResult(err)
+	}
+
+	mImg := env.NewMapImage(sMap)
+
+	switch {
+	case matches[1] != "":
+		var coords [4]int
+		for i := range coords {
+			coords[i], err = strconv.Atoi(matches[i+1])
+			if err != nil {
+				return usageError(c)
+			}
+		}
+
+		x1, y1, x2, y2 := coords[0], coords[1], coords[2], coords[3]
+
+		r := image.Rect(x1-1, y1-1, x2-1, y2-1).Canon()
+		err = mImg.SetView(image.Rect(r.Min.X, r.Min.Y, r.Max.X+1, r.Max.Y+1))
+	case matches[5] != "":
+		chits := []*rpgmap.Chit{}
+		for _, m := range quotedNameRe.FindAllStringSubmatch(matches[5], -1) {
+			chit, err := sMap.LookupChit(m[1])
+			if err != nil {
+				return errorResult(err)
+			}
+
+			chits = append(chits, chit)
+		}
+
+		padding := DEFAULT_VIEW_PADDING
+		if matches[6] != "" {
+			padding, err = strconv.Atoi(matches[6])
+			if err != nil {
+				return usageError(c)
+			}
+
+			if padding < 0 || padding > MAX_VIEW_PADDING {
+				return errorResult(fmt.Errorf("padding out of range (0-%d): %d", MAX_VIEW_PADDING, padding))
+			}
+		}
+
+		err = mImg.FitChits(chits, padding)
+	}
+
+	if err != nil {
+		return errorResult(err)
+	}
+
+	if matches[7] != "" {
+		s, _ := strconv.ParseFloat(matches[7], 64)
+		if s < MIN_VIEW_SCALE || s > MAX_VIEW_SCALE {
+			return errorResult(fmt.Errorf("scale out of range (%g-%g): %g", MIN_VIEW_SCALE, MAX_VIEW_SCALE, s))
+		}
+
+		mImg.Scale = s
+	}
+
+	return &Result{
+		Text:  viewText(mImg.View, mImg.Scale, sMap),
+		Image: mImg,
+	}
+}
+
+// viewText は表示範囲を表す文字列を返す。
+func viewText(view image.Rectangle, scale float64, m *rpgmap.SquareMap) string {
+	if view.Empty() {
+		view = image.Rect(0, 0, m.Width(), m.Height())
+	}
+
+	text := fmt.Sprintf("表示範囲: (%d, %d)-(%d, %d)", view.Min.X+1, view.Min.Y+1, view.Max.X, view.Max.Y)
+	if scale > 0 && scale != 1.0 {
+		text += fmt.Sprintf("（x%g）", scale)
+	}
+
+	return text
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"image"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestViewCommands(t *testing.T) {
+	testcases := []struct {
+		Input         string
+		ExpectedText  string
+		ExpectedView  image.Rectangle
+		ExpectedScale float64
+		Err           error
+	}{
+		{Input: "view (2, 3)-(5, 6)", ExpectedText: "表示範囲: (2, 3)-(5, 6)", ExpectedView: image.Rect(1, 2, 5, 6)},
+		{Input: "view (5, 6)-(2, 3) x2", ExpectedText: "表示範囲: (2, 3)-(5, 6)（x2）", ExpectedView: image.Rect(1, 2, 5, 6), ExpectedScale: 2},
+		{Input: "view (8, 8)-(12, 12)", ExpectedText: "表示範囲: (8, 8)-(10, 10)", ExpectedView: image.Rect(7, 7, 10, 10)},
+		{Input: `view "A"`, ExpectedText: "表示範囲: (1, 1)-(3, 4)", ExpectedView: image.Rect(0, 0, 3, 4)},
+		{Input: `view "A" "B" +1`, ExpectedText: "表示範囲: (1, 1)-(7, 6)", ExpectedView: image.Rect(0, 0, 7, 6)},
+		{Input: `view "A" +0 x1.5`, ExpectedText: "表示範囲: (1, 2)-(1, 2)（x1.5）", ExpectedView: image.Rect(0, 1, 1, 2), ExpectedScale: 1.5},
+		{Input: "view x0.5", ExpectedText: "表示範囲: (1, 1)-(10, 10)（x0.5）", ExpectedScale: 0.5},
+		{Input: "view", Err: errUsage},
+		{Input: "view (1, 1)", Err: errUsage},
+		{Input: "view (1, 1)-(2, 2) +1", Err: errUsage},
+		{Input: `view "A" +99999999999999999999`, Err: errUsage},
+		{Input: `view "A" "B" x`, Err: errUsage},
+		{Input: "view (1, 1)-(99999999999999999999, 2)", Err: errUsage},
+		{Input: `view "A" +20`, ExpectedText: "表示範囲: (1, 1)-(10, 10)", ExpectedView: image.Rect(0, 0, 10, 10)},
+		{Input: `view "A" +-1`, Err: errAny},
+		{Input: `view "A" +21`, Err: errAny},
+		{Input: `view "C"`, Err: errAny},
+		{Input: "view (11, 11)-(12, 12)", Err: errAny},
+		{Input: "view x8", Err: errAny},
+	}
+
+	r := NewRegistry("")
+	r.Register(ViewCommands()...)
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 1})
+			m.AddChit(&rpgmap.Chit{Name: "B", X: 5, Y: 4})
+
+			s := NewMapStore()
+			s.SetMap(DEFAULT_MAP_NAME, m)
+
+			env := &Env{Store: s}
+
+			_, res, err := r.Execute(env, test.Input)
+			if err != nil {
+				t.Fatalf("parse err: %s", err)
+			}
+
+			if test.Err != nil {
+				assertErr(t, res.Err, test.Err)
+				return
+			}
+
+			if res.Err != nil {
+				t.Fatalf("got err: %s", res.Err)
+			}
+
+			if res.Text != test.ExpectedText {
+				t.Errorf("Text: got %q, want %q", res.Text, test.ExpectedText)
+			}
+
+			if res.Image.View != test.ExpectedView {
+				t.Errorf("View: got %v, want %v", res.Image.View, test.ExpectedView)
+			}
+
+			if res.Image.Scale != test.ExpectedScale {
+				t.Errorf("Scale: got %g, want %g", res.Image.Scale, test.ExpectedScale)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package mapgen
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/llgcode/draw2d"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// drawBackgroundImage はgcにマップの背景画像を描画する。
+//
+// 背景が設定されていなければ何もしない。
+func (i *SquareMapImage) drawBackgroundImage(gc draw2d.GraphicContext) {
+	bg, found := i.Map.Background()
+	if !found {
+		return
+	}
+
+	sx, sy := i.backgroundScale(bg)
+
+	gc.Save()
+	defer gc.Restore()
+
+	if !bg.Fit {
+		// マップの移動に合わせて背景画像を移動する
+		gc.Translate(float64(bg.ShiftX*i.GridWidth), float64(bg.ShiftY*i.GridHeight))
+	}
+
+	// 背景画像上の (OffsetX, OffsetY) がマップの左上に来るように配置する
+	gc.Translate(-float64(bg.OffsetX)*sx, -float64(bg.OffsetY)*sy)
+	gc.Scale(sx, sy)
+	gc.DrawImage(withOpacity(bg.Image, bg.Opacity))
+}
+
+// backgroundScale は背景画像の拡大率を返す。
+func (i *SquareMapImage) backgroundScale(bg *rpgmap.Background) (float64, float64) {
+	if bg.Fit {
+		size := bg.Image.Bounds().Size()
+		w := size.X - bg.OffsetX
+		h := size.Y - bg.OffsetY
+		if w < 1 || h < 1 {
+			return 1.0, 1.0
+		}
+
+		return float64(i.Width()) / float64(w), float64(i.Height()) / float64(h)
+	}
+
+	if bg.CellSize == 0 {
+		return 1.0, 1.0
+	}
+
+	return float64(i.GridWidth) / float64(bg.CellSize), float64(i.GridHeight) / float64(bg.CellSize)
+}
+
+// withOpacity は、画像に不透明度を適用したものを返す。
+func withOpacity(img image.Image, opacity float64) image.Image {
+	if opacity >= 1.0 {
+		return img
+	}
+
+	dest := image.NewRGBA(img.Bounds())
+	mask := image.NewUniform(color.Alpha{uint8(opacity * 0xFF)})
+	draw.DrawMask(dest, dest.Bounds(), img, img.Bounds().Min, mask, image.ZP, draw.Src)
+
+	return dest
+}
@@ -0,0 +1,93 @@
+package mapgen
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// newCheckerImage は、左上の1マス分が赤、それ以外が青の画像を返す。
+func newCheckerImage(w int, h int, cellSize int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0, 0, 0xFF, 0xFF}), image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, cellSize, cellSize), image.NewUniform(color.RGBA{0xFF, 0, 0, 0xFF}), image.ZP, draw.Src)
+
+	return img
+}
+
+func TestSquareMapImage_Render_Background(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	blue := color.RGBA{0, 0, 0xFF, 0xFF}
+
+	testcases := []struct {
+		Name       string
+		Background rpgmap.Background
+		// Point は確認する画素の位置。
+		Point image.Point
+		// expected は期待する色。
+		expected color.RGBA
+	}{
+		{
+			Name:       "original size",
+			Background: rpgmap.Background{Image: newCheckerImage(128, 128, 32), Opacity: 1.0},
+			Point:      image.Pt(16, 16),
+			expected:   red,
+		},
+		{
+			Name:       "CellSize",
+			Background: rpgmap.Background{Image: newCheckerImage(64, 64, 16), CellSize: 16, Opacity: 1.0},
+			Point:      image.Pt(16, 16),
+			expected:   red,
+		},
+		{
+			Name:       "CellSize (next cell)",
+			Background: rpgmap.Background{Image: newCheckerImage(64, 64, 16), CellSize: 16, Opacity: 1.0},
+			Point:      image.Pt(48, 48),
+			expected:   blue,
+		},
+		{
+			Name:       "Fit",
+			Background: rpgmap.Background{Image: newCheckerImage(40, 40, 10), Fit: true, Opacity: 1.0},
+			Point:      image.Pt(16, 16),
+			expected:   red,
+		},
+		{
+			Name: "offset",
+			Background: rpgmap.Background{
+				Image:   newCheckerImage(128, 128, 32),
+				OffsetX: 32,
+				Opacity: 1.0,
+			},
+			Point:    image.Pt(16, 16),
+			expected: blue,
+		},
+		{
+			Name:       "opacity",
+			Background: rpgmap.Background{Image: newCheckerImage(128, 128, 32), Opacity: 0.0},
+			Point:      image.Pt(16, 16),
+			expected:   color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(4, 4)
+			if err := m.SetBackground(&test.Background); err != nil {
+				t.Fatalf("SetBackground: %s", err)
+			}
+
+			img, err := NewSquareMapImage(m, newTestFontCache(t)).Render()
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			actual := img.RGBAAt(test.Point.X, test.Point.Y)
+			if actual != test.expected {
+				t.Fatalf("got: %v, want: %v", actual, test.expected)
+			}
+		})
+	}
+}
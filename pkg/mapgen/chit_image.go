@@ -0,0 +1,123 @@
+package mapgen
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dkit"
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	// chitBorderRatio は、チット画像の縁取りの太さの半径に対する比率。
+	chitBorderRatio = 0.2
+)
+
+// drawChitMarker は、gcの中心 (x, y)、半径 r の円に収まるようにチットを描画する。
+//
+// チットに画像が設定されていれば、円形に切り抜いた画像をチットの色で縁取って描画する。
+// 縁取りの太さ border が0の場合は、半径から決める。
+// 画像が設定されていなければ、チットの色で塗りつぶしたチットの形の図形を描画する。
+func drawChitMarker(gc draw2d.GraphicContext, chit *rpgmap.Chit, x float64, y float64, r float64, border float64) {
+	if chit.Image == nil || r < 1.0 {
+		gc.SetFillColor(chit.Color)
+		chitShapePath(gc, chit.Shape, x, y, r)
+		gc.Fill()
+		return
+	}
+
+	if border <= 0 {
+		border = r * chitBorderRatio
+	}
+	border = math.Min(border, r)
+	d := int(math.Ceil(2 * r))
+
+	gc.Save()
+	gc.Translate(x-float64(d)/2.0, y-float64(d)/2.0)
+	gc.DrawImage(circleClippedImage(chit.Image, d))
+	gc.Restore()
+
+	gc.SetStrokeColor(chit.Color)
+	gc.SetLineWidth(border)
+	draw2dkit.Circle(gc, x, y, r-border/2.0)
+	gc.Stroke()
+}
+
+// chitShapePath は、gcに中心 (x, y)、半径 r の円に収まるチットの形の経路を作る。
+//
+// 形が空または不明な場合は円とする。
+func chitShapePath(gc draw2d.GraphicContext, shape string, x float64, y float64, r float64) {
+	switch shape {
+	case rpgmap.SHAPE_SQUARE:
+		// 円と同程度の大きさに見えるように、円に内接する正方形より大きくする
+		h := r * 0.85
+		draw2dkit.Rectangle(gc, x-h, y-h, x+h, y+h)
+	case rpgmap.SHAPE_TRIANGLE:
+		// 外接円の半径を r とし、上下の中央が y になるように下にずらす
+		dx := r * math.Sqrt(3) / 2.0
+		dy := r / 4.0
+		gc.MoveTo(x, y-r+dy)
+		gc.LineTo(x+dx, y+r/2.0+dy)
+		gc.LineTo(x-dx, y+r/2.0+dy)
+		gc.Close()
+	case rpgmap.SHAPE_DIAMOND:
+		gc.MoveTo(x, y-r)
+		gc.LineTo(x+r, y)
+		gc.LineTo(x, y+r)
+		gc.LineTo(x-r, y)
+		gc.Close()
+	default:
+		draw2dkit.Circle(gc, x, y, r)
+	}
+}
+
+// circleClippedImage は、画像を d x d に縮小し、円形に切り抜いたものを返す。
+//
+// 画像が正方形でない場合は、中央の正方形の部分を使用する。
+func circleClippedImage(src image.Image, d int) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	srcRect := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+
+	scaled := image.NewRGBA(image.Rect(0, 0, d, d))
+	xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), src, srcRect, xdraw.Src, nil)
+
+	dest := image.NewRGBA(scaled.Bounds())
+	draw.DrawMask(dest, dest.Bounds(), scaled, image.ZP, &circleMask{d: d}, image.ZP, draw.Src)
+
+	return dest
+}
+
+// circleMask は、d x d の正方形に内接する円の内側のみを不透明とするマスク。
+type circleMask struct {
+	// d は円の直径。
+	d int
+}
+
+func (m *circleMask) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (m *circleMask) Bounds() image.Rectangle {
+	return image.Rect(0, 0, m.d, m.d)
+}
+
+func (m *circleMask) At(x, y int) color.Color {
+	r := float64(m.d) / 2.0
+	dx := float64(x) + 0.5 - r
+	dy := float64(y) + 0.5 - r
+	if dx*dx+dy*dy <= r*r {
+		return color.Alpha{0xFF}
+	}
+
+	return color.Alpha{0}
+}
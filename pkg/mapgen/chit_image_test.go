@@ -0,0 +1,115 @@
+package mapgen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapImage_Render_ChitImage(t *testing.T) {
+	green := color.RGBA{0, 0xFF, 0, 0xFF}
+	avatar := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(avatar, avatar.Bounds(), image.NewUniform(green), image.ZP, draw.Src)
+
+	m, _ := rpgmap.NewSquareMap(4, 4)
+	m.AddChit(&rpgmap.Chit{
+		Name:  "A",
+		X:     1,
+		Y:     1,
+		Color: colorutil.CSS3NameToRGBA("red"),
+		Image: avatar,
+	})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	mImg.GridWidth = 64
+	mImg.GridHeight = 64
+	mImg.updateRect()
+
+	img, err := mImg.Render()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	// チットの中心には画像が描画される
+	center := img.RGBAAt(64+32, 64+32)
+	if center != green {
+		t.Errorf("center: got %v, want %v", center, green)
+	}
+
+	// チットの縁はチットの色で描画される
+	edge := img.RGBAAt(64+32+14, 64+32)
+	if edge != colorutil.CSS3NameToRGBA("red") {
+		t.Errorf("edge: got %v, want red", edge)
+	}
+
+	// 円の外側の角には画像が描画されない
+	corner := img.RGBAAt(64+17, 64+17)
+	if corner == green {
+		t.Error("corner is not clipped")
+	}
+}
+
+func TestCircleClippedImage_CropsCenterOfNonSquareImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	draw.Draw(src, image.Rect(10, 0, 20, 10), image.NewUniform(color.RGBA{0xFF, 0, 0, 0xFF}), image.ZP, draw.Src)
+
+	clipped := circleClippedImage(src, 10)
+	if clipped.Bounds().Size() != image.Pt(10, 10) {
+		t.Fatalf("size: got %v", clipped.Bounds().Size())
+	}
+
+	if clipped.RGBAAt(5, 5) != (color.RGBA{0xFF, 0, 0, 0xFF}) {
+		t.Fatalf("center: got %v", clipped.RGBAAt(5, 5))
+	}
+
+	if clipped.RGBAAt(0, 0).A != 0 {
+		t.Fatalf("corner: got %v", clipped.RGBAAt(0, 0))
+	}
+}
+
+func TestSquareMapImage_Render_ChitShape(t *testing.T) {
+	red := colorutil.CSS3NameToRGBA("red")
+
+	// チットの中心は (96, 96)、半径は16
+	testcases := []struct {
+		Shape  string
+		X      int
+		Y      int
+		Filled bool
+	}{
+		{Shape: "", X: 96 - 10, Y: 96 - 10, Filled: true},
+		{Shape: "", X: 96 - 13, Y: 96 - 13, Filled: false},
+		{Shape: rpgmap.SHAPE_SQUARE, X: 96 - 13, Y: 96 - 13, Filled: true},
+		{Shape: rpgmap.SHAPE_TRIANGLE, X: 96, Y: 96 - 10, Filled: true},
+		{Shape: rpgmap.SHAPE_TRIANGLE, X: 96 - 12, Y: 96 - 6, Filled: false},
+		{Shape: rpgmap.SHAPE_DIAMOND, X: 96 - 14, Y: 96, Filled: true},
+		{Shape: rpgmap.SHAPE_DIAMOND, X: 96 - 10, Y: 96 - 10, Filled: false},
+	}
+
+	for _, test := range testcases {
+		t.Run(fmt.Sprintf("%s (%d, %d)", test.Shape, test.X, test.Y), func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(4, 4)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 1, Y: 1, Color: red, Shape: test.Shape})
+
+			mImg := NewSquareMapImage(m, newTestFontCache(t))
+			mImg.GridWidth = 64
+			mImg.GridHeight = 64
+			mImg.updateRect()
+
+			img, err := mImg.Render()
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			actual := img.RGBAAt(test.X, test.Y) == red
+			if actual != test.Filled {
+				t.Errorf("filled: got %t, want %t", actual, test.Filled)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package mapgen
+
+import (
+	"image/color"
+	"math"
+)
+
+// colorSortKey は、色を色相順に並べるための値を返す。
+//
+// 無彩色は有彩色の後に明るい順に並べる。
+func colorSortKey(c color.RGBA) float64 {
+	r := float64(c.R) / 255.0
+	g := float64(c.G) / 255.0
+	b := float64(c.B) / 255.0
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	delta := max - min
+
+	if delta == 0 {
+		return 360.0 + (1.0 - max)
+	}
+
+	var hue float64
+	switch max {
+	case r:
+		hue = math.Mod((g-b)/delta, 6.0)
+	case g:
+		hue = (b-r)/delta + 2.0
+	default:
+		hue = (r-g)/delta + 4.0
+	}
+
+	hue *= 60.0
+	if hue < 0 {
+		hue += 360.0
+	}
+
+	return hue
+}
@@ -1,25 +1,62 @@
 package mapgen
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
 
 	"github.com/golang/freetype/truetype"
 	"github.com/llgcode/draw2d"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 const (
 	// fontNameForMap は、マップ用に使用するフォントの名前。
 	fontNameForMap = "normal"
+	// fontNameForMapBold は、マップ用に使用する太字のフォントの名前。
+	fontNameForMapBold = "bold"
+	// fontNameFallbackPrefix は、代替フォントの名前の接頭辞。
+	fontNameFallbackPrefix = "fallback"
+
+	// fontCollectionIndexSeparator は、フォントファイルのパスと
+	// TrueTypeコレクション内のフォントの番号の区切り文字。
+	fontCollectionIndexSeparator = "#"
+	// ttcTag はTrueTypeコレクションのファイルの先頭にある識別子。
+	ttcTag = "ttcf"
 )
 
 // stringFontMap は文字列 -> フォントデータの対応の型。
 type stringFontMap map[string]*truetype.Font
 
 // FontCache はフォントデータを格納する構造体。
+//
+// 通常のフォントの他に、太字のフォントと代替フォントを格納できる。
+// 文字を描画する際、フォントに含まれない文字は代替フォントを順に探して描画する。
 type FontCache struct {
 	// fontMap はフォント名 -> フォントデータの対応。
 	fontMap stringFontMap
+	// fallbacks は、格納された順の代替フォントの名前。
+	fallbacks []string
+}
+
+// FontFiles はマップの描画に使用するフォントファイルの設定。
+//
+// パスの末尾に "#番号" を付けると、TrueTypeコレクション（.ttc）内の
+// その番号（0始まり）のフォントを使用する。
+type FontFiles struct {
+	// Regular は通常のフォントファイルのパス。
+	//
+	// 空の場合は組み込みのフォント（Goフォント）を使用する。
+	// 組み込みのフォントには日本語の文字が含まれないため、
+	// 日本語を描画する場合は日本語に対応したフォントを指定する。
+	Regular string
+	// Bold は太字のフォントファイルのパス。空の場合は通常のフォントを使用する。
+	Bold string
+	// Fallbacks は、フォントに含まれない文字を描画するための代替フォントファイルのパス。
+	Fallbacks []string
 }
 
 // NewFontCache は新しいフォントデータの格納先を返す。
@@ -44,13 +81,71 @@ func (fc *FontCache) Load(fd draw2d.FontData) (*truetype.Font, error) {
 	return font, nil
 }
 
-// フォントファイルからデータを読み、格納する。
+// StoreFontFiles は設定されたフォントファイルからデータを読み、格納する。
+func (fc *FontCache) StoreFontFiles(files *FontFiles) error {
+	if files.Regular == "" {
+		if err := fc.StoreDefaultFontData(); err != nil {
+			return err
+		}
+	} else {
+		if err := fc.StoreFontDataFromFile(files.Regular); err != nil {
+			return err
+		}
+	}
+
+	if files.Bold != "" {
+		if err := fc.StoreBoldFontDataFromFile(files.Bold); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files.Fallbacks {
+		if err := fc.AddFallbackFontDataFromFile(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StoreFontDataFromFile はフォントファイルからデータを読み、通常のフォントとして格納する。
 func (fc *FontCache) StoreFontDataFromFile(fontPath string) error {
-	b, err := ioutil.ReadFile(fontPath)
+	font, err := parseFontFile(fontPath)
 	if err != nil {
 		return err
 	}
 
+	fc.Store(draw2d.FontData{Name: fontNameForMap}, font)
+
+	return nil
+}
+
+// StoreBoldFontDataFromFile はフォントファイルからデータを読み、太字のフォントとして格納する。
+func (fc *FontCache) StoreBoldFontDataFromFile(fontPath string) error {
+	font, err := parseFontFile(fontPath)
+	if err != nil {
+		return err
+	}
+
+	fc.Store(draw2d.FontData{Name: fontNameForMapBold}, font)
+
+	return nil
+}
+
+// AddFallbackFontDataFromFile はフォントファイルからデータを読み、代替フォントとして追加する。
+func (fc *FontCache) AddFallbackFontDataFromFile(fontPath string) error {
+	font, err := parseFontFile(fontPath)
+	if err != nil {
+		return err
+	}
+
+	fc.AddFallbackFont(font)
+
+	return nil
+}
+
+// StoreFontData はTrueTypeフォントのデータを解析し、通常のフォントとして格納する。
+func (fc *FontCache) StoreFontData(b []byte) error {
 	font, err := truetype.Parse(b)
 	if err != nil {
 		return err
@@ -60,3 +155,111 @@ func (fc *FontCache) StoreFontDataFromFile(fontPath string) error {
 
 	return nil
 }
+
+// StoreDefaultFontData は、組み込みのフォント（Goフォント）を
+// 通常のフォントおよび太字のフォントとして格納する。
+func (fc *FontCache) StoreDefaultFontData() error {
+	regular, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		return err
+	}
+
+	bold, err := truetype.Parse(gobold.TTF)
+	if err != nil {
+		return err
+	}
+
+	fc.Store(draw2d.FontData{Name: fontNameForMap}, regular)
+	fc.Store(draw2d.FontData{Name: fontNameForMapBold}, bold)
+
+	return nil
+}
+
+// AddFallbackFont は代替フォントを追加する。
+//
+// 代替フォントは追加した順に探す。
+func (fc *FontCache) AddFallbackFont(font *truetype.Font) {
+	name := fontNameFallbackPrefix + strconv.Itoa(len(fc.fallbacks))
+	fc.Store(draw2d.FontData{Name: name}, font)
+	fc.fallbacks = append(fc.fallbacks, name)
+}
+
+// fontChain は、name のフォントに含まれない文字を探す順のフォントの名前を返す。
+//
+// 格納されていないフォントは含まない。
+func (fc *FontCache) fontChain(name string) []string {
+	names := []string{name}
+	if name != fontNameForMap {
+		names = append(names, fontNameForMap)
+	}
+	names = append(names, fc.fallbacks...)
+
+	chain := make([]string, 0, len(names))
+	for _, n := range names {
+		if _, stored := fc.fontMap[n]; stored {
+			chain = append(chain, n)
+		}
+	}
+
+	return chain
+}
+
+// parseFontFile はフォントファイルを読み込んで解析する。
+//
+// パスの末尾の "#番号" はTrueTypeコレクション内のフォントの番号とみなす。
+func parseFontFile(fontPath string) (*truetype.Font, error) {
+	path, index := splitFontPath(fontPath)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	font, err := parseFont(b, index)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fontPath, err)
+	}
+
+	return font, nil
+}
+
+// splitFontPath は、フォントファイルのパスとTrueTypeコレクション内のフォントの番号を分ける。
+func splitFontPath(fontPath string) (string, int) {
+	sep := strings.LastIndex(fontPath, fontCollectionIndexSeparator)
+	if sep < 0 {
+		return fontPath, 0
+	}
+
+	index, err := strconv.Atoi(fontPath[sep+1:])
+	if err != nil || index < 0 {
+		return fontPath, 0
+	}
+
+	return fontPath[:sep], index
+}
+
+// parseFont はフォントのデータを解析する。
+//
+// TrueTypeコレクションの場合は、index 番目のフォントを返す。
+func parseFont(b []byte, index int) (*truetype.Font, error) {
+	if index == 0 {
+		return truetype.Parse(b)
+	}
+
+	if len(b) < 12 || string(b[:4]) != ttcTag {
+		return nil, fmt.Errorf("not a TrueType collection: font index %d", index)
+	}
+
+	numFonts := int(binary.BigEndian.Uint32(b[8:12]))
+	if index >= numFonts || len(b) < 12+4*numFonts {
+		return nil, fmt.Errorf("font index out of range: %d", index)
+	}
+
+	// truetype.Parse はコレクションの最初のフォントを読むため、
+	// 最初のフォントの位置を目的のフォントの位置に書き換える
+	c := make([]byte, len(b))
+	copy(c, b)
+	copy(c[12:16], b[12+4*index:16+4*index])
+
+	return truetype.Parse(c)
+}
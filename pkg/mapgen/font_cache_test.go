@@ -0,0 +1,173 @@
+package mapgen
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/llgcode/draw2d"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// makeTTC はフォントのデータを並べたTrueTypeコレクションのデータを返す。
+func makeTTC(fonts ...[]byte) []byte {
+	b := make([]byte, 12+4*len(fonts))
+	copy(b, ttcTag)
+	binary.BigEndian.PutUint32(b[4:8], 0x00010000)
+	binary.BigEndian.PutUint32(b[8:12], uint32(len(fonts)))
+
+	for k, f := range fonts {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+
+		base := len(b)
+		binary.BigEndian.PutUint32(b[12+4*k:], uint32(base))
+
+		// テーブルの位置をコレクションの先頭からの位置に直す
+		relocated := append([]byte{}, f...)
+		numTables := int(binary.BigEndian.Uint16(relocated[4:6]))
+		for t := 0; t < numTables; t++ {
+			p := 12 + 16*t + 8
+			offset := binary.BigEndian.Uint32(relocated[p:])
+			binary.BigEndian.PutUint32(relocated[p:], offset+uint32(base))
+		}
+
+		b = append(b, relocated...)
+	}
+
+	return b
+}
+
+func TestSplitFontPath(t *testing.T) {
+	testcases := []struct {
+		Input         string
+		ExpectedPath  string
+		ExpectedIndex int
+	}{
+		{Input: "font.ttf", ExpectedPath: "font.ttf", ExpectedIndex: 0},
+		{Input: "fonts.ttc#2", ExpectedPath: "fonts.ttc", ExpectedIndex: 2},
+		{Input: "C:/Fonts/msgothic.ttc#1", ExpectedPath: "C:/Fonts/msgothic.ttc", ExpectedIndex: 1},
+		{Input: "#fonts/font.ttf", ExpectedPath: "#fonts/font.ttf", ExpectedIndex: 0},
+		{Input: "font.ttc#-1", ExpectedPath: "font.ttc#-1", ExpectedIndex: 0},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			path, index := splitFontPath(test.Input)
+			if path != test.ExpectedPath || index != test.ExpectedIndex {
+				t.Errorf("got: %s, %d, want: %s, %d", path, index, test.ExpectedPath, test.ExpectedIndex)
+			}
+		})
+	}
+}
+
+func TestParseFont_Collection(t *testing.T) {
+	ttc := makeTTC(goregular.TTF, gobold.TTF)
+
+	testcases := []struct {
+		Data     []byte
+		Index    int
+		Expected string
+		Err      bool
+	}{
+		{Data: ttc, Index: 0, Expected: "Go Regular"},
+		{Data: ttc, Index: 1, Expected: "Go Bold"},
+		{Data: ttc, Index: 2, Err: true},
+		{Data: goregular.TTF, Index: 0, Expected: "Go Regular"},
+		{Data: goregular.TTF, Index: 1, Err: true},
+	}
+
+	for _, test := range testcases {
+		font, err := parseFont(test.Data, test.Index)
+		if test.Err {
+			if err == nil {
+				t.Errorf("index %d: expected err", test.Index)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("index %d: got err: %s", test.Index, err)
+		}
+
+		if name := font.Name(truetype.NameIDFontFullName); name != test.Expected {
+			t.Errorf("index %d: got: %s, want: %s", test.Index, name, test.Expected)
+		}
+	}
+}
+
+func TestFontCache_SplitTextRuns(t *testing.T) {
+	fc := newTestFontCache(t)
+
+	runs := fc.splitTextRuns("Aあ", fontNameForMapBold)
+	if len(runs) != 1 || runs[0].FontName != fontNameForMap {
+		t.Errorf("without bold font: got %+v", runs)
+	}
+
+	bold, _ := truetype.Parse(gobold.TTF)
+	fc.Store(draw2d.FontData{Name: fontNameForMapBold}, bold)
+
+	runs = fc.splitTextRuns("Aあ", fontNameForMapBold)
+	if len(runs) != 1 || runs[0].FontName != fontNameForMapBold {
+		t.Errorf("with bold font: got %+v", runs)
+	}
+}
+
+func TestFontCache_SplitTextRuns_Fallback(t *testing.T) {
+	const fallbackPath = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	if _, err := os.Stat(fallbackPath); err != nil {
+		t.Skipf("fallback font is not installed: %s", fallbackPath)
+	}
+
+	fc := newTestFontCache(t)
+	if err := fc.AddFallbackFontDataFromFile(fallbackPath); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	runs := fc.splitTextRuns("A★B", fontNameForMap)
+	expected := []textRun{
+		{FontName: fontNameForMap, Text: "A"},
+		{FontName: fontNameFallbackPrefix + "0", Text: "★"},
+		{FontName: fontNameForMap, Text: "B"},
+	}
+
+	if len(runs) != len(expected) {
+		t.Fatalf("got: %+v, want: %+v", runs, expected)
+	}
+
+	for k, run := range runs {
+		if run != expected[k] {
+			t.Errorf("run %d: got: %+v, want: %+v", k, run, expected[k])
+		}
+	}
+}
+
+func TestFontCache_StoreFontFiles_DefaultFont(t *testing.T) {
+	fc := NewFontCache()
+	if err := fc.StoreFontFiles(&FontFiles{}); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	testcases := []struct {
+		FontName string
+		Expected string
+	}{
+		{FontName: fontNameForMap, Expected: "Go Regular"},
+		{FontName: fontNameForMapBold, Expected: "Go Bold"},
+	}
+
+	for _, test := range testcases {
+		font, err := fc.Load(draw2d.FontData{Name: test.FontName})
+		if err != nil {
+			t.Fatalf("%s: got err: %s", test.FontName, err)
+		}
+
+		if name := font.Name(truetype.NameIDFontFullName); name != test.Expected {
+			t.Errorf("%s: got: %s, want: %s", test.FontName, name, test.Expected)
+		}
+	}
+}
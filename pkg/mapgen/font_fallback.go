@@ -0,0 +1,95 @@
+package mapgen
+
+import (
+	"math"
+
+	"github.com/llgcode/draw2d"
+)
+
+// textRun は、同じフォントで描画する文字列の断片。
+type textRun struct {
+	// FontName はフォントの名前。
+	FontName string
+	// Text は文字列。
+	Text string
+}
+
+// splitTextRuns は、文字列を描画に使うフォントごとの断片に分ける。
+//
+// 各文字には、name のフォントから順に代替フォントを探し、
+// 最初に見つかったその文字を含むフォントを使う。
+// どのフォントにも含まれない文字は、格納されている最初のフォントで描画する。
+func (fc *FontCache) splitTextRuns(s string, name string) []textRun {
+	chain := fc.fontChain(name)
+	switch len(chain) {
+	case 0:
+		return []textRun{{FontName: name, Text: s}}
+	case 1:
+		return []textRun{{FontName: chain[0], Text: s}}
+	}
+
+	runs := []textRun{}
+	for _, r := range s {
+		fontName := chain[0]
+		for _, n := range chain {
+			if fc.fontMap[n].Index(r) != 0 {
+				fontName = n
+				break
+			}
+		}
+
+		last := len(runs) - 1
+		if last >= 0 && runs[last].FontName == fontName {
+			runs[last].Text += string(r)
+		} else {
+			runs = append(runs, textRun{FontName: fontName, Text: string(r)})
+		}
+	}
+
+	return runs
+}
+
+// fillStringAt は、代替フォントを使いながらgcの (x, y) に文字列を描画し、その幅を返す。
+//
+// fontName は最初に使うフォントの名前。
+func fillStringAt(gc draw2d.GraphicContext, fc *FontCache, fontName string, s string, x float64, y float64) float64 {
+	defer gc.SetFontData(gc.GetFontData())
+
+	cursor := x
+	for _, run := range fc.splitTextRuns(s, fontName) {
+		gc.SetFontData(draw2d.FontData{Name: run.FontName})
+		cursor += gc.FillStringAt(run.Text, cursor, y)
+	}
+
+	return cursor - x
+}
+
+// measureString は、代替フォントを使いながら文字列を描画したときの幅を返す。
+//
+// 幅の計測のためにgcのパスを消去する。
+func measureString(gc draw2d.GraphicContext, fc *FontCache, fontName string, s string) float64 {
+	defer gc.SetFontData(gc.GetFontData())
+
+	left := math.Inf(1)
+	right := math.Inf(-1)
+	cursor := 0.0
+	for _, run := range fc.splitTextRuns(s, fontName) {
+		gc.SetFontData(draw2d.FontData{Name: run.FontName})
+
+		l, _, r, _ := gc.GetStringBounds(run.Text)
+		// 空白のみの断片は範囲を持たない
+		if l <= r {
+			left = math.Min(left, cursor+l)
+			right = math.Max(right, cursor+r)
+		}
+
+		cursor += gc.CreateStringPath(run.Text, 0, 0)
+		gc.BeginPath()
+	}
+
+	if right < left {
+		return 0
+	}
+
+	return right - left
+}
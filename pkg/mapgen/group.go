@@ -0,0 +1,109 @@
+package mapgen
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dkit"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	// groupBorderRatio は、グループの縁取りの太さの半径に対する比率。
+	groupBorderRatio = 0.2
+	// minGroupBorderWidth はグループの縁取りの最小の太さ。
+	minGroupBorderWidth = 1.5
+
+	// legendNoGroupLabel は、凡例でグループに属さないチットの見出し。
+	legendNoGroupLabel = "（グループなし）"
+)
+
+// legendGroup は凡例のグループごとの区切り。
+type legendGroup struct {
+	// Name はグループの名前。空の場合はグループに属さないチットを表す。
+	Name string
+	// Chits はグループのチットの配列。
+	Chits []*rpgmap.Chit
+}
+
+// groupLegendChits はチットをグループごとに分ける。
+//
+// グループは chits の中で最初に現れる順に並べ、グループに属さないチットは最後に置く。
+// 各グループ内のチットの順序は保たれる。
+func groupLegendChits(chits []*rpgmap.Chit) []legendGroup {
+	groups := []legendGroup{}
+	index := map[string]int{}
+	ungrouped := []*rpgmap.Chit{}
+
+	for _, c := range chits {
+		if c.Group == "" {
+			ungrouped = append(ungrouped, c)
+			continue
+		}
+
+		k, found := index[c.Group]
+		if !found {
+			k = len(groups)
+			index[c.Group] = k
+			groups = append(groups, legendGroup{Name: c.Group})
+		}
+
+		groups[k].Chits = append(groups[k].Chits, c)
+	}
+
+	if len(ungrouped) > 0 {
+		groups = append(groups, legendGroup{Chits: ungrouped})
+	}
+
+	return groups
+}
+
+// groupColor はチットのグループの色を返す。
+func (i *SquareMapImage) groupColor(chit *rpgmap.Chit) (color.RGBA, bool) {
+	if chit.Group == "" {
+		return color.RGBA{}, false
+	}
+
+	return i.Map.GroupColor(chit.Group)
+}
+
+// groupBorderWidth は、半径 r のチットのグループの縁取りの太さを返す。
+func groupBorderWidth(r float64) float64 {
+	return math.Max(minGroupBorderWidth, r*groupBorderRatio)
+}
+
+// drawGroupBorder は、gcの中心 (x, y)、半径 r のチットの外側をグループの色で縁取る。
+//
+// チットがグループに属さない場合は何もしない。
+func (i *SquareMapImage) drawGroupBorder(gc draw2d.GraphicContext, chit *rpgmap.Chit, x float64, y float64, r float64) {
+	c, found := i.groupColor(chit)
+	if !found {
+		return
+	}
+
+	shape := chit.Shape
+	if chit.Image != nil {
+		// 画像付きのチットは円形に切り抜かれる
+		shape = rpgmap.SHAPE_CIRCLE
+	}
+
+	w := groupBorderWidth(r)
+	gc.SetStrokeColor(c)
+	gc.SetLineWidth(w)
+	chitShapePath(gc, shape, x, y, r+w/2.0)
+	gc.Stroke()
+}
+
+// drawLegendGroupSwatch は、gcの中心 (x, y) にグループの色の見本を描画する。
+func (i *SquareMapImage) drawLegendGroupSwatch(gc draw2d.GraphicContext, group string, x float64, y float64, r float64) {
+	c, found := i.Map.GroupColor(group)
+	if !found {
+		return
+	}
+
+	gc.SetFillColor(c)
+	draw2dkit.Rectangle(gc, x-r, y-r/2.0, x+r, y+r/2.0)
+	gc.Fill()
+}
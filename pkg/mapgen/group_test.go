@@ -0,0 +1,78 @@
+package mapgen
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapImage_LegendLayout_Groups(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(20, 4)
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 0, Y: 0, Group: "Goblins"})
+	m.AddChit(&rpgmap.Chit{Name: "Merchant", X: 1, Y: 0})
+	m.AddChit(&rpgmap.Chit{Name: "Fighter", X: 2, Y: 0, Group: "PC"})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 2", X: 3, Y: 0, Group: "Goblins"})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	l := mImg.newLegendLayout(mImg.chits(), image.Pt(mImg.Width(), mImg.Height()))
+
+	expected := []string{"Goblins", "Goblin 1", "Goblin 2", "PC", "Fighter", legendNoGroupLabel, "Merchant"}
+	if len(l.Entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d", len(l.Entries), len(expected))
+	}
+
+	for k, e := range l.Entries {
+		if label := strings.Join(e.Lines, " "); label != expected[k] {
+			t.Errorf("%d: got: %s, want: %s", k, label, expected[k])
+		}
+
+		isHeader := e.Chit == nil
+		if expectedHeader := k == 0 || k == 3 || k == 5; isHeader != expectedHeader {
+			t.Errorf("%d: header: got %t, want %t", k, isHeader, expectedHeader)
+		}
+	}
+}
+
+func TestSquareMapImage_LegendLayout_NoHeadersWithoutGroups(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(20, 4)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 1, Y: 0})
+	m.AddChit(&rpgmap.Chit{Name: "C", X: 2, Y: 0})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	l := mImg.newLegendLayout(mImg.chits(), image.Pt(mImg.Width(), mImg.Height()))
+
+	for _, e := range l.Entries {
+		if e.Chit == nil {
+			t.Errorf("unexpected header: %s", e.Lines)
+		}
+	}
+}
+
+func TestSquareMapImage_Render_GroupBorder(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(4, 4)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 1, Y: 1, Color: colorutil.CSS3NameToRGBA("red"), Group: "PC"})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	mImg.GridWidth = 64
+	mImg.GridHeight = 64
+	mImg.updateRect()
+
+	img, err := mImg.Render()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	// チットの半径は16、縁取りの太さは3.2で、チットの外側に描画される
+	expected, _ := m.GroupColor("PC")
+	if actual := img.RGBAAt(64+32+17, 64+32); actual != expected {
+		t.Errorf("border: got %v, want %v", actual, expected)
+	}
+
+	if actual := img.RGBAAt(64+32+10, 64+32); actual != colorutil.CSS3NameToRGBA("red") {
+		t.Errorf("chit: got %v, want red", actual)
+	}
+}
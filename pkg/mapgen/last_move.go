@@ -0,0 +1,86 @@
+package mapgen
+
+import (
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dkit"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// lastMovedChit は、強調して描画する直前に移動したチットを返す。
+//
+// 強調しない場合や、チットが見つからない場合は nil を返す。
+func (i *SquareMapImage) lastMovedChit(chits []*rpgmap.Chit) *rpgmap.Chit {
+	if !i.HighlightLastMove || i.LastMovedChit == "" {
+		return nil
+	}
+
+	for _, c := range chits {
+		if c.Name == i.LastMovedChit && c.Moved {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// drawLastMoveArrow は、gcにチットの移動前のマスから現在のマスへの矢印を描画する。
+//
+// 矢印の先端はチットの縁で止める。移動前と同じマスにいる場合は何もしない。
+func (i *SquareMapImage) drawLastMoveArrow(gc draw2d.GraphicContext, c *rpgmap.Chit) {
+	if c.PrevX == c.X && c.PrevY == c.Y {
+		return
+	}
+
+	x1, y1 := i.cellCenter(cellPoint{X: float64(c.PrevX), Y: float64(c.PrevY)})
+	x2, y2 := i.cellCenter(cellPoint{X: float64(c.X), Y: float64(c.Y)})
+
+	dx := x2 - x1
+	dy := y2 - y1
+	length := math.Hypot(dx, dy)
+	ux := dx / length
+	uy := dy / length
+
+	size := math.Min(float64(i.GridWidth), float64(i.GridHeight))
+	lineWidth := math.Max(1.0, size/10.0)
+	headLength := size / 4.0
+
+	// 先端はチットの縁
+	r := i.chitDiameter() / 2.0
+	tipX := x2 - ux*r
+	tipY := y2 - uy*r
+	baseX := tipX - ux*headLength
+	baseY := tipY - uy*headLength
+
+	gc.SetStrokeColor(i.HighlightColor)
+	gc.SetFillColor(i.HighlightColor)
+	gc.SetLineWidth(lineWidth)
+
+	gc.MoveTo(x1, y1)
+	gc.LineTo(baseX, baseY)
+	gc.Stroke()
+
+	// 矢じり
+	halfWidth := headLength / 2.0
+	gc.MoveTo(tipX, tipY)
+	gc.LineTo(baseX-uy*halfWidth, baseY+ux*halfWidth)
+	gc.LineTo(baseX+uy*halfWidth, baseY-ux*halfWidth)
+	gc.Close()
+	gc.Fill()
+}
+
+// drawHighlightRing はgcにチットを囲む強調の輪を描画する。
+func (i *SquareMapImage) drawHighlightRing(gc draw2d.GraphicContext, c *rpgmap.Chit) {
+	x, y := i.cellCenter(cellPoint{X: float64(c.X), Y: float64(c.Y)})
+
+	size := math.Min(float64(i.GridWidth), float64(i.GridHeight))
+	lineWidth := math.Max(1.0, size/12.0)
+	r := i.chitDiameter()/2.0 + lineWidth
+
+	gc.SetStrokeColor(i.HighlightColor)
+	gc.SetLineWidth(lineWidth)
+	draw2dkit.Circle(gc, x, y, r)
+	gc.Stroke()
+}
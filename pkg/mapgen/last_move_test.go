@@ -0,0 +1,47 @@
+package mapgen
+
+import (
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapImage_Render_LastMove(t *testing.T) {
+	testcases := []struct {
+		Name          string
+		LastMoved     string
+		Highlight     bool
+		ExpectedArrow bool
+	}{
+		{Name: "highlighted", LastMoved: "A", Highlight: true, ExpectedArrow: true},
+		{Name: "disabled", LastMoved: "A", Highlight: false},
+		{Name: "not moved", LastMoved: "B", Highlight: true},
+		{Name: "none", Highlight: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(6, 2)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0, Color: colorutil.CSS3NameToRGBA("blue")})
+			m.AddChit(&rpgmap.Chit{Name: "B", X: 5, Y: 1, Color: colorutil.CSS3NameToRGBA("blue")})
+			m.MoveChit("A", 4, 0)
+
+			mImg := NewSquareMapImage(m, newTestFontCache(t))
+			mImg.LastMovedChit = test.LastMoved
+			mImg.HighlightLastMove = test.Highlight
+
+			img, err := mImg.Render()
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			// 移動前と移動後のマスの間（矢印の軸上）
+			actual := img.RGBAAt(2*32+16, 16)
+			isHighlighted := actual == mImg.HighlightColor
+			if isHighlighted != test.ExpectedArrow {
+				t.Errorf("arrow: got %v, highlighted: %t, want: %t", actual, isHighlighted, test.ExpectedArrow)
+			}
+		})
+	}
+}
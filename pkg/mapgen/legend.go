@@ -0,0 +1,334 @@
+package mapgen
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// 凡例の配置。
+const (
+	// LEGEND_BELOW はマップの下に凡例を置くことを表す。
+	LEGEND_BELOW = "below"
+	// LEGEND_RIGHT はマップの右に凡例を置くことを表す。
+	LEGEND_RIGHT = "right"
+)
+
+// 凡例の並べ方。
+const (
+	// LEGEND_SORT_INSERTION はチットを追加した順に並べることを表す。
+	LEGEND_SORT_INSERTION = "insertion"
+	// LEGEND_SORT_NAME はチットの名前順に並べることを表す。
+	LEGEND_SORT_NAME = "name"
+	// LEGEND_SORT_COLOR はチットの色相順に並べることを表す。
+	LEGEND_SORT_COLOR = "color"
+	// LEGEND_SORT_INITIATIVE はチットのイニシアチブの高い順に並べることを表す。
+	LEGEND_SORT_INITIATIVE = "initiative"
+)
+
+// LegendPlacements は凡例の配置の一覧。
+var LegendPlacements = []string{LEGEND_BELOW, LEGEND_RIGHT}
+
+// LegendSorts は凡例の並べ方の一覧。
+var LegendSorts = []string{
+	LEGEND_SORT_INSERTION,
+	LEGEND_SORT_NAME,
+	LEGEND_SORT_COLOR,
+	LEGEND_SORT_INITIATIVE,
+}
+
+// legendEntry は凡例の項目。
+//
+// チットの項目の他に、グループの見出しの項目がある。
+type legendEntry struct {
+	// Chit は項目のチット。グループの見出しの場合は nil。
+	Chit *rpgmap.Chit
+	// Group は見出しのグループの名前。
+	Group string
+	// Lines は表示する名前の各行。長い名前は折り返されている。
+	Lines []string
+	// FontName は名前の描画に使うフォントの名前。
+	FontName string
+	// Column は項目を置く列。
+	Column int
+	// Row は項目の先頭の行。
+	Row int
+}
+
+// legendLayout は凡例の配置の情報。
+//
+// 項目は列ごとに上から下へ並べ、列が埋まったら右の列に移る。
+// 折り返した名前の項目は、行数分の高さを使う。項目は列をまたがない。
+type legendLayout struct {
+	// Entries は並べ替えた凡例の項目。
+	Entries []legendEntry
+	// Columns は列数。
+	Columns int
+	// Rows は1列の行数。
+	Rows int
+	// ColumnWidth は列の幅。
+	ColumnWidth float64
+	// RowHeight は行の高さ。
+	RowHeight float64
+}
+
+// size は凡例全体の幅と高さを返す。
+func (l *legendLayout) size() (float64, float64) {
+	return float64(l.Columns) * l.ColumnWidth, float64(l.Rows) * l.RowHeight
+}
+
+// newLegendLayout は、大きさ mapSize のマップに付けるチットの凡例の配置を決める。
+func (i *SquareMapImage) newLegendLayout(chits []*rpgmap.Chit, mapSize image.Point) *legendLayout {
+	l := &legendLayout{
+		RowHeight: float64(i.legendRowHeight()),
+	}
+
+	if len(chits) < 1 {
+		return l
+	}
+
+	// 名前の幅を測り、長すぎるものは折り返す
+	// 直前に移動したチットの名前は太字にする
+	gc := i.newMeasureContext()
+	lastMoved := i.lastMovedChit(chits)
+	maxLabelWidth := i.LegendNameWidth * float64(i.GridWidth)
+	labelWidth := 0.0
+	numOfLines := 0
+	maxLines := 1
+	addEntry := func(e legendEntry, name string) {
+		measure := func(s string) float64 {
+			return measureString(gc, i.FontCache, e.FontName, s)
+		}
+
+		e.Lines = wrapLabel(measure, name, maxLabelWidth)
+		for _, line := range e.Lines {
+			labelWidth = math.Max(labelWidth, measure(line))
+		}
+
+		numOfLines += len(e.Lines)
+		if len(e.Lines) > maxLines {
+			maxLines = len(e.Lines)
+		}
+
+		l.Entries = append(l.Entries, e)
+	}
+
+	// グループがある場合は、グループごとに見出しを付けて並べる
+	groups := groupLegendChits(i.sortLegendChits(chits))
+	withHeaders := len(groups) > 1 || groups[0].Name != ""
+	for _, g := range groups {
+		if withHeaders {
+			header := g.Name
+			if header == "" {
+				header = legendNoGroupLabel
+			}
+
+			addEntry(legendEntry{Group: g.Name, FontName: fontNameForMapBold}, header)
+		}
+
+		for _, c := range g.Chits {
+			fontName := fontNameForMap
+			if c == lastMoved {
+				fontName = fontNameForMapBold
+			}
+
+			addEntry(legendEntry{Chit: c, FontName: fontName}, c.Name)
+		}
+	}
+
+	// 印の幅 + 名前の幅 + 余白
+	l.ColumnWidth = math.Ceil(float64(i.GridWidth) + labelWidth + float64(i.GridWidth)/2.0)
+
+	if i.LegendPlacement == LEGEND_RIGHT {
+		l.Rows = clampInt(int(float64(mapSize.Y)/l.RowHeight), maxLines, numOfLines)
+		l.Columns = l.place(l.Rows)
+	} else {
+		columns := clampInt(int(float64(mapSize.X)/l.ColumnWidth), 1, len(l.Entries))
+
+		// 列数に収まるまで1列の行数を増やす
+		rows := (numOfLines + columns - 1) / columns
+		if rows < maxLines {
+			rows = maxLines
+		}
+
+		for l.place(rows) > columns {
+			rows++
+		}
+
+		l.Rows = rows
+		l.Columns = l.place(rows)
+	}
+
+	return l
+}
+
+// place は、1列の行数を rows として項目を並べ、使った列数を返す。
+func (l *legendLayout) place(rows int) int {
+	column := 0
+	row := 0
+	for k := range l.Entries {
+		e := &l.Entries[k]
+		if row > 0 && row+len(e.Lines) > rows {
+			column++
+			row = 0
+		}
+
+		e.Column = column
+		e.Row = row
+		row += len(e.Lines)
+	}
+
+	return column + 1
+}
+
+// clampInt は v を min 以上 max 以下に収めた値を返す。
+func clampInt(v int, min int, max int) int {
+	if v < min {
+		return min
+	}
+
+	if v > max {
+		return max
+	}
+
+	return v
+}
+
+// newMeasureContext は文字の幅を測るためのグラフィックコンテキストを返す。
+func (i *SquareMapImage) newMeasureContext() draw2d.GraphicContext {
+	gc := draw2dimg.NewGraphicContext(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	gc.FontCache = i.FontCache
+	gc.SetFontData(draw2d.FontData{Name: fontNameForMap})
+	gc.SetFontSize(i.legendFontSize())
+
+	return gc
+}
+
+// wrapLabel は、measure で測った幅が maxWidth を超えないように名前を折り返す。
+//
+// できるだけ空白の位置で折り返し、1単語が収まらない場合は単語の途中で折り返す。
+// maxWidth が0以下の場合は折り返さない。
+func wrapLabel(measure func(string) float64, name string, maxWidth float64) []string {
+	if maxWidth <= 0 || measure(name) <= maxWidth {
+		return []string{name}
+	}
+
+	lines := []string{}
+	rest := []rune(strings.TrimSpace(name))
+	for len(rest) > 0 {
+		// 収まる文字数を数える（少なくとも1文字は置く）
+		n := 1
+		for n < len(rest) && measure(string(rest[:n+1])) <= maxWidth {
+			n++
+		}
+
+		if n < len(rest) {
+			for k := n; k > 0; k-- {
+				if unicode.IsSpace(rest[k]) {
+					n = k
+					break
+				}
+			}
+		}
+
+		lines = append(lines, strings.TrimSpace(string(rest[:n])))
+		rest = []rune(strings.TrimLeftFunc(string(rest[n:]), unicode.IsSpace))
+	}
+
+	return lines
+}
+
+// sortLegendChits は、凡例の並べ方に従って並べ替えたチットの配列を返す。
+//
+// 並べ方で順序が決まらないチットは、追加した順に並べる。
+func (i *SquareMapImage) sortLegendChits(chits []*rpgmap.Chit) []*rpgmap.Chit {
+	sorted := append([]*rpgmap.Chit{}, chits...)
+
+	var less func(a, b *rpgmap.Chit) bool
+	switch i.LegendSort {
+	case LEGEND_SORT_NAME:
+		less = func(a, b *rpgmap.Chit) bool {
+			return a.Name < b.Name
+		}
+	case LEGEND_SORT_COLOR:
+		less = func(a, b *rpgmap.Chit) bool {
+			return colorSortKey(a.Color) < colorSortKey(b.Color)
+		}
+	case LEGEND_SORT_INITIATIVE:
+		less = func(a, b *rpgmap.Chit) bool {
+			return a.Initiative > b.Initiative
+		}
+	default:
+		return sorted
+	}
+
+	sort.SliceStable(sorted, func(x, y int) bool {
+		return less(sorted[x], sorted[y])
+	})
+
+	return sorted
+}
+
+// legendFontSize は凡例の文字の大きさを返す。
+func (i *SquareMapImage) legendFontSize() float64 {
+	if i.LegendFontSize > 0 {
+		return i.LegendFontSize
+	}
+
+	return 0.4 * math.Min(float64(i.GridWidth), float64(i.GridHeight))
+}
+
+// legendRowHeight は凡例の1行の高さを返す。
+//
+// 文字が大きい場合は、文字が収まるように1マスの高さより高くする。
+func (i *SquareMapImage) legendRowHeight() int {
+	return int(math.Max(float64(i.GridHeight), math.Ceil(1.5*i.legendFontSize())))
+}
+
+// drawLegend はgcの (left, top) を左上として凡例を描画する。
+func (mImg *SquareMapImage) drawLegend(gc draw2d.GraphicContext, l *legendLayout, left float64, top float64) {
+	if len(l.Entries) < 1 {
+		return
+	}
+
+	width, height := l.size()
+	fontSize := mImg.legendFontSize()
+	r := mImg.chitDiameter() / 2.0
+
+	gc.SetFontData(draw2d.FontData{Name: fontNameForMap})
+	gc.SetFontSize(fontSize)
+
+	// 背景色で塗る
+	gc.SetFillColor(mImg.BackgroundColor)
+	draw2dkit.Rectangle(gc, left, top, left+width, top+height)
+	gc.Fill()
+
+	// 凡例の各項目を描画する
+	for _, e := range l.Entries {
+		x0 := left + float64(e.Column)*l.ColumnWidth
+		y0 := top + float64(e.Row)*l.RowHeight
+
+		x := x0 + float64(mImg.GridWidth)/2.0
+		y := y0 + l.RowHeight/2.0
+		if e.Chit == nil {
+			mImg.drawLegendGroupSwatch(gc, e.Group, x, y, r)
+		} else {
+			drawChitMarker(gc, e.Chit, x, y, r, mImg.ChitBorderWidth)
+			mImg.drawGroupBorder(gc, e.Chit, x, y, r)
+		}
+
+		gc.SetFillColor(mImg.LegendTextColor)
+		for n, line := range e.Lines {
+			fillStringAt(gc, mImg.FontCache, e.FontName, line,
+				x0+float64(mImg.GridWidth), y+float64(n)*l.RowHeight+fontSize/2)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package mapgen
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapImage_LegendLayout(t *testing.T) {
+	testcases := []struct {
+		Name            string
+		Placement       string
+		Width           int
+		Height          int
+		NumOfChits      int
+		ExpectedColumns int
+		ExpectedRows    int
+	}{
+		{Name: "below, single row", Placement: LEGEND_BELOW, Width: 20, Height: 4, NumOfChits: 3, ExpectedColumns: 3, ExpectedRows: 1},
+		{Name: "below, narrow", Placement: LEGEND_BELOW, Width: 2, Height: 4, NumOfChits: 3, ExpectedColumns: 1, ExpectedRows: 3},
+		{Name: "right", Placement: LEGEND_RIGHT, Width: 4, Height: 2, NumOfChits: 5, ExpectedColumns: 3, ExpectedRows: 2},
+		{Name: "no chits", Placement: LEGEND_BELOW, Width: 4, Height: 4, NumOfChits: 0, ExpectedColumns: 0, ExpectedRows: 0},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(test.Width, test.Height)
+			for k := 0; k < test.NumOfChits; k++ {
+				m.AddChit(&rpgmap.Chit{Name: fmt.Sprintf("C%d", k+1), X: 0, Y: 0})
+			}
+
+			mImg := NewSquareMapImage(m, newTestFontCache(t))
+			mImg.LegendPlacement = test.Placement
+
+			l := mImg.newLegendLayout(mImg.chits(), image.Pt(mImg.Width(), mImg.Height()))
+			if l.Columns != test.ExpectedColumns || l.Rows != test.ExpectedRows {
+				t.Errorf("got: %d x %d, want: %d x %d", l.Columns, l.Rows, test.ExpectedColumns, test.ExpectedRows)
+			}
+		})
+	}
+}
+
+func TestSquareMapImage_Render_LegendRight(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(4, 2)
+	for _, name := range []string{"C1", "C2", "C3", "C4", "C5"} {
+		m.AddChit(&rpgmap.Chit{Name: name, X: 0, Y: 0})
+	}
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	mImg.LegendPlacement = LEGEND_RIGHT
+
+	img, err := mImg.Render()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	l := mImg.newLegendLayout(mImg.chits(), image.Pt(mImg.Width(), mImg.Height()))
+	expected := image.Pt(4*32+3*int(l.ColumnWidth), 2*32)
+	if img.Bounds().Size() != expected {
+		t.Errorf("size: got %v, want %v", img.Bounds().Size(), expected)
+	}
+}
+
+func TestSquareMapImage_LegendWrapsLongNames(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(10, 4)
+	m.AddChit(&rpgmap.Chit{Name: strings.Repeat("Goblin ", 5) + strings.Repeat("x", 30)})
+	m.AddChit(&rpgmap.Chit{Name: "Bob"})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	mImg.LegendNameWidth = 2
+
+	l := mImg.newLegendLayout(mImg.chits(), image.Pt(mImg.Width(), mImg.Height()))
+
+	long := l.Entries[0].Lines
+	if len(long) < 2 {
+		t.Fatalf("long name: got %q", long)
+	}
+
+	// 名前は省略されない
+	if actual := strings.Join(strings.Fields(strings.Join(long, "")), ""); actual != strings.Repeat("Goblin", 5)+strings.Repeat("x", 30) {
+		t.Errorf("long name: got %q", long)
+	}
+
+	// 単語の途中では折り返さない
+	if long[0] != "Goblin" && !strings.HasPrefix(long[0], "Goblin Goblin") {
+		t.Errorf("first line: got %q", long[0])
+	}
+
+	gc := mImg.newMeasureContext()
+	for _, line := range long {
+		if w := measureString(gc, mImg.FontCache, fontNameForMap, line); w > 2*32 {
+			t.Errorf("width of %q: got %g, want <= %d", line, w, 2*32)
+		}
+	}
+
+	if len(l.Entries[1].Lines) != 1 || l.Entries[1].Lines[0] != "Bob" {
+		t.Errorf("short name: got %q, want %q", l.Entries[1].Lines, "Bob")
+	}
+
+	// 折り返した項目は行数分の高さを使い、次の項目は右の列に置かれる
+	if l.Rows != len(long) {
+		t.Errorf("Rows: got %d, want %d", l.Rows, len(long))
+	}
+
+	if l.Entries[1].Column != 1 || l.Entries[1].Row != 0 {
+		t.Errorf("position of Bob: got (%d, %d), want (1, 0)", l.Entries[1].Column, l.Entries[1].Row)
+	}
+}
+
+func TestSquareMapImage_Render_LegendRightFillsBackground(t *testing.T) {
+	testcases := []struct {
+		Name   string
+		Height int
+		Chit   string
+		Point  image.Point
+	}{
+		// 凡例の下の余白
+		{Name: "below legend", Height: 4, Chit: "A", Point: image.Pt(4*32+8, 3*32+8)},
+		// 折り返した凡例が高い場合のマップの下の余白
+		{Name: "below map", Height: 2, Chit: strings.Repeat("Goblin ", 4), Point: image.Pt(8, 2*32+8)},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(4, test.Height)
+			m.AddChit(&rpgmap.Chit{Name: test.Chit})
+
+			mImg := NewSquareMapImage(m, newTestFontCache(t))
+			mImg.LegendPlacement = LEGEND_RIGHT
+			mImg.LegendNameWidth = 2
+
+			img, err := mImg.Render()
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if !test.Point.In(img.Bounds()) {
+				t.Fatalf("%v is out of %v", test.Point, img.Bounds())
+			}
+
+			actual := img.RGBAAt(test.Point.X, test.Point.Y)
+			if actual != mImg.BackgroundColor {
+				t.Errorf("got: %v, want: %v", actual, mImg.BackgroundColor)
+			}
+		})
+	}
+}
+
+func TestSquareMapImage_SortLegendChits(t *testing.T) {
+	chits := []*rpgmap.Chit{
+		{Name: "Carol", Color: colorutil.CSS3NameToRGBA("dodgerblue"), Initiative: 12},
+		{Name: "Alice", Color: colorutil.CSS3NameToRGBA("gray"), Initiative: 8},
+		{Name: "Bob", Color: colorutil.CSS3NameToRGBA("red"), Initiative: 15},
+		{Name: "Dave", Color: colorutil.CSS3NameToRGBA("gold"), Initiative: 12},
+	}
+
+	testcases := []struct {
+		Sort     string
+		Expected string
+	}{
+		{Sort: LEGEND_SORT_INSERTION, Expected: "Carol Alice Bob Dave"},
+		{Sort: LEGEND_SORT_NAME, Expected: "Alice Bob Carol Dave"},
+		{Sort: LEGEND_SORT_COLOR, Expected: "Bob Dave Carol Alice"},
+		{Sort: LEGEND_SORT_INITIATIVE, Expected: "Bob Carol Dave Alice"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Sort, func(t *testing.T) {
+			mImg := &SquareMapImage{LegendSort: test.Sort}
+
+			names := []string{}
+			for _, c := range mImg.sortLegendChits(chits) {
+				names = append(names, c.Name)
+			}
+
+			actual := strings.Join(names, " ")
+			if actual != test.Expected {
+				t.Errorf("got: %s, want: %s", actual, test.Expected)
+			}
+		})
+	}
+}
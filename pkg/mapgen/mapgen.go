@@ -3,14 +3,14 @@ package mapgen
 import (
 	"image"
 	"image/color"
-	"image/draw"
 	"math"
+	"strconv"
 
 	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/llgcode/draw2d/draw2dkit"
+	"github.com/llgcode/draw2d/draw2dsvg"
 
-	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
 	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
 )
 
@@ -30,24 +30,68 @@ type SquareMapImage struct {
 	BackgroundColor color.RGBA
 	// GridColor はグリッドの線の色。
 	GridColor color.RGBA
+	// LegendTextColor は凡例の文字の色。
+	LegendTextColor color.RGBA
+	// GridLineWidth はグリッドの線の太さ。
+	GridLineWidth float64
+	// ChitBorderWidth は画像付きのチットの縁取りの太さ。0の場合はチットの大きさから決める。
+	ChitBorderWidth float64
+	// ChitScale は1マスの大きさに対するチットの直径の比率。
+	ChitScale float64
+	// LegendFontSize は凡例の文字の大きさ。0の場合は1マスの大きさから決める。
+	LegendFontSize float64
+	// LegendPlacement は凡例の配置（LEGEND_BELOW または LEGEND_RIGHT）。
+	LegendPlacement string
+	// LegendSort は凡例の並べ方。
+	LegendSort string
+	// LegendNameWidth は凡例に表示する名前の最大の幅（マス単位）。
+	LegendNameWidth float64
+	// HighlightColor は直前に移動したチットを強調する色。
+	HighlightColor color.RGBA
+	// HighlightLastMove は、直前に移動したチットの移動を強調するかどうか。
+	HighlightLastMove bool
+	// LastMovedChit は直前に移動したチットの名前。空の場合は強調しない。
+	LastMovedChit string
+	// View は描画するマップの範囲（マス単位）。空の場合はマップ全体を描画する。
+	View image.Rectangle
+	// Scale は描画の拡大率。0の場合は等倍で描画する。
+	Scale float64
 }
 
 // NewSquareMapImage は新しいスクエアマップ描画情報を返す。
+//
+// 描画の設定には既定のテーマが使われる。
 func NewSquareMapImage(m *rpgmap.SquareMap, fc *FontCache) *SquareMapImage {
 	i := &SquareMapImage{
-		Map:             m,
-		FontCache:       fc,
-		GridWidth:       32,
-		GridHeight:      32,
-		BackgroundColor: colorutil.CSS3NameToRGBA("white"),
-		GridColor:       colorutil.CSS3NameToRGBA("dimgray"),
+		Map:       m,
+		FontCache: fc,
 	}
 
-	i.updateRect()
+	i.ApplyTheme(DefaultTheme())
 
 	return i
 }
 
+// ApplyTheme は描画の設定をテーマに合わせる。
+func (i *SquareMapImage) ApplyTheme(t *Theme) {
+	i.GridWidth = t.CellSize
+	i.GridHeight = t.CellSize
+	i.BackgroundColor = t.BackgroundColor
+	i.GridColor = t.GridColor
+	i.LegendTextColor = t.LegendTextColor
+	i.GridLineWidth = t.GridLineWidth
+	i.ChitBorderWidth = t.ChitBorderWidth
+	i.ChitScale = t.ChitScale
+	i.LegendFontSize = t.LegendFontSize
+	i.LegendPlacement = t.LegendPlacement
+	i.LegendSort = t.LegendSort
+	i.LegendNameWidth = t.LegendNameWidth
+	i.HighlightColor = t.HighlightColor
+	i.HighlightLastMove = t.HighlightLastMove
+
+	i.updateRect()
+}
+
 // Width は画像の幅を返す。
 func (img *SquareMapImage) Width() int {
 	return img.rect.Dx()
@@ -60,29 +104,138 @@ func (img *SquareMapImage) Height() int {
 
 // Render はマップを描画する。
 func (i *SquareMapImage) Render() (*image.RGBA, error) {
-	mapImg := image.NewRGBA(i.rect)
-	mapGC := draw2dimg.NewGraphicContext(mapImg)
+	chits := i.chits()
+
+	dest := image.NewRGBA(i.fullRect(chits))
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.FontCache = i.FontCache
+
+	i.draw(gc, chits)
+
+	return dest, nil
+}
+
+// RenderSVG はマップをSVGとして描画する。
+//
+// 文字はパスに変換されるため、閲覧環境にフォントは必要ない。
+func (i *SquareMapImage) RenderSVG() (*draw2dsvg.Svg, error) {
+	chits := i.chits()
+	r := i.fullRect(chits)
+
+	svg := draw2dsvg.NewSvg()
+	svg.Width = toSvgLength(r.Dx())
+	svg.Height = toSvgLength(r.Dy())
+	svg.ViewBox = "0 0 " + toSvgLength(r.Dx()) + " " + toSvgLength(r.Dy())
+
+	gc := draw2dsvg.NewGraphicContext(svg)
+	gc.FontCache = i.FontCache
 
-	i.fillBackGround(mapGC)
-	i.drawGrid(mapGC)
-	i.drawChits(mapGC)
+	i.draw(gc, chits)
 
-	legend, legendErr := i.drawLegend()
-	if legendErr != nil {
-		return nil, legendErr
+	return svg, nil
+}
+
+// toSvgLength は長さをSVGの属性値に変換する。
+func toSvgLength(l int) string {
+	return strconv.Itoa(l)
+}
+
+// chits は描画するチットの配列を返す。
+//
+// 描画中にマップが変更されても描画結果が矛盾しないように、
+// 描画開始時のチットの複製を使用する。
+func (i *SquareMapImage) chits() []*rpgmap.Chit {
+	chits := make([]*rpgmap.Chit, 0, i.Map.NumOfChits())
+	i.Map.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		chits = append(chits, c)
+	})
+
+	return chits
+}
+
+// fullRect は凡例を含めた画像全体の矩形を返す。
+func (i *SquareMapImage) fullRect(chits []*rpgmap.Chit) image.Rectangle {
+	vr := i.viewRect()
+	s := i.scale()
+	layout := i.newLegendLayout(i.chitsInView(chits), vr.Size())
+
+	width := float64(vr.Dx())
+	height := float64(vr.Dy())
+	legendWidth, legendHeight := layout.size()
+	if i.LegendPlacement == LEGEND_RIGHT {
+		width += legendWidth
+		height = math.Max(height, legendHeight)
+	} else {
+		height += legendHeight
 	}
 
-	legendSP := image.Point{0, mapImg.Bounds().Dy()}
-	legendRect := image.Rectangle{legendSP, legendSP.Add(legend.Bounds().Size())}
+	return image.Rect(0, 0, int(math.Ceil(width*s)), int(math.Ceil(height*s)))
+}
 
-	r := image.Rectangle{image.ZP, legendRect.Max}
+// draw はgcにマップと凡例を描画する。
+//
+// 描画範囲が設定されている場合は、その範囲が画像の左上に来るように描画する。
+func (i *SquareMapImage) draw(gc draw2d.GraphicContext, chits []*rpgmap.Chit) {
+	i.fillImage(gc, chits)
 
-	dest := image.NewRGBA(r)
+	i.beginMap(gc)
+	i.drawMapBase(gc)
 
-	draw.Draw(dest, mapImg.Bounds(), mapImg, image.ZP, draw.Src)
-	draw.Draw(dest, legendRect, legend, image.ZP, draw.Src)
+	lastMoved := i.lastMovedChit(chits)
+	if lastMoved != nil {
+		i.drawLastMoveArrow(gc, lastMoved)
+	}
 
-	return dest, nil
+	i.drawChits(gc, chits, nil)
+
+	if lastMoved != nil {
+		i.drawHighlightRing(gc, lastMoved)
+	}
+	gc.Restore()
+
+	i.drawLegendOf(gc, chits)
+}
+
+// fillImage は、凡例の横や下の余白も含めた画像全体を背景色で塗る。
+func (i *SquareMapImage) fillImage(gc draw2d.GraphicContext, chits []*rpgmap.Chit) {
+	r := i.fullRect(chits)
+	gc.SetFillColor(i.BackgroundColor)
+	draw2dkit.Rectangle(gc, 0, 0, float64(r.Dx()), float64(r.Dy()))
+	gc.Fill()
+}
+
+// beginMap は、gcの状態を保存し、描画範囲が画像の左上に来るように座標系を変換する。
+//
+// 描画後に gc.Restore() を呼ぶ必要がある。
+func (i *SquareMapImage) beginMap(gc draw2d.GraphicContext) {
+	vr := i.viewRect()
+	s := i.scale()
+
+	gc.Save()
+	gc.Translate(-float64(vr.Min.X)*s, -float64(vr.Min.Y)*s)
+	gc.Scale(s, s)
+}
+
+// drawMapBase はgcにマップの背景とグリッドを描画する。
+func (i *SquareMapImage) drawMapBase(gc draw2d.GraphicContext) {
+	i.fillBackGround(gc)
+	i.drawBackgroundImage(gc)
+	i.drawGrid(gc)
+}
+
+// drawLegendOf はgcにチットの集合の凡例を描画する。
+func (i *SquareMapImage) drawLegendOf(gc draw2d.GraphicContext, chits []*rpgmap.Chit) {
+	vr := i.viewRect()
+
+	gc.Save()
+	gc.Scale(i.scale(), i.scale())
+	layout := i.newLegendLayout(i.chitsInView(chits), vr.Size())
+	if i.LegendPlacement == LEGEND_RIGHT {
+		i.drawLegend(gc, layout, float64(vr.Dx()), 0)
+	} else {
+		i.drawLegend(gc, layout, 0, float64(vr.Dy()))
+	}
+	gc.Restore()
 }
 
 // 描画領域の矩形を更新する。
@@ -94,7 +247,7 @@ func (i *SquareMapImage) updateRect() {
 }
 
 // fillBackGround はgcを背景色で塗りつぶす。
-func (i *SquareMapImage) fillBackGround(gc *draw2dimg.GraphicContext) {
+func (i *SquareMapImage) fillBackGround(gc draw2d.GraphicContext) {
 	// 背景色で塗る
 	gc.SetFillColor(i.BackgroundColor)
 	draw2dkit.Rectangle(gc, 0, 0, float64(i.Width()), float64(i.Height()))
@@ -102,18 +255,22 @@ func (i *SquareMapImage) fillBackGround(gc *draw2dimg.GraphicContext) {
 }
 
 // drawGrid はgcにグリッドを描画する。
-func (img *SquareMapImage) drawGrid(gc *draw2dimg.GraphicContext) {
+func (img *SquareMapImage) drawGrid(gc draw2d.GraphicContext) {
+	if img.GridLineWidth <= 0 {
+		return
+	}
+
 	gc.SetStrokeColor(img.GridColor)
-	gc.SetLineWidth(1.0)
+	gc.SetLineWidth(img.GridLineWidth)
 
-	for i := 0; i < img.Height(); i++ {
+	for i := 0; i < img.Map.Height(); i++ {
 		y := float64(i * img.GridHeight)
 		gc.MoveTo(0, y)
 		gc.LineTo(float64(img.Width()), y)
 		gc.Stroke()
 	}
 
-	for j := 0; j < img.Width(); j++ {
+	for j := 0; j < img.Map.Width(); j++ {
 		x := float64(j * img.GridWidth)
 		gc.MoveTo(x, 0)
 		gc.LineTo(x, float64(img.Height()))
@@ -124,13 +281,20 @@ func (img *SquareMapImage) drawGrid(gc *draw2dimg.GraphicContext) {
 // drawChits はgcにチットの集合を描画する。
 //
 // TODO: 同じ座標の場合にチットの位置をずらす。
-func (i *SquareMapImage) drawChits(gc *draw2dimg.GraphicContext) {
-	chitSize := int(math.Min(float64(i.GridWidth), float64(i.GridHeight))) / 2
+func (i *SquareMapImage) drawChits(gc draw2d.GraphicContext, chits []*rpgmap.Chit, frame *replayFrame) {
+	chitSize := int(i.chitDiameter())
 	offset := image.Point{X: 0, Y: 0}
 
-	i.Map.ForEachChit(func(_ int, c *rpgmap.Chit) {
-		i.drawChit(gc, c, chitSize, offset)
-	})
+	for _, c := range chits {
+		p := cellPoint{X: float64(c.X), Y: float64(c.Y)}
+		if frame != nil {
+			if framePos, found := frame.positions[c.Name]; found {
+				p = framePos
+			}
+		}
+
+		i.drawChit(gc, c, p, chitSize, offset)
+	}
 }
 
 // chitDrawing はチット描画の情報。
@@ -143,53 +307,38 @@ type chitDrawing struct {
 	Offset image.Point
 }
 
-// drawChit はgcにチットを描画する。
+// drawChit はgcのマス p の位置にチットを描画する。
 func (i *SquareMapImage) drawChit(
-	gc *draw2dimg.GraphicContext,
+	gc draw2d.GraphicContext,
 	chit *rpgmap.Chit,
+	p cellPoint,
 	size int,
 	offset image.Point,
 ) {
-	x := float64(chit.X*i.GridWidth) + float64(i.GridWidth)/2.0 + float64(offset.X)
-	y := float64(chit.Y*i.GridHeight) + float64(i.GridHeight)/2.0 + float64(offset.Y)
+	x, y := i.cellCenter(p)
+	x += float64(offset.X)
+	y += float64(offset.Y)
 	r := float64(size) / 2.0
 
-	gc.SetFillColor(chit.Color)
-	draw2dkit.Circle(gc, x, y, r)
-	gc.Fill()
+	drawChitMarker(gc, chit, x, y, r, i.ChitBorderWidth)
+	i.drawGroupBorder(gc, chit, x, y, r)
 }
 
-// drawLegend は凡例を描画する。
-func (mImg *SquareMapImage) drawLegend() (image.Image, error) {
-	img := image.NewRGBA(image.Rect(0, 0, mImg.Width(), mImg.Map.NumOfChits()*mImg.GridHeight))
-	gc := draw2dimg.NewGraphicContext(img)
-
-	size := math.Min(float64(mImg.GridWidth), float64(mImg.GridHeight)) / 2.0
-	fontSize := 0.8 * size
+// cellPoint はマス単位の座標。アニメーションのため、マスの間の位置も表せる。
+type cellPoint struct {
+	X float64
+	Y float64
+}
 
-	gc.FontCache = mImg.FontCache
-	gc.SetFontData(draw2d.FontData{Name: fontNameForMap})
-	gc.SetFontSize(fontSize)
+// cellCenter はマス p の中心のピクセル単位の座標を返す。
+func (i *SquareMapImage) cellCenter(p cellPoint) (float64, float64) {
+	x := p.X*float64(i.GridWidth) + float64(i.GridWidth)/2.0
+	y := p.Y*float64(i.GridHeight) + float64(i.GridHeight)/2.0
 
-	// 背景色で塗る
-	gc.SetFillColor(mImg.BackgroundColor)
-	draw2dkit.Rectangle(gc, 0, 0, float64(img.Rect.Dx()), float64(img.Rect.Dy()))
-	gc.Fill()
-
-	// 凡例の各行を描画する
-	x := float64(mImg.GridWidth) / 2.0
-	xLabel := float64(mImg.GridWidth)
-	r := size / 2.0
-	mImg.Map.ForEachChit(func(i int, c *rpgmap.Chit) {
-		y := float64(i*mImg.GridHeight) + float64(mImg.GridHeight)/2.0
-		gc.SetFillColor(c.Color)
-		draw2dkit.Circle(gc, x, y, r)
-		gc.Fill()
-
-		yLabel := y + fontSize/2
-		gc.SetFillColor(colorutil.CSS3NameToRGBA("black"))
-		gc.FillStringAt(c.Name, xLabel, yLabel)
-	})
+	return x, y
+}
 
-	return img, nil
+// chitDiameter はチットの直径を返す。
+func (i *SquareMapImage) chitDiameter() float64 {
+	return math.Min(float64(i.GridWidth), float64(i.GridHeight)) * i.ChitScale
 }
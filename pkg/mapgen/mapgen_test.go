@@ -0,0 +1,86 @@
+package mapgen
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// newTestFontCache はテスト用のフォントを格納したフォントキャッシュを返す。
+//
+// マップは各テストで組み立て、フォントの読み込みだけをこの関数で共有する。
+func newTestFontCache(t *testing.T) *FontCache {
+	t.Helper()
+
+	fc := NewFontCache()
+	if err := fc.StoreFontData(goregular.TTF); err != nil {
+		t.Fatalf("failed to load font: %s", err)
+	}
+
+	return fc
+}
+
+func TestSquareMapImage_Render(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(8, 6)
+	m.AddChit(&rpgmap.Chit{Name: "Alice", X: 1, Y: 2, Color: colorutil.CSS3NameToRGBA("red")})
+	m.AddChit(&rpgmap.Chit{Name: "Bob", X: 5, Y: 4, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+
+	i := NewSquareMapImage(m, newTestFontCache(t))
+
+	img, err := i.Render()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	// マップ 8x6 マス + 凡例 1行（2列）
+	if img.Bounds().Dx() != 8*32 || img.Bounds().Dy() != 7*32 {
+		t.Fatalf("size: got %v", img.Bounds().Size())
+	}
+
+	// チットの中心はチットの色で塗られている
+	actual := img.RGBAAt(1*32+16, 2*32+16)
+	expected := colorutil.CSS3NameToRGBA("red")
+	if actual != expected {
+		t.Errorf("chit color: got %v, want %v", actual, expected)
+	}
+
+	// 凡例の印もチットの色で塗られている
+	layout := i.newLegendLayout(i.chits(), image.Pt(i.Width(), i.Height()))
+	actual = img.RGBAAt(int(layout.ColumnWidth)+16, 6*32+16)
+	expected = colorutil.CSS3NameToRGBA("dodgerblue")
+	if actual != expected {
+		t.Errorf("legend color: got %v, want %v", actual, expected)
+	}
+}
+
+func TestSquareMapImage_RenderSVG(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(8, 6)
+	m.AddChit(&rpgmap.Chit{Name: "Alice", X: 1, Y: 2, Color: colorutil.CSS3NameToRGBA("red")})
+	m.AddChit(&rpgmap.Chit{Name: "Bob", X: 5, Y: 4, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+
+	i := NewSquareMapImage(m, newTestFontCache(t))
+
+	svg, err := i.RenderSVG()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if svg.Width != "256" || svg.Height != "224" {
+		t.Errorf("size: got %s x %s, want 256 x 224", svg.Width, svg.Height)
+	}
+
+	b, err := xml.Marshal(svg)
+	if err != nil {
+		t.Fatalf("xml.Marshal: %s", err)
+	}
+
+	if !bytes.Contains(b, []byte(`fill="#FF0000"`)) {
+		t.Error("chit is not drawn")
+	}
+}
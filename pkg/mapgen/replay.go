@@ -0,0 +1,277 @@
+package mapgen
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	// REPLAY_FRAMES_PER_MOVE は、1回の移動を表すアニメーションのフレーム数。
+	REPLAY_FRAMES_PER_MOVE = 6
+	// REPLAY_FRAME_DELAY は、移動中の各フレームの表示時間（1/100秒単位）。
+	REPLAY_FRAME_DELAY = 6
+	// REPLAY_HOLD_DELAY は、最初と最後のフレームの表示時間（1/100秒単位）。
+	REPLAY_HOLD_DELAY = 100
+	// REPLAY_VIEW_PADDING は、描画範囲が設定されていない場合に移動の範囲の周囲に加えるマス数。
+	REPLAY_VIEW_PADDING = 2
+	// REPLAY_MAX_FRAME_PIXELS は1フレームの画素数の上限。
+	REPLAY_MAX_FRAME_PIXELS = 800 * 600
+	// REPLAY_MAX_TOTAL_PIXELS は全フレームの画素数の合計の上限。
+	REPLAY_MAX_TOTAL_PIXELS = 64 * REPLAY_MAX_FRAME_PIXELS
+	// replayTrailAlpha は軌跡の線の不透明度。
+	replayTrailAlpha = 0xA0
+)
+
+// ErrNoMoves は再生するチットの移動がないことを示すエラー。
+var ErrNoMoves = errors.New("再生する移動がありません")
+
+// replayFrame はアニメーションの1フレームの描画情報。
+type replayFrame struct {
+	// positions はチット名 -> 描画する位置の対応。
+	positions map[string]cellPoint
+	// trails は描画する軌跡。
+	trails []replayTrail
+}
+
+// replayTrail はチットの移動の軌跡。
+type replayTrail struct {
+	// Color は軌跡の色。
+	Color color.RGBA
+	// From は始点。
+	From cellPoint
+	// To は終点。
+	To cellPoint
+}
+
+// RenderReplay は、チットの移動を順に再生するアニメーションGIFを描画する。
+//
+// 既に削除されたチットの移動は再生しない。
+// 描画範囲が設定されていない場合は、移動したチットの移動前後の位置を囲む範囲を描画する。
+// 1フレームの画素数が REPLAY_MAX_FRAME_PIXELS を超える場合は縮小し、
+// 全フレームの画素数が REPLAY_MAX_TOTAL_PIXELS を超える場合は1回の移動のフレーム数を減らす。
+func (i *SquareMapImage) RenderReplay(moves []rpgmap.Move) (*gif.GIF, error) {
+	chits := i.chits()
+
+	nameToChit := map[string]*rpgmap.Chit{}
+	for _, c := range chits {
+		nameToChit[c.Name] = c
+	}
+
+	validMoves := make([]rpgmap.Move, 0, len(moves))
+	for _, mv := range moves {
+		if _, found := nameToChit[mv.Name]; found {
+			validMoves = append(validMoves, mv)
+		}
+	}
+
+	if len(validMoves) < 1 {
+		return nil, ErrNoMoves
+	}
+
+	// 描画範囲と拡大率は再生の間だけ変更する
+	origView := i.View
+	origScale := i.Scale
+	defer func() {
+		i.View = origView
+		i.Scale = origScale
+	}()
+
+	if i.View.Empty() {
+		if err := i.fitMoves(validMoves); err != nil {
+			return nil, err
+		}
+	}
+
+	rect := i.fitReplayRect(chits)
+	framesPerMove := replayFramesPerMove(rect, len(validMoves))
+
+	// 移動したチットとそれ以外のチットを分ける
+	moved := map[string]bool{}
+	for _, mv := range validMoves {
+		moved[mv.Name] = true
+	}
+
+	movingChits := make([]*rpgmap.Chit, 0, len(moved))
+	fixedChits := make([]*rpgmap.Chit, 0, len(chits))
+	for _, c := range chits {
+		if moved[c.Name] {
+			movingChits = append(movingChits, c)
+		} else {
+			fixedChits = append(fixedChits, c)
+		}
+	}
+
+	base := i.renderReplayBase(rect, chits, fixedChits)
+
+	// 最初の移動の前の位置に戻す
+	frame := &replayFrame{positions: map[string]cellPoint{}}
+	for k := len(validMoves) - 1; k >= 0; k-- {
+		mv := validMoves[k]
+		frame.positions[mv.Name] = cellPoint{X: float64(mv.FromX), Y: float64(mv.FromY)}
+	}
+
+	g := &gif.GIF{}
+	paletteIndex := map[color.RGBA]uint8{}
+
+	g.Image = append(g.Image, i.renderFrame(base, movingChits, frame, paletteIndex))
+	g.Delay = append(g.Delay, REPLAY_HOLD_DELAY)
+
+	for _, mv := range validMoves {
+		from := cellPoint{X: float64(mv.FromX), Y: float64(mv.FromY)}
+		to := cellPoint{X: float64(mv.ToX), Y: float64(mv.ToY)}
+		completedTrails := frame.trails
+
+		for f := 1; f <= framesPerMove; f++ {
+			t := float64(f) / float64(framesPerMove)
+			p := cellPoint{
+				X: from.X + (to.X-from.X)*t,
+				Y: from.Y + (to.Y-from.Y)*t,
+			}
+
+			frame.positions[mv.Name] = p
+			frame.trails = append(append([]replayTrail{}, completedTrails...), replayTrail{
+				Color: nameToChit[mv.Name].Color,
+				From:  from,
+				To:    p,
+			})
+
+			g.Image = append(g.Image, i.renderFrame(base, movingChits, frame, paletteIndex))
+			g.Delay = append(g.Delay, REPLAY_FRAME_DELAY)
+		}
+	}
+
+	g.Delay[len(g.Delay)-1] = REPLAY_HOLD_DELAY
+
+	return g, nil
+}
+
+// fitMoves は、描画範囲を移動前後の位置を囲む範囲に設定する。
+func (i *SquareMapImage) fitMoves(moves []rpgmap.Move) error {
+	points := make([]*rpgmap.Chit, 0, 2*len(moves))
+	for _, mv := range moves {
+		points = append(points,
+			&rpgmap.Chit{X: mv.FromX, Y: mv.FromY},
+			&rpgmap.Chit{X: mv.ToX, Y: mv.ToY},
+		)
+	}
+
+	return i.FitChits(points, REPLAY_VIEW_PADDING)
+}
+
+// fitReplayRect は、1フレームの画素数が上限以下になるように拡大率を調整し、
+// フレームの矩形を返す。
+func (i *SquareMapImage) fitReplayRect(chits []*rpgmap.Chit) image.Rectangle {
+	rect := i.fullRect(chits)
+	for pixelsOf(rect) > REPLAY_MAX_FRAME_PIXELS {
+		// 矩形の大きさは切り上げられるため、少し余分に縮小する
+		ratio := math.Sqrt(float64(REPLAY_MAX_FRAME_PIXELS) / float64(pixelsOf(rect)))
+		i.Scale = i.scale() * ratio * 0.99
+		rect = i.fullRect(chits)
+	}
+
+	return rect
+}
+
+// replayFramesPerMove は、全フレームの画素数が上限以下になる1回の移動のフレーム数を返す。
+//
+// フレーム数は1以上 REPLAY_FRAMES_PER_MOVE 以下。
+func replayFramesPerMove(rect image.Rectangle, numOfMoves int) int {
+	maxFrames := REPLAY_MAX_TOTAL_PIXELS / pixelsOf(rect)
+	n := (maxFrames - 1) / numOfMoves
+
+	return clampInt(n, 1, REPLAY_FRAMES_PER_MOVE)
+}
+
+// pixelsOf は矩形の画素数を返す。
+func pixelsOf(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// renderReplayBase は、全フレームで共通の背景、グリッド、移動しないチットおよび凡例を描画する。
+func (i *SquareMapImage) renderReplayBase(rect image.Rectangle, chits []*rpgmap.Chit, fixedChits []*rpgmap.Chit) *image.RGBA {
+	base := image.NewRGBA(rect)
+	gc := draw2dimg.NewGraphicContext(base)
+	gc.FontCache = i.FontCache
+
+	i.fillImage(gc, chits)
+
+	i.beginMap(gc)
+	i.drawMapBase(gc)
+	i.drawChits(gc, fixedChits, nil)
+	gc.Restore()
+
+	i.drawLegendOf(gc, chits)
+
+	return base
+}
+
+// renderFrame は、共通部分 base の上に軌跡と移動するチットを描画し、アニメーションの1フレームを返す。
+func (i *SquareMapImage) renderFrame(
+	base *image.RGBA,
+	movingChits []*rpgmap.Chit,
+	frame *replayFrame,
+	paletteIndex map[color.RGBA]uint8,
+) *image.Paletted {
+	rect := base.Bounds()
+
+	dest := image.NewRGBA(rect)
+	copy(dest.Pix, base.Pix)
+
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.FontCache = i.FontCache
+
+	i.beginMap(gc)
+	i.drawTrails(gc, frame.trails)
+	i.drawChits(gc, movingChits, frame)
+	gc.Restore()
+
+	return toPaletted(dest, paletteIndex)
+}
+
+// toPaletted は画像を palette.Plan9 で減色する。
+//
+// 各フレームの色の種類は少ないため、色 -> パレットの番号の対応を index に記録して再利用する。
+func toPaletted(src *image.RGBA, index map[color.RGBA]uint8) *image.Paletted {
+	rect := src.Bounds()
+	p := image.NewPaletted(rect, palette.Plan9)
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := src.RGBAAt(x, y)
+			n, found := index[c]
+			if !found {
+				n = uint8(p.Palette.Index(c))
+				index[c] = n
+			}
+
+			p.SetColorIndex(x, y, n)
+		}
+	}
+
+	return p
+}
+
+// drawTrails はgcにチットの移動の軌跡を描画する。
+func (i *SquareMapImage) drawTrails(gc draw2d.GraphicContext, trails []replayTrail) {
+	width := math.Max(1.0, math.Min(float64(i.GridWidth), float64(i.GridHeight))/8.0)
+
+	gc.SetLineWidth(width)
+	for _, t := range trails {
+		gc.SetStrokeColor(color.NRGBA{t.Color.R, t.Color.G, t.Color.B, replayTrailAlpha})
+
+		x1, y1 := i.cellCenter(t.From)
+		x2, y2 := i.cellCenter(t.To)
+		gc.MoveTo(x1, y1)
+		gc.LineTo(x2, y2)
+		gc.Stroke()
+	}
+}
@@ -0,0 +1,146 @@
+package mapgen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapImage_RenderReplay(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(6, 4)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0, Color: colorutil.CSS3NameToRGBA("red")})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 5, Y: 3, Color: colorutil.CSS3NameToRGBA("blue")})
+	m.MoveChit("A", 3, 0)
+	m.MoveChit("B", 5, 1)
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	g, err := mImg.RenderReplay(m.LastMoves(10))
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expectedFrames := 1 + 2*REPLAY_FRAMES_PER_MOVE
+	if len(g.Image) != expectedFrames {
+		t.Fatalf("len(Image): got %d, want %d", len(g.Image), expectedFrames)
+	}
+
+	if len(g.Delay) != len(g.Image) {
+		t.Fatalf("len(Delay): got %d, want %d", len(g.Delay), len(g.Image))
+	}
+
+	if g.Delay[0] != REPLAY_HOLD_DELAY || g.Delay[len(g.Delay)-1] != REPLAY_HOLD_DELAY {
+		t.Errorf("Delay: got %v", g.Delay)
+	}
+
+	// 最初のフレームではチットAは移動前の位置にある
+	first := g.Image[0]
+	if !isReddish(first.At(16, 16)) {
+		t.Errorf("first frame: chit A is not at (0, 0): %v", first.At(16, 16))
+	}
+
+	// 最後のフレームではチットAは移動後の位置にある
+	last := g.Image[len(g.Image)-1]
+	if !isReddish(last.At(3*32+16, 16)) {
+		t.Errorf("last frame: chit A is not at (3, 0): %v", last.At(3*32+16, 16))
+	}
+}
+
+// isReddish は、減色後の色が赤に近いかを返す。
+func isReddish(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r>>8 >= 0xC0 && g>>8 < 0x40 && b>>8 < 0x40
+}
+
+func TestSquareMapImage_RenderReplay_NoMoves(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(6, 4)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0})
+	m.MoveChit("A", 1, 0)
+	m.DeleteChit("A")
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	if _, err := mImg.RenderReplay(m.LastMoves(10)); err != ErrNoMoves {
+		t.Errorf("got: %v, want: %v", err, ErrNoMoves)
+	}
+}
+
+// MAX_TEST_REPLAY_MOVES は、大きなマップのテストで再生する移動数。
+const MAX_TEST_REPLAY_MOVES = 20
+
+func TestSquareMapImage_RenderReplay_LargeMap(t *testing.T) {
+	testcases := []struct {
+		Name string
+		// Target は、k回目の移動の移動先を返す。
+		Target func(k int) (int, int)
+		// Reduced は、1回の移動のフレーム数が減らされるかどうか。
+		Reduced           bool
+		ExpectedMaxWidth  int
+		ExpectedMaxHeight int
+	}{
+		{
+			// 移動の範囲 (0, 0)-(20, 1) に周囲2マスを加えた範囲を描画する
+			Name: "fit to moves",
+			Target: func(k int) (int, int) {
+				return k, k % 2
+			},
+			Reduced:           false,
+			ExpectedMaxWidth:  (21 + 2) * 32,
+			ExpectedMaxHeight: 400,
+		},
+		{
+			// 移動がマップ全体に広がる場合は半分以下に縮小し、フレーム数を減らす
+			Name: "whole map",
+			Target: func(k int) (int, int) {
+				return k % 2 * 59, k % 2 * 39
+			},
+			Reduced:           true,
+			ExpectedMaxWidth:  60 * 32 / 2,
+			ExpectedMaxHeight: 40 * 32 / 2,
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(60, 40)
+			m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0, Color: colorutil.CSS3NameToRGBA("red")})
+			m.AddChit(&rpgmap.Chit{Name: "B", X: 59, Y: 39, Color: colorutil.CSS3NameToRGBA("blue")})
+			for k := 1; k <= MAX_TEST_REPLAY_MOVES; k++ {
+				x, y := test.Target(k)
+				m.MoveChit("A", x, y)
+			}
+
+			mImg := NewSquareMapImage(m, newTestFontCache(t))
+			g, err := mImg.RenderReplay(m.LastMoves(MAX_TEST_REPLAY_MOVES))
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			fullFrames := 1 + MAX_TEST_REPLAY_MOVES*REPLAY_FRAMES_PER_MOVE
+			if test.Reduced {
+				if len(g.Image) < 1+MAX_TEST_REPLAY_MOVES || len(g.Image) >= fullFrames {
+					t.Errorf("len(Image): got %d, want %d..%d", len(g.Image), 1+MAX_TEST_REPLAY_MOVES, fullFrames-1)
+				}
+			} else if len(g.Image) != fullFrames {
+				t.Errorf("len(Image): got %d, want %d", len(g.Image), fullFrames)
+			}
+
+			r := g.Image[0].Bounds()
+			if r.Dx() > test.ExpectedMaxWidth || r.Dy() > test.ExpectedMaxHeight {
+				t.Errorf("frame size: got %v, want <= (%d, %d)", r.Size(), test.ExpectedMaxWidth, test.ExpectedMaxHeight)
+			}
+
+			if r.Dx()*r.Dy() > REPLAY_MAX_FRAME_PIXELS {
+				t.Errorf("frame size %v exceeds %d pixels", r.Size(), REPLAY_MAX_FRAME_PIXELS)
+			}
+
+			if total := len(g.Image) * r.Dx() * r.Dy(); total > REPLAY_MAX_TOTAL_PIXELS {
+				t.Errorf("total pixels %d exceeds %d", total, REPLAY_MAX_TOTAL_PIXELS)
+			}
+
+			if !mImg.View.Empty() || mImg.Scale != 0 {
+				t.Errorf("View and Scale are not restored: %v, %f", mImg.View, mImg.Scale)
+			}
+		})
+	}
+}
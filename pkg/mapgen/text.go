@@ -0,0 +1,158 @@
+package mapgen
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+const (
+	// textCellWidth は文字で描画する際の1マスの幅（半角文字数）。
+	textCellWidth = 3
+	// textEmptyCell は何もないマスを表す文字。
+	textEmptyCell = "."
+	// textMultipleChits は複数のチットがあるマスを表す文字。
+	textMultipleChits = "*"
+
+	// escReset は書式設定をリセットするエスケープシーケンス。
+	escReset = "\033[0m"
+	// escDim は文字を薄くするエスケープシーケンス。
+	escDim = "\033[2m"
+)
+
+// SquareMapText はスクエアマップを文字で描画するための情報の構造体。
+type SquareMapText struct {
+	// Map は描画対象のスクエアマップ。
+	Map *rpgmap.SquareMap
+	// Colored は、ANSIエスケープシーケンスでチットに色を付けるかどうか。
+	//
+	// 色は24ビットカラーで指定するため、対応した端末が必要。
+	Colored bool
+}
+
+// NewSquareMapText は新しいスクエアマップの文字描画情報を返す。
+func NewSquareMapText(m *rpgmap.SquareMap) *SquareMapText {
+	return &SquareMapText{
+		Map:     m,
+		Colored: false,
+	}
+}
+
+// Render はマップを文字で描画する。
+//
+// 各マスにはチットの名前の最初の文字を表示する。
+// マップの下には凡例としてチットの一覧を出力する。
+func (t *SquareMapText) Render() string {
+	chits := []*rpgmap.Chit{}
+	t.Map.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		chits = append(chits, c)
+	})
+
+	// 座標 -> チットの対応を作る
+	cells := make([][]*rpgmap.Chit, t.Map.Width()*t.Map.Height())
+	for _, c := range chits {
+		i := c.Y*t.Map.Width() + c.X
+		cells[i] = append(cells[i], c)
+	}
+
+	var b strings.Builder
+
+	// 列番号
+	b.WriteString(strings.Repeat(" ", textCellWidth))
+	for x := 0; x < t.Map.Width(); x++ {
+		fmt.Fprintf(&b, "%*d", textCellWidth, x+1)
+	}
+	b.WriteString("\n")
+
+	for y := 0; y < t.Map.Height(); y++ {
+		fmt.Fprintf(&b, "%*d", textCellWidth, y+1)
+
+		for x := 0; x < t.Map.Width(); x++ {
+			t.writeCell(&b, cells[y*t.Map.Width()+x])
+		}
+
+		b.WriteString("\n")
+	}
+
+	// 凡例
+	for _, c := range chits {
+		b.WriteString("\n")
+		b.WriteString(t.colorize(chitInitial(c), c.Color))
+		b.WriteString(": ")
+		b.WriteString(c.String())
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeCell はbに1マス分の文字を書き込む。
+func (t *SquareMapText) writeCell(b *strings.Builder, chits []*rpgmap.Chit) {
+	switch len(chits) {
+	case 0:
+		b.WriteString(strings.Repeat(" ", textCellWidth-1))
+		if t.Colored {
+			b.WriteString(escDim + textEmptyCell + escReset)
+		} else {
+			b.WriteString(textEmptyCell)
+		}
+	case 1:
+		initial := chitInitial(chits[0])
+		b.WriteString(strings.Repeat(" ", textCellWidth-stringWidth(initial)))
+		b.WriteString(t.colorize(initial, chits[0].Color))
+	default:
+		b.WriteString(strings.Repeat(" ", textCellWidth-1))
+		b.WriteString(textMultipleChits)
+	}
+}
+
+// colorize は、色付きで描画する場合は文字列sに色cを付けて返す。
+func (t *SquareMapText) colorize(s string, c color.RGBA) string {
+	if !t.Colored {
+		return s
+	}
+
+	return fmt.Sprintf("\033[1;38;2;%d;%d;%dm%s%s", c.R, c.G, c.B, s, escReset)
+}
+
+// chitInitial はチットの名前の最初の文字を返す。
+func chitInitial(c *rpgmap.Chit) string {
+	r, _ := utf8.DecodeRuneInString(c.Name)
+	if r == utf8.RuneError {
+		return "?"
+	}
+
+	return string(r)
+}
+
+// stringWidth は、端末に表示したときの文字列の幅（半角文字数）を返す。
+func stringWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		if isWideRune(r) {
+			w += 2
+		} else {
+			w++
+		}
+	}
+
+	return w
+}
+
+// isWideRune は、全角幅で表示される文字かどうかを返す。
+//
+// 日本語の名前で使われる主な文字のみを判定する簡易的な実装。
+func isWideRune(r rune) bool {
+	if r >= 0xFF61 && r <= 0xFFDC {
+		// 半角カナ
+		return false
+	}
+
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || // CJKの記号と句読点
+		(r >= 0xFF01 && r <= 0xFF60) || // 全角英数字・記号
+		(r >= 0xFFE0 && r <= 0xFFE6)
+}
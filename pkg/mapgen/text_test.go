@@ -0,0 +1,75 @@
+package mapgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapText_Render(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(4, 3)
+	m.AddChit(&rpgmap.Chit{Name: "Alice", X: 0, Y: 0})
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 2, Y: 1})
+	m.AddChit(&rpgmap.Chit{Name: "Bob", X: 3, Y: 2})
+	m.AddChit(&rpgmap.Chit{Name: "Carol", X: 3, Y: 2})
+
+	expected := strings.Join([]string{
+		"     1  2  3  4",
+		"  1  A  .  .  .",
+		"  2  .  . ゆ  .",
+		"  3  .  .  .  *",
+		"",
+		"A: Alice (1, 1)",
+		"ゆ: ゆうしゃ (3, 2)",
+		"B: Bob (4, 3)",
+		"C: Carol (4, 3)",
+	}, "\n")
+
+	actual := NewSquareMapText(m).Render()
+	if actual != expected {
+		t.Fatalf("got:\n%s\nwant:\n%s", actual, expected)
+	}
+}
+
+func TestSquareMapText_Render_Colored(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(2, 2)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 1, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+
+	mText := NewSquareMapText(m)
+	mText.Colored = true
+
+	actual := mText.Render()
+	expected := "\033[1;38;2;30;144;255mA\033[0m"
+	if !strings.Contains(actual, "  "+expected+"\n") {
+		t.Fatalf("colored chit not found: %q", actual)
+	}
+
+	if !strings.Contains(actual, "\n"+expected+": A (2, 2)") {
+		t.Fatalf("colored legend not found: %q", actual)
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	testcases := []struct {
+		Input    string
+		expected int
+	}{
+		{Input: "A", expected: 1},
+		{Input: "ゆ", expected: 2},
+		{Input: "勇", expected: 2},
+		{Input: "ア", expected: 2},
+		{Input: "Ａ", expected: 2},
+		{Input: "ｱ", expected: 1},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Input, func(t *testing.T) {
+			actual := stringWidth(test.Input)
+			if actual != test.expected {
+				t.Fatalf("got: %d, want: %d", actual, test.expected)
+			}
+		})
+	}
+}
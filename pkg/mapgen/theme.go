@@ -0,0 +1,425 @@
+package mapgen
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+)
+
+const (
+	// THEME_DEFAULT は既定のテーマの名前。
+	THEME_DEFAULT = "default"
+	// THEME_DARK は暗い背景のテーマの名前。
+	THEME_DARK = "dark"
+
+	// MIN_CELL_SIZE は1マスの大きさの最小値。
+	MIN_CELL_SIZE = 8
+	// MAX_CELL_SIZE は1マスの大きさの最大値。
+	MAX_CELL_SIZE = 256
+	// MAX_GRID_LINE_WIDTH はグリッドの線の太さの最大値。
+	MAX_GRID_LINE_WIDTH = 16
+	// MAX_CHIT_BORDER_WIDTH は画像付きのチットの縁取りの太さの最大値。
+	MAX_CHIT_BORDER_WIDTH = 16
+	// MIN_CHIT_SCALE はチットの直径の比率の最小値。
+	MIN_CHIT_SCALE = 0.1
+	// MAX_CHIT_SCALE はチットの直径の比率の最大値。
+	MAX_CHIT_SCALE = 1
+	// MAX_LEGEND_FONT_SIZE は凡例の文字の大きさの最大値。
+	MAX_LEGEND_FONT_SIZE = 128
+	// MAX_LEGEND_NAME_WIDTH は凡例に表示する名前の最大の幅（マス単位）の上限。
+	MAX_LEGEND_NAME_WIDTH = 64
+)
+
+// テーマの設定項目の名前。
+const (
+	THEME_KEY_BACKGROUND  = "background"
+	THEME_KEY_GRID        = "grid"
+	THEME_KEY_TEXT        = "text"
+	THEME_KEY_CELL        = "cell"
+	THEME_KEY_LINE        = "line"
+	THEME_KEY_BORDER      = "border"
+	THEME_KEY_CHIT        = "chit"
+	THEME_KEY_LEGEND_FONT = "font"
+	THEME_KEY_LEGEND      = "legend"
+	THEME_KEY_SORT        = "sort"
+	THEME_KEY_NAME_WIDTH  = "namewidth"
+	THEME_KEY_HIGHLIGHT   = "highlight"
+	THEME_KEY_LAST_MOVE   = "lastmove"
+)
+
+// ThemeKeys はテーマの設定項目の名前の一覧。
+var ThemeKeys = []string{
+	THEME_KEY_BACKGROUND,
+	THEME_KEY_GRID,
+	THEME_KEY_TEXT,
+	THEME_KEY_CELL,
+	THEME_KEY_LINE,
+	THEME_KEY_BORDER,
+	THEME_KEY_CHIT,
+	THEME_KEY_LEGEND_FONT,
+	THEME_KEY_LEGEND,
+	THEME_KEY_SORT,
+	THEME_KEY_NAME_WIDTH,
+	THEME_KEY_HIGHLIGHT,
+	THEME_KEY_LAST_MOVE,
+}
+
+// Theme はマップの描画の見た目の設定の構造体。
+type Theme struct {
+	// BackgroundColor はマップと凡例の背景色。
+	BackgroundColor color.RGBA
+	// GridColor はグリッドの線の色。
+	GridColor color.RGBA
+	// LegendTextColor は凡例の文字の色。
+	LegendTextColor color.RGBA
+	// CellSize は1マスの大きさ（ピクセル）。
+	CellSize int
+	// GridLineWidth はグリッドの線の太さ。
+	GridLineWidth float64
+	// ChitBorderWidth は画像付きのチットの縁取りの太さ。0の場合はチットの大きさから決める。
+	ChitBorderWidth float64
+	// ChitScale は1マスの大きさに対するチットの直径の比率。
+	ChitScale float64
+	// LegendFontSize は凡例の文字の大きさ。0の場合は1マスの大きさから決める。
+	LegendFontSize float64
+	// LegendPlacement は凡例の配置（LEGEND_BELOW または LEGEND_RIGHT）。
+	LegendPlacement string
+	// LegendSort は凡例の並べ方。
+	LegendSort string
+	// LegendNameWidth は凡例に表示する名前の最大の幅（マス単位）。長い名前は折り返す。0の場合は折り返さない。
+	LegendNameWidth float64
+	// HighlightColor は直前に移動したチットを強調する色。
+	HighlightColor color.RGBA
+	// HighlightLastMove は、直前に移動したチットの移動を矢印と輪で強調するかどうか。
+	HighlightLastMove bool
+}
+
+// DefaultTheme は既定のテーマを返す。
+func DefaultTheme() *Theme {
+	return &Theme{
+		BackgroundColor:   colorutil.CSS3NameToRGBA("white"),
+		GridColor:         colorutil.CSS3NameToRGBA("dimgray"),
+		LegendTextColor:   colorutil.CSS3NameToRGBA("black"),
+		CellSize:          32,
+		GridLineWidth:     1.0,
+		ChitBorderWidth:   0,
+		ChitScale:         0.5,
+		LegendFontSize:    0,
+		LegendPlacement:   LEGEND_BELOW,
+		LegendSort:        LEGEND_SORT_INSERTION,
+		LegendNameWidth:   6,
+		HighlightColor:    colorutil.CSS3NameToRGBA("gold"),
+		HighlightLastMove: true,
+	}
+}
+
+// DarkTheme は暗い背景のテーマを返す。
+func DarkTheme() *Theme {
+	t := DefaultTheme()
+	t.BackgroundColor = color.RGBA{0x1E, 0x1E, 0x1E, 0xFF}
+	t.GridColor = color.RGBA{0x6A, 0x6A, 0x6A, 0xFF}
+	t.LegendTextColor = color.RGBA{0xE0, 0xE0, 0xE0, 0xFF}
+
+	return t
+}
+
+// themePresets はテーマ名 -> テーマを返す関数の対応。
+var themePresets = map[string]func() *Theme{
+	THEME_DEFAULT: DefaultTheme,
+	THEME_DARK:    DarkTheme,
+}
+
+// ThemePreset は名前に対応する定義済みのテーマを返す。
+func ThemePreset(name string) (*Theme, bool) {
+	f, found := themePresets[name]
+	if !found {
+		return nil, false
+	}
+
+	return f(), true
+}
+
+// ThemePresetNames は定義済みのテーマの名前を昇順に並べて返す。
+func ThemePresetNames() []string {
+	names := make([]string, 0, len(themePresets))
+	for name := range themePresets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Clone はテーマの複製を返す。
+func (t *Theme) Clone() *Theme {
+	copied := *t
+	return &copied
+}
+
+// Validate はテーマの設定値が有効かを確かめる。
+func (t *Theme) Validate() error {
+	if t.CellSize < MIN_CELL_SIZE || t.CellSize > MAX_CELL_SIZE {
+		return fmt.Errorf("cell size out of range (%d-%d): %d", MIN_CELL_SIZE, MAX_CELL_SIZE, t.CellSize)
+	}
+
+	if err := checkThemeFloat("grid line width", t.GridLineWidth, 0, MAX_GRID_LINE_WIDTH); err != nil {
+		return err
+	}
+
+	if err := checkThemeFloat("chit border width", t.ChitBorderWidth, 0, MAX_CHIT_BORDER_WIDTH); err != nil {
+		return err
+	}
+
+	if err := checkThemeFloat("chit scale", t.ChitScale, MIN_CHIT_SCALE, MAX_CHIT_SCALE); err != nil {
+		return err
+	}
+
+	if err := checkThemeFloat("legend font size", t.LegendFontSize, 0, MAX_LEGEND_FONT_SIZE); err != nil {
+		return err
+	}
+
+	if !containsString(LegendPlacements, t.LegendPlacement) {
+		return fmt.Errorf("unknown legend placement: %s", t.LegendPlacement)
+	}
+
+	if !containsString(LegendSorts, t.LegendSort) {
+		return fmt.Errorf("unknown legend sort: %s", t.LegendSort)
+	}
+
+	if err := checkThemeFloat("legend name width", t.LegendNameWidth, 0, MAX_LEGEND_NAME_WIDTH); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkThemeFloat は、実数の設定値が有限で min 以上 max 以下かを確かめる。
+func checkThemeFloat(name string, v float64, min float64, max float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("invalid %s: %g", name, v)
+	}
+
+	if v < min || v > max {
+		return fmt.Errorf("%s out of range (%g-%g): %g", name, min, max, v)
+	}
+
+	return nil
+}
+
+// Set は名前で指定された設定項目に文字列で表された値を設定する。
+//
+// 値が無効な場合はテーマを変更せずにエラーを返す。
+func (t *Theme) Set(key string, value string) error {
+	newTheme := t.Clone()
+
+	var err error
+	switch key {
+	case THEME_KEY_BACKGROUND:
+		newTheme.BackgroundColor, err = colorutil.ParseColor(value)
+	case THEME_KEY_GRID:
+		newTheme.GridColor, err = colorutil.ParseColor(value)
+	case THEME_KEY_TEXT:
+		newTheme.LegendTextColor, err = colorutil.ParseColor(value)
+	case THEME_KEY_CELL:
+		newTheme.CellSize, err = strconv.Atoi(value)
+	case THEME_KEY_LINE:
+		newTheme.GridLineWidth, err = strconv.ParseFloat(value, 64)
+	case THEME_KEY_BORDER:
+		newTheme.ChitBorderWidth, err = strconv.ParseFloat(value, 64)
+	case THEME_KEY_CHIT:
+		newTheme.ChitScale, err = strconv.ParseFloat(value, 64)
+	case THEME_KEY_LEGEND_FONT:
+		newTheme.LegendFontSize, err = strconv.ParseFloat(value, 64)
+	case THEME_KEY_LEGEND:
+		newTheme.LegendPlacement = value
+	case THEME_KEY_SORT:
+		newTheme.LegendSort = value
+	case THEME_KEY_NAME_WIDTH:
+		newTheme.LegendNameWidth, err = strconv.ParseFloat(value, 64)
+	case THEME_KEY_HIGHLIGHT:
+		newTheme.HighlightColor, err = colorutil.ParseColor(value)
+	case THEME_KEY_LAST_MOVE:
+		newTheme.HighlightLastMove, err = parseOnOff(value)
+	default:
+		return fmt.Errorf("unknown theme key: %s", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: invalid value: %s", key, value)
+	}
+
+	if err := newTheme.Validate(); err != nil {
+		return err
+	}
+
+	*t = *newTheme
+
+	return nil
+}
+
+// Get は名前で指定された設定項目の値を文字列で返す。
+func (t *Theme) Get(key string) (string, bool) {
+	switch key {
+	case THEME_KEY_BACKGROUND:
+		return colorutil.RGBAToHex(t.BackgroundColor), true
+	case THEME_KEY_GRID:
+		return colorutil.RGBAToHex(t.GridColor), true
+	case THEME_KEY_TEXT:
+		return colorutil.RGBAToHex(t.LegendTextColor), true
+	case THEME_KEY_CELL:
+		return strconv.Itoa(t.CellSize), true
+	case THEME_KEY_LINE:
+		return formatThemeFloat(t.GridLineWidth), true
+	case THEME_KEY_BORDER:
+		return formatThemeFloat(t.ChitBorderWidth), true
+	case THEME_KEY_CHIT:
+		return formatThemeFloat(t.ChitScale), true
+	case THEME_KEY_LEGEND_FONT:
+		return formatThemeFloat(t.LegendFontSize), true
+	case THEME_KEY_LEGEND:
+		return t.LegendPlacement, true
+	case THEME_KEY_SORT:
+		return t.LegendSort, true
+	case THEME_KEY_NAME_WIDTH:
+		return formatThemeFloat(t.LegendNameWidth), true
+	case THEME_KEY_HIGHLIGHT:
+		return colorutil.RGBAToHex(t.HighlightColor), true
+	case THEME_KEY_LAST_MOVE:
+		if t.HighlightLastMove {
+			return "on", true
+		}
+
+		return "off", true
+	default:
+		return "", false
+	}
+}
+
+// formatThemeFloat はテーマの実数の設定値を文字列に変換する。
+func formatThemeFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// containsString は、文字列の配列に s が含まれているかを返す。
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseOnOff は "on" または "off" を真偽値に変換する。
+func parseOnOff(value string) (bool, error) {
+	switch value {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid on/off value: %s", value)
+	}
+}
+
+// ThemeConfig は設定ファイルに書くテーマの設定の構造体。
+//
+// 省略した項目には Base で指定したテーマの値が使われる。
+// 0 が有効な値である項目は、省略と区別するためにポインタで表す。
+type ThemeConfig struct {
+	// Base は元にするテーマの名前。省略すると既定のテーマを使う。
+	Base string
+	// BackgroundColor はマップと凡例の背景色（CSS3の色名または #RRGGBB 形式）。
+	BackgroundColor string
+	// GridColor はグリッドの線の色。
+	GridColor string
+	// LegendTextColor は凡例の文字の色。
+	LegendTextColor string
+	// CellSize は1マスの大きさ（ピクセル）。
+	CellSize int
+	// GridLineWidth はグリッドの線の太さ。
+	GridLineWidth *float64
+	// ChitBorderWidth は画像付きのチットの縁取りの太さ。
+	ChitBorderWidth *float64
+	// ChitScale は1マスの大きさに対するチットの直径の比率。
+	ChitScale float64
+	// LegendFontSize は凡例の文字の大きさ。
+	LegendFontSize *float64
+	// LegendPlacement は凡例の配置（"below" または "right"）。
+	LegendPlacement string
+	// LegendSort は凡例の並べ方（"insertion"、"name"、"color" または "initiative"）。
+	LegendSort string
+	// LegendNameWidth は凡例に表示する名前の最大の幅（マス単位）。
+	LegendNameWidth *float64
+	// HighlightColor は直前に移動したチットを強調する色。
+	HighlightColor string
+	// HighlightLastMove は、直前に移動したチットの移動を強調するかどうか。
+	HighlightLastMove *bool
+}
+
+// Theme は設定からテーマを構築する。
+func (c *ThemeConfig) Theme() (*Theme, error) {
+	base := c.Base
+	if base == "" {
+		base = THEME_DEFAULT
+	}
+
+	t, found := ThemePreset(base)
+	if !found {
+		return nil, fmt.Errorf("unknown theme: %s", base)
+	}
+
+	var err error
+	set := func(key string, value string) {
+		if err == nil && value != "" {
+			err = t.Set(key, value)
+		}
+	}
+
+	set(THEME_KEY_BACKGROUND, c.BackgroundColor)
+	set(THEME_KEY_GRID, c.GridColor)
+	set(THEME_KEY_TEXT, c.LegendTextColor)
+	set(THEME_KEY_LEGEND, c.LegendPlacement)
+	set(THEME_KEY_SORT, c.LegendSort)
+	set(THEME_KEY_HIGHLIGHT, c.HighlightColor)
+
+	if c.CellSize != 0 {
+		set(THEME_KEY_CELL, strconv.Itoa(c.CellSize))
+	}
+
+	if c.GridLineWidth != nil {
+		set(THEME_KEY_LINE, formatThemeFloat(*c.GridLineWidth))
+	}
+
+	if c.ChitBorderWidth != nil {
+		set(THEME_KEY_BORDER, formatThemeFloat(*c.ChitBorderWidth))
+	}
+
+	if c.ChitScale != 0 {
+		set(THEME_KEY_CHIT, formatThemeFloat(c.ChitScale))
+	}
+
+	if c.LegendFontSize != nil {
+		set(THEME_KEY_LEGEND_FONT, formatThemeFloat(*c.LegendFontSize))
+	}
+
+	if c.LegendNameWidth != nil {
+		set(THEME_KEY_NAME_WIDTH, formatThemeFloat(*c.LegendNameWidth))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("theme: %s", err)
+	}
+
+	if c.HighlightLastMove != nil {
+		t.HighlightLastMove = *c.HighlightLastMove
+	}
+
+	return t, nil
+}
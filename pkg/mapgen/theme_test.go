@@ -0,0 +1,176 @@
+package mapgen
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestTheme_Set(t *testing.T) {
+	testcases := []struct {
+		Key      string
+		Value    string
+		Expected string
+		Err      bool
+	}{
+		{Key: THEME_KEY_BACKGROUND, Value: "black", Expected: "#000000"},
+		{Key: THEME_KEY_GRID, Value: "#123456", Expected: "#123456"},
+		{Key: THEME_KEY_TEXT, Value: "nocolor", Err: true},
+		{Key: THEME_KEY_CELL, Value: "48", Expected: "48"},
+		{Key: THEME_KEY_CELL, Value: "4", Err: true},
+		{Key: THEME_KEY_CELL, Value: "big", Err: true},
+		{Key: THEME_KEY_LINE, Value: "2.5", Expected: "2.5"},
+		{Key: THEME_KEY_LINE, Value: "-1", Err: true},
+		{Key: THEME_KEY_LINE, Value: "17", Err: true},
+		{Key: THEME_KEY_LINE, Value: "NaN", Err: true},
+		{Key: THEME_KEY_BORDER, Value: "3", Expected: "3"},
+		{Key: THEME_KEY_BORDER, Value: "100", Err: true},
+		{Key: THEME_KEY_BORDER, Value: "Inf", Err: true},
+		{Key: THEME_KEY_CHIT, Value: "0.75", Expected: "0.75"},
+		{Key: THEME_KEY_CHIT, Value: "1.5", Err: true},
+		{Key: THEME_KEY_CHIT, Value: "0", Err: true},
+		{Key: THEME_KEY_CHIT, Value: "0.01", Err: true},
+		{Key: THEME_KEY_CHIT, Value: "NaN", Err: true},
+		{Key: THEME_KEY_LEGEND_FONT, Value: "20", Expected: "20"},
+		{Key: THEME_KEY_LEGEND_FONT, Value: "100000", Err: true},
+		{Key: THEME_KEY_LEGEND_FONT, Value: "Inf", Err: true},
+		{Key: THEME_KEY_LEGEND_FONT, Value: "-Inf", Err: true},
+		{Key: THEME_KEY_LEGEND_FONT, Value: "NaN", Err: true},
+		{Key: THEME_KEY_LEGEND, Value: "right", Expected: "right"},
+		{Key: THEME_KEY_LEGEND, Value: "left", Err: true},
+		{Key: THEME_KEY_SORT, Value: "initiative", Expected: "initiative"},
+		{Key: THEME_KEY_SORT, Value: "size", Err: true},
+		{Key: THEME_KEY_NAME_WIDTH, Value: "0", Expected: "0"},
+		{Key: THEME_KEY_NAME_WIDTH, Value: "-2", Err: true},
+		{Key: THEME_KEY_NAME_WIDTH, Value: "1000", Err: true},
+		{Key: THEME_KEY_NAME_WIDTH, Value: "+Inf", Err: true},
+		{Key: THEME_KEY_HIGHLIGHT, Value: "orange", Expected: "#ffa500"},
+		{Key: THEME_KEY_LAST_MOVE, Value: "off", Expected: "off"},
+		{Key: THEME_KEY_LAST_MOVE, Value: "no", Err: true},
+		{Key: "size", Value: "1", Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Key+" "+test.Value, func(t *testing.T) {
+			theme := DefaultTheme()
+			err := theme.Set(test.Key, test.Value)
+			if test.Err {
+				if err == nil {
+					t.Fatal("expected err")
+				}
+
+				if *theme != *DefaultTheme() {
+					t.Errorf("theme is changed: %+v", theme)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			actual, _ := theme.Get(test.Key)
+			if actual != test.Expected {
+				t.Errorf("got: %s, want: %s", actual, test.Expected)
+			}
+		})
+	}
+}
+
+func TestThemeConfig_Theme(t *testing.T) {
+	c := ThemeConfig{
+		Base:      THEME_DARK,
+		GridColor: "red",
+		CellSize:  40,
+	}
+
+	theme, err := c.Theme()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expected := DarkTheme()
+	expected.GridColor = color.RGBA{0xFF, 0, 0, 0xFF}
+	expected.CellSize = 40
+
+	if *theme != *expected {
+		t.Errorf("got: %+v, want: %+v", theme, expected)
+	}
+}
+
+func TestThemeConfig_Theme_Zero(t *testing.T) {
+	var c ThemeConfig
+	input := "gridLineWidth = 0.0\nchitBorderWidth = 0.0\nlegendFontSize = 0.0\nlegendNameWidth = 0.0\n"
+	if _, err := toml.Decode(input, &c); err != nil {
+		t.Fatalf("decode err: %s", err)
+	}
+
+	theme, err := c.Theme()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expected := DefaultTheme()
+	expected.GridLineWidth = 0
+	expected.ChitBorderWidth = 0
+	expected.LegendFontSize = 0
+	expected.LegendNameWidth = 0
+
+	if *theme != *expected {
+		t.Errorf("got: %+v, want: %+v", theme, expected)
+	}
+}
+
+// floatPtr は v へのポインタを返す。
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestThemeConfig_Theme_Invalid(t *testing.T) {
+	testcases := []struct {
+		Name   string
+		Config ThemeConfig
+	}{
+		{Name: "unknown base", Config: ThemeConfig{Base: "sepia"}},
+		{Name: "unknown color", Config: ThemeConfig{BackgroundColor: "nocolor"}},
+		{Name: "too large cell", Config: ThemeConfig{CellSize: 1000}},
+		{Name: "too large font", Config: ThemeConfig{LegendFontSize: floatPtr(100000)}},
+		{Name: "infinite line width", Config: ThemeConfig{GridLineWidth: floatPtr(math.Inf(1))}},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			if _, err := test.Config.Theme(); err == nil {
+				t.Fatal("expected err")
+			}
+		})
+	}
+}
+
+func TestSquareMapImage_ApplyTheme(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(3, 2)
+
+	theme := DarkTheme()
+	theme.CellSize = 20
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	mImg.ApplyTheme(theme)
+
+	if mImg.Width() != 60 || mImg.Height() != 40 {
+		t.Fatalf("size: got %d x %d, want 60 x 40", mImg.Width(), mImg.Height())
+	}
+
+	img, err := mImg.Render()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if actual := img.RGBAAt(10, 10); actual != theme.BackgroundColor {
+		t.Errorf("background: got %v, want %v", actual, theme.BackgroundColor)
+	}
+}
@@ -0,0 +1,83 @@
+package mapgen
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// SetView は描画するマップの範囲をマス単位で設定する。
+//
+// 範囲がマップからはみ出す場合は、マップ内に収まる部分を描画する。
+// マップと重なる部分がない場合はエラーを返す。
+func (i *SquareMapImage) SetView(r image.Rectangle) error {
+	mapRect := image.Rect(0, 0, i.Map.Width(), i.Map.Height())
+	view := r.Canon().Intersect(mapRect)
+	if view.Empty() {
+		return fmt.Errorf("view is out of map: %v", r)
+	}
+
+	i.View = view
+
+	return nil
+}
+
+// FitChits は、描画範囲をチットの集合を囲む範囲に設定する。
+//
+// 範囲はチットの周囲 padding マスだけ広げる。padding が負の場合はエラーを返す。
+func (i *SquareMapImage) FitChits(chits []*rpgmap.Chit, padding int) error {
+	if len(chits) < 1 {
+		return fmt.Errorf("no chits to fit")
+	}
+
+	if padding < 0 {
+		return fmt.Errorf("padding must not be negative: %d", padding)
+	}
+
+	r := image.Rect(chits[0].X, chits[0].Y, chits[0].X+1, chits[0].Y+1)
+	for _, c := range chits[1:] {
+		r = r.Union(image.Rect(c.X, c.Y, c.X+1, c.Y+1))
+	}
+
+	return i.SetView(r.Inset(-padding))
+}
+
+// viewRect は描画範囲のピクセル単位の矩形を返す。
+func (i *SquareMapImage) viewRect() image.Rectangle {
+	if i.View.Empty() {
+		return i.rect
+	}
+
+	return image.Rect(
+		i.View.Min.X*i.GridWidth,
+		i.View.Min.Y*i.GridHeight,
+		i.View.Max.X*i.GridWidth,
+		i.View.Max.Y*i.GridHeight,
+	)
+}
+
+// scale は描画の拡大率を返す。
+func (i *SquareMapImage) scale() float64 {
+	if i.Scale <= 0 {
+		return 1.0
+	}
+
+	return i.Scale
+}
+
+// chitsInView は描画範囲内のチットを返す。
+func (i *SquareMapImage) chitsInView(chits []*rpgmap.Chit) []*rpgmap.Chit {
+	if i.View.Empty() {
+		return chits
+	}
+
+	result := make([]*rpgmap.Chit, 0, len(chits))
+	for _, c := range chits {
+		if image.Pt(c.X, c.Y).In(i.View) {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,120 @@
+package mapgen
+
+import (
+	"image"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestSquareMapImage_Render_View(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 5, Y: 5, Color: colorutil.CSS3NameToRGBA("red")})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 8, Y: 2, Color: colorutil.CSS3NameToRGBA("blue")})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	if err := mImg.SetView(image.Rect(4, 4, 7, 7)); err != nil {
+		t.Fatalf("SetView: %s", err)
+	}
+	mImg.Scale = 2.0
+
+	img, err := mImg.Render()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	// 3マス x 32ピクセル x 2倍、凡例はチットAの1行のみ
+	expectedSize := image.Pt(192, 192+64)
+	if img.Bounds().Size() != expectedSize {
+		t.Fatalf("size: got %v, want %v", img.Bounds().Size(), expectedSize)
+	}
+
+	// チットAの中心は、範囲の左上から1.5マスの位置
+	if actual := img.RGBAAt(96, 96); actual != colorutil.CSS3NameToRGBA("red") {
+		t.Errorf("chit A: got %v, want red", actual)
+	}
+}
+
+func TestSquareMapImage_SetView(t *testing.T) {
+	testcases := []struct {
+		Name     string
+		Input    image.Rectangle
+		Expected image.Rectangle
+		Err      bool
+	}{
+		{Name: "inside", Input: image.Rect(1, 2, 3, 4), Expected: image.Rect(1, 2, 3, 4)},
+		{Name: "clipped", Input: image.Rect(8, 8, 12, 12), Expected: image.Rect(8, 8, 10, 10)},
+		{Name: "reversed", Input: image.Rect(3, 4, 1, 2), Expected: image.Rect(1, 2, 3, 4)},
+		{Name: "outside", Input: image.Rect(10, 10, 12, 12), Err: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := rpgmap.NewSquareMap(10, 10)
+			mImg := NewSquareMapImage(m, newTestFontCache(t))
+
+			err := mImg.SetView(test.Input)
+			if test.Err {
+				if err == nil {
+					t.Fatal("expected err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if mImg.View != test.Expected {
+				t.Errorf("got: %v, want: %v", mImg.View, test.Expected)
+			}
+		})
+	}
+}
+
+func TestSquareMapImage_FitChits(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 5, Y: 5, Color: colorutil.CSS3NameToRGBA("red")})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 8, Y: 2, Color: colorutil.CSS3NameToRGBA("blue")})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	chits := mImg.chits()
+
+	if err := mImg.FitChits(chits, 1); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expected := image.Rect(4, 1, 10, 7)
+	if mImg.View != expected {
+		t.Errorf("got: %v, want: %v", mImg.View, expected)
+	}
+
+	if err := mImg.FitChits(nil, 1); err == nil {
+		t.Error("expected err for no chits")
+	}
+
+	if err := mImg.FitChits(chits, -1); err == nil {
+		t.Error("expected err for negative padding")
+	}
+}
+
+func TestSquareMapImage_RenderSVG_View(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 5, Y: 5, Color: colorutil.CSS3NameToRGBA("red")})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 8, Y: 2, Color: colorutil.CSS3NameToRGBA("blue")})
+
+	mImg := NewSquareMapImage(m, newTestFontCache(t))
+	mImg.SetView(image.Rect(4, 4, 7, 7))
+	mImg.Scale = 0.5
+
+	svg, err := mImg.RenderSVG()
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if svg.Width != "48" || svg.Height != "64" {
+		t.Errorf("size: got %s x %s, want 48 x 64", svg.Width, svg.Height)
+	}
+}
@@ -0,0 +1,107 @@
+package rpgmap
+
+import (
+	"fmt"
+	"image"
+)
+
+// Background はマップの背景画像の構造体。
+type Background struct {
+	// Image は背景画像。
+	Image image.Image
+	// Source は背景画像の取得元（ファイル名やURL）。
+	Source string
+	// Fit は、背景画像をマップ全体に合わせて拡大縮小するかどうか。
+	//
+	// false の場合は、CellSize ピクセルを1マスとして配置する。
+	Fit bool
+	// CellSize は、背景画像上での1マスの大きさ（ピクセル）。
+	//
+	// 0 の場合は、描画時の1マスの大きさと同じとみなす。
+	// Fit が true の場合は使用しない。
+	CellSize int
+	// OffsetX は、背景画像上でのグリッドの左端のx座標（ピクセル）。
+	OffsetX int
+	// OffsetY は、背景画像上でのグリッドの上端のy座標（ピクセル）。
+	OffsetY int
+	// Opacity は背景画像の不透明度（0.0〜1.0）。
+	Opacity float64
+	// ShiftX は、マップの移動に合わせて背景画像を右に移動したマス数。
+	//
+	// Fit が true の場合は使用しない。
+	ShiftX int
+	// ShiftY は、マップの移動に合わせて背景画像を下に移動したマス数。
+	//
+	// Fit が true の場合は使用しない。
+	ShiftY int
+}
+
+// NewBackground は、画像を原寸で不透明に配置する新しい背景を返す。
+func NewBackground(img image.Image, source string) *Background {
+	return &Background{
+		Image:   img,
+		Source:  source,
+		Fit:     false,
+		Opacity: 1.0,
+	}
+}
+
+// Validate は背景の設定が正しいかを確かめる。
+func (b *Background) Validate() error {
+	if b.Image == nil {
+		return fmt.Errorf("background image is not set")
+	}
+
+	if b.CellSize < 0 {
+		return fmt.Errorf("CellSize must be greater than or equal to 0 (%d)", b.CellSize)
+	}
+
+	if b.Opacity < 0.0 || b.Opacity > 1.0 {
+		return fmt.Errorf("Opacity must be between 0.0 and 1.0 (%g)", b.Opacity)
+	}
+
+	return nil
+}
+
+// Background はマップの背景の複製を返す。
+//
+// 背景が設定されていない場合は false を返す。
+func (m *SquareMap) Background() (*Background, bool) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	if m.background == nil {
+		return nil, false
+	}
+
+	copied := *m.background
+	return &copied, true
+}
+
+// SetBackground はマップの背景を設定する。
+func (m *SquareMap) SetBackground(b *Background) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	copied := *b
+	m.background = &copied
+
+	return nil
+}
+
+// ClearBackground はマップの背景を削除する。
+//
+// 背景が設定されていた場合は true を返す。
+func (m *SquareMap) ClearBackground() bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	found := m.background != nil
+	m.background = nil
+
+	return found
+}
@@ -0,0 +1,95 @@
+package rpgmap
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSquareMap_SetBackground(t *testing.T) {
+	testcases := []struct {
+		Name       string
+		Background Background
+		Err        bool
+	}{
+		{
+			Name:       "default",
+			Background: *NewBackground(image.NewRGBA(image.Rect(0, 0, 10, 10)), "a.png"),
+			Err:        false,
+		},
+		{
+			Name:       "no image",
+			Background: Background{Opacity: 1.0},
+			Err:        true,
+		},
+		{
+			Name: "negative CellSize",
+			Background: Background{
+				Image:    image.NewRGBA(image.Rect(0, 0, 10, 10)),
+				CellSize: -1,
+				Opacity:  1.0,
+			},
+			Err: true,
+		},
+		{
+			Name: "Opacity out of range",
+			Background: Background{
+				Image:   image.NewRGBA(image.Rect(0, 0, 10, 10)),
+				Opacity: 1.5,
+			},
+			Err: true,
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			m, _ := NewSquareMap(10, 10)
+
+			err := m.SetBackground(&test.Background)
+			if err != nil {
+				if test.Err {
+					return
+				}
+
+				t.Fatalf("got err: %s", err)
+			}
+
+			if test.Err {
+				t.Fatal("expected err")
+			}
+
+			if _, found := m.Background(); !found {
+				t.Fatal("background is not set")
+			}
+		})
+	}
+}
+
+func TestSquareMap_Background_ReturnsCopy(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.SetBackground(NewBackground(image.NewRGBA(image.Rect(0, 0, 10, 10)), "a.png"))
+
+	b, _ := m.Background()
+	b.Opacity = 0.5
+
+	actual, _ := m.Background()
+	if actual.Opacity != 1.0 {
+		t.Fatalf("Opacity: got %g, want %g", actual.Opacity, 1.0)
+	}
+}
+
+func TestSquareMap_ClearBackground(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.SetBackground(NewBackground(image.NewRGBA(image.Rect(0, 0, 10, 10)), "a.png"))
+
+	if !m.ClearBackground() {
+		t.Fatal("ClearBackground returned false")
+	}
+
+	if _, found := m.Background(); found {
+		t.Fatal("background is not cleared")
+	}
+
+	if m.ClearBackground() {
+		t.Fatal("ClearBackground returned true without background")
+	}
+}
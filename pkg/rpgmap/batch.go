@@ -0,0 +1,161 @@
+package rpgmap
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// AddChits はチットをまとめて追加する。
+//
+// いずれかのチットを追加できない場合は、どのチットも追加せずにエラーを返す。
+// 色が設定されていないチットには、AddChit と同様に色を割り当てる。
+func (m *SquareMap) AddChits(chits []*Chit) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.addChits(chits)
+}
+
+// addChits はチットをまとめて追加する。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) addChits(chits []*Chit) error {
+	names := map[string]bool{}
+	for _, c := range chits {
+		normalized := NormalizeChitName(c.Name)
+		if names[normalized] {
+			return fmt.Errorf(`chit "%s" is duplicated`, c.Name)
+		}
+
+		if err := m.validateNewChit(c); err != nil {
+			return err
+		}
+
+		names[normalized] = true
+	}
+
+	for _, c := range chits {
+		m.pushChit(c)
+	}
+
+	return nil
+}
+
+// PlaceChits は、チットを area 内の空いているマスに置いてまとめて追加する。
+//
+// area はマス単位の矩形（Max は含まない）。
+// area が1マスの場合は、そのマスから近い順に、マップ全体の空いているマスに置く。
+// それ以外の場合は、area 内の空いているマスに左上から行ごとに置く。
+// チットの座標は書き換えられる。
+// 空いているマスが足りない場合は、どのチットも追加せずにエラーを返す。
+func (m *SquareMap) PlaceChits(chits []*Chit, area image.Rectangle) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	mapRect := image.Rect(0, 0, m.width, m.height)
+	area = area.Canon()
+	if !area.In(mapRect) {
+		return fmt.Errorf("area is out of range: (%d, %d)-(%d, %d)",
+			area.Min.X+1, area.Min.Y+1, area.Max.X, area.Max.Y)
+	}
+
+	var cells []image.Point
+	if area.Dx() == 1 && area.Dy() == 1 {
+		cells = cellsNear(area.Min, mapRect)
+	} else {
+		cells = cellsIn(area)
+	}
+
+	free := m.freeCells(cells, len(chits))
+	if len(free) < len(chits) {
+		return fmt.Errorf("not enough free cells: %d (%d required)", len(free), len(chits))
+	}
+
+	// 追加に失敗した場合に座標を戻せるように、複製に座標を設定する
+	placed := make([]*Chit, 0, len(chits))
+	for k, c := range chits {
+		copied := *c
+		copied.X = free[k].X
+		copied.Y = free[k].Y
+		placed = append(placed, &copied)
+	}
+
+	if err := m.addChits(placed); err != nil {
+		return err
+	}
+
+	for k, c := range chits {
+		*c = *placed[k]
+	}
+
+	return nil
+}
+
+// freeCells は、cells のうちチットが置かれていないマスを先頭から最大 n 個返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) freeCells(cells []image.Point, n int) []image.Point {
+	occupied := map[image.Point]bool{}
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		c := e.Value.(*Chit)
+		occupied[image.Pt(c.X, c.Y)] = true
+	}
+
+	free := make([]image.Point, 0, n)
+	for _, p := range cells {
+		if len(free) >= n {
+			break
+		}
+
+		if !occupied[p] {
+			free = append(free, p)
+		}
+	}
+
+	return free
+}
+
+// cellsIn は、矩形内のマスを左上から行ごとに並べた配列を返す。
+func cellsIn(r image.Rectangle) []image.Point {
+	cells := make([]image.Point, 0, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cells = append(cells, image.Pt(x, y))
+		}
+	}
+
+	return cells
+}
+
+// cellsNear は、矩形 bounds 内のマスを p から近い順に並べた配列を返す。
+//
+// 距離は縦横斜めの移動の回数で測り、同じ距離のマスは左上から行ごとに並べる。
+func cellsNear(p image.Point, bounds image.Rectangle) []image.Point {
+	cells := cellsIn(bounds)
+
+	distance := func(q image.Point) int {
+		dx := abs(q.X - p.X)
+		dy := abs(q.Y - p.Y)
+		if dx > dy {
+			return dx
+		}
+
+		return dy
+	}
+
+	sort.SliceStable(cells, func(i, j int) bool {
+		return distance(cells[i]) < distance(cells[j])
+	})
+
+	return cells
+}
+
+// abs は整数の絶対値を返す。
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -0,0 +1,165 @@
+package rpgmap
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+// chitPoints はチットの座標の配列を返す。
+func chitPoints(chits []*Chit) []image.Point {
+	points := []image.Point{}
+	for _, c := range chits {
+		points = append(points, image.Pt(c.X, c.Y))
+	}
+
+	return points
+}
+
+func TestSquareMap_AddChits(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0})
+
+	err := m.AddChits([]*Chit{
+		{Name: "B", X: 1, Y: 0},
+		{Name: "C", X: 2, Y: 0},
+	})
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if n := m.NumOfChits(); n != 3 {
+		t.Errorf("NumOfChits: got: %d, want: %d", n, 3)
+	}
+
+	testcases := []struct {
+		title string
+		chits []*Chit
+	}{
+		{
+			title: "existing name",
+			chits: []*Chit{{Name: "D", X: 3, Y: 0}, {Name: "A", X: 4, Y: 0}},
+		},
+		{
+			title: "duplicated name",
+			chits: []*Chit{{Name: "D", X: 3, Y: 0}, {Name: "D", X: 4, Y: 0}},
+		},
+		{
+			title: "out of range",
+			chits: []*Chit{{Name: "D", X: 3, Y: 0}, {Name: "E", X: 10, Y: 0}},
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.title, func(t *testing.T) {
+			if err := m.AddChits(test.chits); err == nil {
+				t.Fatal("expected err")
+			}
+
+			// どのチットも追加されない
+			if _, found := m.FindChit("D"); found {
+				t.Error("chit D was added")
+			}
+
+			if n := m.NumOfChits(); n != 3 {
+				t.Errorf("NumOfChits: got: %d, want: %d", n, 3)
+			}
+		})
+	}
+}
+
+func TestSquareMap_PlaceChits(t *testing.T) {
+	testcases := []struct {
+		title    string
+		n        int
+		area     image.Rectangle
+		expected []image.Point
+	}{
+		{
+			title:    "in area",
+			n:        4,
+			area:     image.Rect(2, 3, 5, 5),
+			expected: []image.Point{{2, 3}, {4, 3}, {2, 4}, {3, 4}},
+		},
+		{
+			title:    "near point",
+			n:        4,
+			area:     image.Rect(3, 3, 4, 4),
+			expected: []image.Point{{2, 2}, {3, 2}, {4, 2}, {2, 3}},
+		},
+		{
+			title:    "near corner",
+			n:        3,
+			area:     image.Rect(9, 9, 10, 10),
+			expected: []image.Point{{9, 9}, {8, 8}, {9, 8}},
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.title, func(t *testing.T) {
+			m, _ := NewSquareMap(10, 10)
+			m.AddChit(&Chit{Name: "A", X: 3, Y: 3})
+
+			chits := []*Chit{}
+			for i := 0; i < test.n; i++ {
+				chits = append(chits, &Chit{Name: string(rune('B' + i))})
+			}
+
+			if err := m.PlaceChits(chits, test.area); err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if actual := chitPoints(chits); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("got: %v, want: %v", actual, test.expected)
+			}
+
+			if n := m.NumOfChits(); n != test.n+1 {
+				t.Errorf("NumOfChits: got: %d, want: %d", n, test.n+1)
+			}
+		})
+	}
+}
+
+func TestSquareMap_PlaceChits_Error(t *testing.T) {
+	testcases := []struct {
+		title string
+		n     int
+		area  image.Rectangle
+	}{
+		{
+			title: "not enough cells",
+			n:     4,
+			area:  image.Rect(2, 2, 4, 4),
+		},
+		{
+			title: "out of range",
+			n:     1,
+			area:  image.Rect(8, 8, 11, 9),
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.title, func(t *testing.T) {
+			m, _ := NewSquareMap(10, 10)
+			m.AddChit(&Chit{Name: "A", X: 3, Y: 3})
+
+			chits := []*Chit{}
+			for i := 0; i < test.n; i++ {
+				chits = append(chits, &Chit{Name: string(rune('B' + i)), X: -1, Y: -1})
+			}
+
+			if err := m.PlaceChits(chits, test.area); err == nil {
+				t.Fatal("expected err")
+			}
+
+			if n := m.NumOfChits(); n != 1 {
+				t.Errorf("NumOfChits: got: %d, want: %d", n, 1)
+			}
+
+			// 座標は書き換えられない
+			if chits[0].X != -1 || chits[0].Y != -1 {
+				t.Errorf("coordinates were changed: (%d, %d)", chits[0].X, chits[0].Y)
+			}
+		})
+	}
+}
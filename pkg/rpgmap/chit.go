@@ -2,6 +2,7 @@ package rpgmap
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 )
 
@@ -15,6 +16,26 @@ type Chit struct {
 	Y int
 	// Color は駒の色。
 	Color color.RGBA
+	// AutoColor は、駒の色がパレットから自動で割り当てられたものかどうか。
+	//
+	// パレットを変更すると、自動で割り当てられた色は割り当て直される。
+	AutoColor bool
+	// Group は駒のグループ（陣営）の名前。空の場合はどのグループにも属さない。
+	Group string
+	// Shape は駒の形（SHAPE_CIRCLE など）。空の場合は円とする。
+	Shape string
+	// Image は駒の画像。nil の場合は色付きの図形で表す。
+	Image image.Image
+	// ImageSource は駒の画像の取得元（ファイル名やURL）。
+	ImageSource string
+	// Initiative は駒のイニシアチブ（行動順を決める値）。
+	Initiative int
+	// Moved は駒が移動したことがあるかどうか。
+	Moved bool
+	// PrevX は直前の移動の前の駒のx座標。Moved が false の場合は使わない。
+	PrevX int
+	// PrevY は直前の移動の前の駒のy座標。Moved が false の場合は使わない。
+	PrevY int
 }
 
 // String は駒を表す文字列を返す。
@@ -0,0 +1,234 @@
+package rpgmap
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+)
+
+// groupColorPalette はグループの色に使うパレット。
+var groupColorPalette, _ = colorutil.Palette(colorutil.PALETTE_TOL)
+
+// Groups はチットのグループの名前の配列を返す。
+//
+// グループは、そのグループのチットが最初に現れる順に並べる。
+func (m *SquareMap) Groups() []string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.groups()
+}
+
+// groups はチットのグループの名前の配列を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) groups() []string {
+	groups := []string{}
+	seen := map[string]bool{}
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		g := e.Value.(*Chit).Group
+		if g == "" || seen[g] {
+			continue
+		}
+
+		groups = append(groups, g)
+		seen[g] = true
+	}
+
+	return groups
+}
+
+// GroupColor はグループの色を返す。
+func (m *SquareMap) GroupColor(group string) (color.RGBA, bool) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	c, found := m.groupColors[group]
+	return c, found
+}
+
+// SetGroupColor はグループの色を設定する。
+//
+// グループにチットがいない場合はエラーを返す。
+func (m *SquareMap) SetGroupColor(group string, c color.RGBA) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if len(m.chitsInGroup(group)) < 1 || group == "" {
+		return fmt.Errorf("group not found: %s", group)
+	}
+
+	m.groupColors[group] = c
+
+	return nil
+}
+
+// ensureGroupColor は、グループに色が割り当てられていなければ割り当てる。
+//
+// 他のグループで使われていない色を、パレットの先頭から選ぶ。
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) ensureGroupColor(group string) {
+	if group == "" {
+		return
+	}
+
+	if _, found := m.groupColors[group]; found {
+		return
+	}
+
+	used := make([]color.RGBA, 0, len(m.groupColors))
+	for _, c := range m.groupColors {
+		used = append(used, c)
+	}
+
+	m.groupColors[group] = leastUsedColor(groupColorPalette, used)
+}
+
+// pruneGroupColors は、チットがいなくなったグループの色の割り当てを解除する。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) pruneGroupColors() {
+	alive := map[string]bool{}
+	for _, g := range m.groups() {
+		alive[g] = true
+	}
+
+	for g := range m.groupColors {
+		if !alive[g] {
+			delete(m.groupColors, g)
+		}
+	}
+}
+
+// leastUsedColor は、palette のうち used に含まれる数が最も少ない最初の色を返す。
+func leastUsedColor(palette []color.RGBA, used []color.RGBA) color.RGBA {
+	counts := map[color.RGBA]int{}
+	for _, c := range used {
+		counts[c]++
+	}
+
+	best := palette[0]
+	for _, c := range palette[1:] {
+		if counts[c] < counts[best] {
+			best = c
+		}
+	}
+
+	return best
+}
+
+// SetChitGroup はチットのグループを設定する。
+//
+// group が空の場合は、チットをグループから外す。
+// 設定後のチットの複製を返す。
+func (m *SquareMap) SetChitGroup(name string, group string) (*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	c, err := m.findChit(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Group = group
+	m.ensureGroupColor(group)
+	m.pruneGroupColors()
+
+	copied := *c
+	return &copied, nil
+}
+
+// ChitsInGroup は、グループのチットの複製の配列を追加した順に返す。
+func (m *SquareMap) ChitsInGroup(group string) []*Chit {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	chits := []*Chit{}
+	for _, c := range m.chitsInGroup(group) {
+		copied := *c
+		chits = append(chits, &copied)
+	}
+
+	return chits
+}
+
+// chitsInGroup はグループのチットの配列を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) chitsInGroup(group string) []*Chit {
+	chits := []*Chit{}
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		c := e.Value.(*Chit)
+		if c.Group == group {
+			chits = append(chits, c)
+		}
+	}
+
+	return chits
+}
+
+// MoveGroup は、グループのチットをすべて (dx, dy) だけ移動する。
+//
+// いずれかのチットがマップの範囲外に出る場合は、どのチットも移動しない。
+// 移動後のチットの複製の配列を返す。
+func (m *SquareMap) MoveGroup(group string, dx int, dy int) ([]*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	chits := m.chitsInGroup(group)
+	if len(chits) < 1 {
+		return nil, fmt.Errorf("group not found: %s", group)
+	}
+
+	for _, c := range chits {
+		if !m.xIsInRange(c.X+dx) || !m.yIsInRange(c.Y+dy) {
+			return nil, fmt.Errorf("chit %q would be out of range: (%d, %d)", c.Name, c.X+dx+1, c.Y+dy+1)
+		}
+	}
+
+	moved := make([]*Chit, 0, len(chits))
+	for _, c := range chits {
+		m.recordMove(Move{
+			Name:  c.Name,
+			FromX: c.X,
+			FromY: c.Y,
+			ToX:   c.X + dx,
+			ToY:   c.Y + dy,
+		})
+
+		c.Moved = true
+		c.PrevX = c.X
+		c.PrevY = c.Y
+		c.X += dx
+		c.Y += dy
+
+		copied := *c
+		moved = append(moved, &copied)
+	}
+
+	return moved, nil
+}
+
+// DeleteGroup はグループのチットをすべて削除する。
+//
+// 削除したチットの名前の配列を返す。
+func (m *SquareMap) DeleteGroup(group string) ([]string, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	chits := m.chitsInGroup(group)
+	if len(chits) < 1 {
+		return nil, fmt.Errorf("group not found: %s", group)
+	}
+
+	names := make([]string, 0, len(chits))
+	for _, c := range chits {
+		m.removeChitElement(m.nameToChitListElement[c.Name])
+		names = append(names, c.Name)
+	}
+
+	m.pruneGroupColors()
+
+	return names, nil
+}
@@ -0,0 +1,158 @@
+package rpgmap
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// chitNames はチットの名前の配列を返す。
+func chitNames(chits []*Chit) []string {
+	names := []string{}
+	for _, c := range chits {
+		names = append(names, c.Name)
+	}
+
+	return names
+}
+
+func TestSquareMap_Groups(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Fighter", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "Goblin 1", X: 5, Y: 5, Group: "Goblins"})
+	m.AddChit(&Chit{Name: "Merchant", X: 3, Y: 3})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 6, Y: 5, Group: "Goblins"})
+
+	expected := []string{"PC", "Goblins"}
+	if actual := m.Groups(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got: %v, want: %v", actual, expected)
+	}
+
+	expectedNames := []string{"Goblin 1", "Goblin 2"}
+	if actual := chitNames(m.ChitsInGroup("Goblins")); !reflect.DeepEqual(actual, expectedNames) {
+		t.Errorf("ChitsInGroup: got: %v, want: %v", actual, expectedNames)
+	}
+
+	pc, _ := m.GroupColor("PC")
+	goblins, _ := m.GroupColor("Goblins")
+	if pc == goblins {
+		t.Errorf("groups have the same color: %v", pc)
+	}
+}
+
+func TestSquareMap_SetChitGroup(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Fighter", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "Goblin 1", X: 5, Y: 5, Group: "Goblins"})
+	m.AddChit(&Chit{Name: "Wizard", X: 1, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 6, Y: 5, Group: "Goblins"})
+	m.AddChit(&Chit{Name: "Merchant", X: 3, Y: 3})
+
+	if _, err := m.SetChitGroup("Merchant", "PC"); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expectedNames := []string{"Fighter", "Wizard", "Merchant"}
+	if actual := chitNames(m.ChitsInGroup("PC")); !reflect.DeepEqual(actual, expectedNames) {
+		t.Errorf("got: %v, want: %v", actual, expectedNames)
+	}
+
+	// 最後のチットが外れたグループの色は解除される
+	m.SetChitGroup("Goblin 1", "")
+	m.SetChitGroup("Goblin 2", "")
+	if _, found := m.GroupColor("Goblins"); found {
+		t.Error("color of empty group is not released")
+	}
+
+	if _, err := m.SetChitGroup("Dragon", "PC"); err == nil {
+		t.Error("expected err")
+	}
+}
+
+func TestSquareMap_SetGroupColor(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Fighter", X: 0, Y: 0, Group: "PC"})
+
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	if err := m.SetGroupColor("PC", red); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if c, _ := m.GroupColor("PC"); c != red {
+		t.Errorf("got: %v, want: %v", c, red)
+	}
+
+	if err := m.SetGroupColor("Dragons", red); err == nil {
+		t.Error("expected err")
+	}
+}
+
+func TestSquareMap_MoveGroup(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Fighter", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "Goblin 1", X: 5, Y: 5, Group: "Goblins"})
+	m.AddChit(&Chit{Name: "Wizard", X: 1, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 6, Y: 5, Group: "Goblins"})
+
+	moved, err := m.MoveGroup("Goblins", -2, 1)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if len(moved) != 2 {
+		t.Fatalf("moved: got %d chits, want 2", len(moved))
+	}
+
+	g1, _ := m.FindChit("Goblin 1")
+	g2, _ := m.FindChit("Goblin 2")
+	if g1.X != 3 || g1.Y != 6 || g2.X != 4 || g2.Y != 6 {
+		t.Errorf("got: %s, %s", g1, g2)
+	}
+
+	if n := len(m.LastMoves(10)); n != 2 {
+		t.Errorf("moves: got %d, want 2", n)
+	}
+
+	// 範囲外に出るチットがある場合は、どのチットも移動しない
+	if _, err := m.MoveGroup("PC", 9, 0); err == nil {
+		t.Fatal("expected err")
+	}
+
+	fighter, _ := m.FindChit("Fighter")
+	if fighter.X != 0 {
+		t.Errorf("Fighter is moved: %s", fighter)
+	}
+
+	if _, err := m.MoveGroup("Dragons", 1, 1); err == nil {
+		t.Error("expected err for unknown group")
+	}
+}
+
+func TestSquareMap_DeleteGroup(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Fighter", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "Goblin 1", X: 5, Y: 5, Group: "Goblins"})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 6, Y: 5, Group: "Goblins"})
+
+	names, err := m.DeleteGroup("Goblins")
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expectedNames := []string{"Goblin 1", "Goblin 2"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("got: %v, want: %v", names, expectedNames)
+	}
+
+	if m.NumOfChits() != 1 {
+		t.Errorf("NumOfChits: got %d, want 1", m.NumOfChits())
+	}
+
+	if _, found := m.GroupColor("Goblins"); found {
+		t.Error("color of deleted group is not released")
+	}
+
+	if _, err := m.DeleteGroup("Goblins"); err == nil {
+		t.Error("expected err")
+	}
+}
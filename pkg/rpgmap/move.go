@@ -0,0 +1,117 @@
+package rpgmap
+
+import "fmt"
+
+const (
+	// MAX_MOVE_HISTORY は記録するチットの移動の最大数。
+	MAX_MOVE_HISTORY = 100
+)
+
+// Move はチットの移動の記録を表す構造体。
+type Move struct {
+	// Name は移動したチットの名前。
+	Name string
+	// FromX は移動前のx座標。
+	FromX int
+	// FromY は移動前のy座標。
+	FromY int
+	// ToX は移動後のx座標。
+	ToX int
+	// ToY は移動後のy座標。
+	ToY int
+	// Round は移動したラウンド。
+	Round int
+}
+
+// recordMove はチットの移動を記録する。
+//
+// 記録が最大数を超えた場合は、古いものから削除する。
+// ロックを取得した状態で呼び出すこと。
+func (m *SquareMap) recordMove(mv Move) {
+	mv.Round = m.round
+	m.moves = append(m.moves, mv)
+
+	if len(m.moves) > MAX_MOVE_HISTORY {
+		m.moves = append([]Move{}, m.moves[len(m.moves)-MAX_MOVE_HISTORY:]...)
+	}
+}
+
+// Round は現在のラウンドを返す。
+func (m *SquareMap) Round() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.round
+}
+
+// NextRound は次のラウンドに進め、新しいラウンドを返す。
+func (m *SquareMap) NextRound() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.round++
+
+	return m.round
+}
+
+// SetMoveHistory は、現在のラウンドと移動の記録を設定する。
+//
+// 保存した状態を読み込む場合に使う。移動の記録は古い順に並べること。
+// 最大数を超えた場合は、古いものを捨てる。
+// ラウンドや座標が無効な場合はマップを変更せずにエラーを返す。
+func (m *SquareMap) SetMoveHistory(round int, moves []Move) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if round < 1 {
+		return fmt.Errorf("round must be greater than or equal to 1 (%d)", round)
+	}
+
+	for _, mv := range moves {
+		if mv.Round < 1 || mv.Round > round {
+			return fmt.Errorf("move of %q: round out of range (1-%d): %d", mv.Name, round, mv.Round)
+		}
+
+		if !m.xIsInRange(mv.FromX) || !m.yIsInRange(mv.FromY) ||
+			!m.xIsInRange(mv.ToX) || !m.yIsInRange(mv.ToY) {
+			return fmt.Errorf("move of %q is out of range", mv.Name)
+		}
+	}
+
+	if len(moves) > MAX_MOVE_HISTORY {
+		moves = moves[len(moves)-MAX_MOVE_HISTORY:]
+	}
+
+	m.round = round
+	m.moves = append([]Move{}, moves...)
+
+	return nil
+}
+
+// LastMoves は、最近のチットの移動を最大n件、古い順に返す。
+func (m *SquareMap) LastMoves(n int) []Move {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	start := len(m.moves) - n
+	if start < 0 {
+		start = 0
+	}
+
+	return append([]Move{}, m.moves[start:]...)
+}
+
+// RoundMoves は、指定したラウンドのチットの移動を古い順に返す。
+func (m *SquareMap) RoundMoves(round int) []Move {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	moves := []Move{}
+	for _, mv := range m.moves {
+		if mv.Round == round {
+			moves = append(moves, mv)
+		}
+	}
+
+	return moves
+}
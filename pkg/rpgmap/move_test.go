@@ -0,0 +1,126 @@
+package rpgmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSquareMap_MoveChit_RecordsMoves(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "B", X: 5, Y: 5})
+
+	m.MoveChit("A", 1, 2)
+	m.NextRound()
+	m.MoveChit("B", 6, 5)
+	m.MoveChit("A", 3, 3)
+
+	// 失敗した移動は記録されない
+	m.MoveChit("A", 10, 10)
+	m.MoveChit("C", 1, 1)
+
+	expectedRound2 := []Move{
+		{Name: "B", FromX: 5, FromY: 5, ToX: 6, ToY: 5, Round: 2},
+		{Name: "A", FromX: 1, FromY: 2, ToX: 3, ToY: 3, Round: 2},
+	}
+
+	if actual := m.RoundMoves(2); !reflect.DeepEqual(actual, expectedRound2) {
+		t.Errorf("RoundMoves(2): got %+v, want %+v", actual, expectedRound2)
+	}
+
+	expectedLast := []Move{
+		{Name: "A", FromX: 1, FromY: 2, ToX: 3, ToY: 3, Round: 2},
+	}
+
+	if actual := m.LastMoves(1); !reflect.DeepEqual(actual, expectedLast) {
+		t.Errorf("LastMoves(1): got %+v, want %+v", actual, expectedLast)
+	}
+
+	if actual := m.LastMoves(10); len(actual) != 3 {
+		t.Errorf("len(LastMoves(10)): got %d, want %d", len(actual), 3)
+	}
+
+	if actual := m.RoundMoves(3); len(actual) != 0 {
+		t.Errorf("RoundMoves(3): got %+v", actual)
+	}
+}
+
+func TestSquareMap_MoveChit_LimitsHistory(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0})
+
+	for i := 0; i < MAX_MOVE_HISTORY+5; i++ {
+		m.MoveChit("A", i%10, 0)
+	}
+
+	moves := m.LastMoves(MAX_MOVE_HISTORY * 2)
+	if len(moves) != MAX_MOVE_HISTORY {
+		t.Fatalf("len: got %d, want %d", len(moves), MAX_MOVE_HISTORY)
+	}
+
+	if moves[len(moves)-1].ToX != (MAX_MOVE_HISTORY+4)%10 {
+		t.Errorf("last move: got %+v", moves[len(moves)-1])
+	}
+}
+
+func TestSquareMap_MoveChit_TracksPreviousPosition(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 1})
+
+	c, _ := m.FindChit("A")
+	if c.Moved {
+		t.Fatal("Moved is true before moving")
+	}
+
+	m.MoveChit("A", 2, 3)
+	m.MoveChit("A", 4, 5)
+
+	c, _ = m.FindChit("A")
+	if !c.Moved || c.PrevX != 2 || c.PrevY != 3 {
+		t.Errorf("got: Moved=%t, Prev=(%d, %d), want: Moved=true, Prev=(2, 3)", c.Moved, c.PrevX, c.PrevY)
+	}
+}
+
+func TestSquareMap_SetMoveHistory(t *testing.T) {
+	moves := []Move{
+		{Name: "A", FromX: 0, FromY: 0, ToX: 1, ToY: 2, Round: 1},
+		{Name: "B", FromX: 5, FromY: 5, ToX: 6, ToY: 5, Round: 3},
+	}
+
+	m, _ := NewSquareMap(10, 10)
+	if err := m.SetMoveHistory(3, moves); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if r := m.Round(); r != 3 {
+		t.Errorf("Round: got %d, want %d", r, 3)
+	}
+
+	if actual := m.LastMoves(MAX_MOVE_HISTORY); !reflect.DeepEqual(actual, moves) {
+		t.Errorf("LastMoves: got %+v, want %+v", actual, moves)
+	}
+
+	testcases := []struct {
+		Name  string
+		Round int
+		Move  Move
+	}{
+		{Name: "invalid round", Round: 0},
+		{Name: "move in later round", Round: 1, Move: Move{Name: "A", Round: 2}},
+		{Name: "move without round", Round: 1, Move: Move{Name: "A"}},
+		{Name: "move out of range", Round: 1, Move: Move{Name: "A", ToX: 10, Round: 1}},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			if err := m.SetMoveHistory(test.Round, []Move{test.Move}); err == nil {
+				t.Fatal("expected err")
+			}
+
+			// 失敗した場合は変更しない
+			if r := m.Round(); r != 3 {
+				t.Errorf("Round: got %d, want %d", r, 3)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package rpgmap
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MAX_CHIT_NAME_SUGGESTIONS は、チットが見つからなかった場合に示す候補の最大数。
+const MAX_CHIT_NAME_SUGGESTIONS = 3
+
+// ChitNotFoundError は、名前に対応するチットが見つからないことを表すエラー。
+type ChitNotFoundError struct {
+	// Name は検索したチットの名前。
+	Name string
+	// Ambiguous は、名前の先頭が一致するチットが複数あったかどうか。
+	Ambiguous bool
+	// Suggestions は候補のチットの名前。
+	Suggestions []string
+}
+
+// Error はエラーメッセージを返す。
+func (e *ChitNotFoundError) Error() string {
+	msg := fmt.Sprintf("chit not found: %s", e.Name)
+	if e.Ambiguous {
+		msg = fmt.Sprintf("chit name is ambiguous: %s", e.Name)
+	}
+
+	if len(e.Suggestions) < 1 {
+		return msg
+	}
+
+	quoted := make([]string, 0, len(e.Suggestions))
+	for _, s := range e.Suggestions {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+
+	return fmt.Sprintf("%s (did you mean %s?)", msg, strings.Join(quoted, ", "))
+}
+
+// NormalizeChitName は、チットの名前を比較用に正規化する。
+//
+// Unicode正規化（NFKC）で全角・半角の違いをなくし、大文字と小文字を区別しないように
+// ケースフォールディングを行い、前後の空白を除いて連続する空白を1つにまとめる。
+func NormalizeChitName(name string) string {
+	s := norm.NFKC.String(name)
+	s = cases.Fold().String(s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// LookupChit は、利用者が入力した名前からチットを検索する。
+//
+// 名前は、完全一致、正規化した名前の一致、正規化した名前の先頭の一致の順に探す。
+// 先頭が一致するチットが複数ある場合や見つからない場合は、
+// 最大 MAX_CHIT_NAME_SUGGESTIONS 個の候補を含む *ChitNotFoundError を返す。
+// チットを操作するメソッドは先頭の一致では検索しないため、入力を補完する場合は
+// このメソッドで得たチットの名前を渡すこと。
+// 返り値はチットの複製であり、変更してもマップには反映されない。
+func (m *SquareMap) LookupChit(name string) (*Chit, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	if e, found := m.sameChitElement(name); found {
+		copied := *e.Value.(*Chit)
+		return &copied, nil
+	}
+
+	normalized := NormalizeChitName(name)
+	prefixed := m.prefixedChitNames(normalized)
+	if len(prefixed) == 1 {
+		copied := *m.nameToChitListElement[prefixed[0]].Value.(*Chit)
+		return &copied, nil
+	}
+
+	if len(prefixed) > 1 {
+		if len(prefixed) > MAX_CHIT_NAME_SUGGESTIONS {
+			prefixed = prefixed[:MAX_CHIT_NAME_SUGGESTIONS]
+		}
+
+		return nil, &ChitNotFoundError{
+			Name:        name,
+			Ambiguous:   true,
+			Suggestions: prefixed,
+		}
+	}
+
+	return nil, &ChitNotFoundError{
+		Name:        name,
+		Suggestions: m.similarChitNames(normalized),
+	}
+}
+
+// findChit は名前からチットを検索する。
+//
+// 名前は、完全一致、正規化した名前の一致の順に探す。
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) findChit(name string) (*Chit, error) {
+	e, err := m.findChitElement(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Value.(*Chit), nil
+}
+
+// findChitElement は名前からチットの連結リストの要素を検索する。
+//
+// 名前は、完全一致、正規化した名前の一致の順に探す。
+// 名前の先頭だけが一致するチットは返さず、候補として *ChitNotFoundError に含める。
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) findChitElement(name string) (*list.Element, error) {
+	if e, found := m.sameChitElement(name); found {
+		return e, nil
+	}
+
+	normalized := NormalizeChitName(name)
+	suggestions := m.prefixedChitNames(normalized)
+	if len(suggestions) > MAX_CHIT_NAME_SUGGESTIONS {
+		suggestions = suggestions[:MAX_CHIT_NAME_SUGGESTIONS]
+	}
+
+	for _, s := range m.similarChitNames(normalized) {
+		if len(suggestions) >= MAX_CHIT_NAME_SUGGESTIONS {
+			break
+		}
+
+		if !containsString(suggestions, s) {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	return nil, &ChitNotFoundError{
+		Name:        name,
+		Suggestions: suggestions,
+	}
+}
+
+// sameChitElement は、名前が完全に一致するか、正規化した名前が一致するチットの要素を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) sameChitElement(name string) (*list.Element, bool) {
+	if e, found := m.nameToChitListElement[name]; found {
+		return e, true
+	}
+
+	return m.sameNameChitElement(NormalizeChitName(name))
+}
+
+// prefixedChitNames は、正規化した名前の先頭が normalized と一致するチットの名前を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) prefixedChitNames(normalized string) []string {
+	names := []string{}
+	if normalized == "" {
+		return names
+	}
+
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		name := e.Value.(*Chit).Name
+		if strings.HasPrefix(NormalizeChitName(name), normalized) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// containsString は、文字列の配列に s が含まれているかを返す。
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateNewChitName は、チットの名前として name を使えるかを確認する。
+//
+// 正規化した名前が同じチットがあれば、そのチットを表す要素が self の場合を除いてエラーを返す。
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) validateNewChitName(name string, self *list.Element) error {
+	if name == "" {
+		return fmt.Errorf("chit name is empty")
+	}
+
+	if e, found := m.sameNameChitElement(NormalizeChitName(name)); found && e != self {
+		return fmt.Errorf(`chit "%s" already exists`, e.Value.(*Chit).Name)
+	}
+
+	return nil
+}
+
+// sameNameChitElement は、正規化した名前が normalized と一致するチットの要素を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) sameNameChitElement(normalized string) (*list.Element, bool) {
+	e, found := m.normalizedNameToChitListElement[normalized]
+	return e, found
+}
+
+// indexChitElement は、チットの要素を名前と正規化した名前の対応に登録する。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) indexChitElement(e *list.Element) {
+	name := e.Value.(*Chit).Name
+	m.nameToChitListElement[name] = e
+	m.normalizedNameToChitListElement[NormalizeChitName(name)] = e
+}
+
+// unindexChitElement は、チットの要素を名前と正規化した名前の対応から削除する。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) unindexChitElement(e *list.Element) {
+	name := e.Value.(*Chit).Name
+	delete(m.nameToChitListElement, name)
+	delete(m.normalizedNameToChitListElement, NormalizeChitName(name))
+}
+
+// removeChitElement は、チットの要素を連結リストと名前の対応から削除する。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) removeChitElement(e *list.Element) {
+	m.chitList.Remove(e)
+	m.unindexChitElement(e)
+}
+
+// similarChitNames は、正規化した名前が normalized に近いチットの名前を近い順に返す。
+//
+// 編集距離が名前の長さの3分の1（最低1）以下のものを、最大 MAX_CHIT_NAME_SUGGESTIONS 個返す。
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) similarChitNames(normalized string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	maxDistance := len([]rune(normalized)) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	candidates := []candidate{}
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		name := e.Value.(*Chit).Name
+		d := editDistance(normalized, NormalizeChitName(name))
+		if d <= maxDistance {
+			candidates = append(candidates, candidate{name: name, distance: d})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	names := []string{}
+	for _, c := range candidates {
+		if len(names) >= MAX_CHIT_NAME_SUGGESTIONS {
+			break
+		}
+
+		names = append(names, c.name)
+	}
+
+	return names
+}
+
+// editDistance は、文字列 a と b の編集距離（レーベンシュタイン距離）を返す。
+func editDistance(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// minInt は整数の最小値を返す。
+func minInt(v int, others ...int) int {
+	for _, o := range others {
+		if o < v {
+			v = o
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,252 @@
+package rpgmap
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeChitName(t *testing.T) {
+	testcases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Goblin 1", expected: "goblin 1"},
+		{name: "  GOBLIN   1 ", expected: "goblin 1"},
+		{name: "Ｇｏｂｌｉｎ　１", expected: "goblin 1"},
+		{name: "ｺﾞﾌﾞﾘﾝ", expected: "ゴブリン"},
+		{name: "Straße", expected: "strasse"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			actual := NormalizeChitName(test.name)
+			if actual != test.expected {
+				t.Errorf("got: %q, want: %q", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestSquareMap_LookupChit(t *testing.T) {
+	testcases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Goblin 1", expected: "Goblin 1"},
+		{name: "goblin 1", expected: "Goblin 1"},
+		{name: "Ｇｏｂｌｉｎ　２", expected: "Goblin 2"},
+		{name: " fighter ", expected: "Fighter"},
+		{name: "fi", expected: "Fighter"},
+		{name: "ゆう", expected: "ゆうしゃ"},
+	}
+
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Goblin 1", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 1, Y: 0})
+	m.AddChit(&Chit{Name: "Fighter", X: 2, Y: 0})
+	m.AddChit(&Chit{Name: "ゆうしゃ", X: 3, Y: 0})
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := m.LookupChit(test.name)
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if c.Name != test.expected {
+				t.Errorf("got: %s, want: %s", c.Name, test.expected)
+			}
+		})
+	}
+}
+
+func TestSquareMap_LookupChit_NotFound(t *testing.T) {
+	testcases := []struct {
+		name        string
+		ambiguous   bool
+		suggestions []string
+	}{
+		{name: "gob", ambiguous: true, suggestions: []string{"Goblin 1", "Goblin 2"}},
+		{name: "Figter", suggestions: []string{"Fighter"}},
+		{name: "Goblin 3", suggestions: []string{"Goblin 1", "Goblin 2"}},
+		{name: "Dragon", suggestions: []string{}},
+	}
+
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Goblin 1", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 1, Y: 0})
+	m.AddChit(&Chit{Name: "Fighter", X: 2, Y: 0})
+	m.AddChit(&Chit{Name: "ゆうしゃ", X: 3, Y: 0})
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := m.LookupChit(test.name)
+			notFoundErr, ok := err.(*ChitNotFoundError)
+			if !ok {
+				t.Fatalf("got err: %v", err)
+			}
+
+			if notFoundErr.Ambiguous != test.ambiguous {
+				t.Errorf("Ambiguous: got: %v, want: %v", notFoundErr.Ambiguous, test.ambiguous)
+			}
+
+			if !reflect.DeepEqual(notFoundErr.Suggestions, test.suggestions) {
+				t.Errorf("Suggestions: got: %v, want: %v", notFoundErr.Suggestions, test.suggestions)
+			}
+		})
+	}
+}
+
+func TestSquareMap_FindChit_DoesNotMatchPrefix(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Goblin 1", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 1, Y: 0})
+	m.AddChit(&Chit{Name: "Fighter", X: 2, Y: 0})
+	m.AddChit(&Chit{Name: "ゆうしゃ", X: 3, Y: 0})
+
+	if c, found := m.FindChit("ｆｉｇｈｔｅｒ"); !found || c.Name != "Fighter" {
+		t.Errorf("normalized name: got: %v, %t", c, found)
+	}
+
+	if _, found := m.FindChit("fi"); found {
+		t.Error("chit is found by prefix")
+	}
+
+	// 名前の先頭だけが一致するチットは操作しない
+	err := m.DeleteChit("fi")
+	notFoundErr, ok := err.(*ChitNotFoundError)
+	if !ok {
+		t.Fatalf("got err: %v", err)
+	}
+
+	if expected := []string{"Fighter"}; !reflect.DeepEqual(notFoundErr.Suggestions, expected) {
+		t.Errorf("Suggestions: got: %v, want: %v", notFoundErr.Suggestions, expected)
+	}
+
+	if _, err := m.RenameChit("ゆう", "Hero"); err == nil {
+		t.Error("RenameChit: expected err")
+	}
+
+	if _, err := m.MoveChit("fi", 5, 5); err == nil {
+		t.Error("MoveChit: expected err")
+	}
+
+	if n := m.NumOfChits(); n != 4 {
+		t.Errorf("NumOfChits: got: %d, want: %d", n, 4)
+	}
+}
+
+func TestSquareMap_NormalizedNameIsUnique(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Goblin 1", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "Goblin 2", X: 1, Y: 0})
+	m.AddChit(&Chit{Name: "Fighter", X: 2, Y: 0})
+	m.AddChit(&Chit{Name: "ゆうしゃ", X: 3, Y: 0})
+
+	if err := m.AddChit(&Chit{Name: "GOBLIN 1", X: 5, Y: 5}); err == nil {
+		t.Error("AddChit: expected err")
+	}
+
+	if _, err := m.RenameChit("Fighter", "goblin 2"); err == nil {
+		t.Error("RenameChit: expected err")
+	}
+
+	// 大文字と小文字の違いは直せる
+	c, err := m.RenameChit("fighter", "FIGHTER")
+	if err != nil {
+		t.Fatalf("RenameChit: got err: %s", err)
+	}
+
+	if c.Name != "FIGHTER" {
+		t.Errorf("got: %s, want: %s", c.Name, "FIGHTER")
+	}
+}
+
+func TestSquareMap_NormalizedNameFollowsChanges(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "Goblin 1", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "Fighter", X: 2, Y: 0})
+
+	if _, err := m.RenameChit("Fighter", "Hero"); err != nil {
+		t.Fatalf("RenameChit: got err: %s", err)
+	}
+
+	if _, found := m.FindChit("fighter"); found {
+		t.Error("old name is found")
+	}
+
+	if c, found := m.FindChit("ＨＥＲＯ"); !found || c.Name != "Hero" {
+		t.Errorf("new name: got: %v, %t", c, found)
+	}
+
+	if err := m.DeleteChit("goblin 1"); err != nil {
+		t.Fatalf("DeleteChit: got err: %s", err)
+	}
+
+	if _, found := m.FindChit("GOBLIN 1"); found {
+		t.Error("deleted chit is found")
+	}
+
+	// 削除したチットの名前は再び使える
+	if err := m.AddChit(&Chit{Name: "goblin 1", X: 0, Y: 0}); err != nil {
+		t.Errorf("AddChit: got err: %s", err)
+	}
+}
+
+func TestSquareMap_ChitNameSuggestionsAreLimited(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	for x := 0; x < 10; x++ {
+		m.AddChit(&Chit{Name: fmt.Sprintf("goblin %d", x+1), X: x, Y: 0})
+	}
+
+	expected := []string{"goblin 1", "goblin 2", "goblin 3"}
+
+	_, err := m.LookupChit("gob")
+	lookupErr, ok := err.(*ChitNotFoundError)
+	if !ok {
+		t.Fatalf("LookupChit: got err: %v", err)
+	}
+
+	if !reflect.DeepEqual(lookupErr.Suggestions, expected) {
+		t.Errorf("LookupChit: got: %v, want: %v", lookupErr.Suggestions, expected)
+	}
+
+	err = m.DeleteChit("gob")
+	deleteErr, ok := err.(*ChitNotFoundError)
+	if !ok {
+		t.Fatalf("DeleteChit: got err: %v", err)
+	}
+
+	if !reflect.DeepEqual(deleteErr.Suggestions, expected) {
+		t.Errorf("DeleteChit: got: %v, want: %v", deleteErr.Suggestions, expected)
+	}
+}
+
+func TestChitNotFoundError_Error(t *testing.T) {
+	testcases := []struct {
+		err      *ChitNotFoundError
+		expected string
+	}{
+		{
+			err:      &ChitNotFoundError{Name: "Dragon"},
+			expected: "chit not found: Dragon",
+		},
+		{
+			err:      &ChitNotFoundError{Name: "Figter", Suggestions: []string{"Fighter"}},
+			expected: `chit not found: Figter (did you mean "Fighter"?)`,
+		},
+		{
+			err:      &ChitNotFoundError{Name: "gob", Ambiguous: true, Suggestions: []string{"Goblin 1", "Goblin 2"}},
+			expected: `chit name is ambiguous: gob (did you mean "Goblin 1", "Goblin 2"?)`,
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.expected, func(t *testing.T) {
+			if actual := test.err.Error(); actual != test.expected {
+				t.Errorf("got: %s, want: %s", actual, test.expected)
+			}
+		})
+	}
+}
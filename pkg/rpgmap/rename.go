@@ -0,0 +1,83 @@
+package rpgmap
+
+import (
+	"fmt"
+	"image"
+)
+
+// RenameChit はチットの名前を変更する。
+//
+// チットの順番、色などの属性はそのまま保たれる。
+// 移動の記録のチットの名前も変更する。
+// 変更後のチットの複製を返す。
+func (m *SquareMap) RenameChit(name string, newName string) (*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	e, err := m.findChitElement(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// 大文字と小文字の違いなどを直すために、正規化した名前が同じ名前への変更は認める
+	if err := m.validateNewChitName(newName, e); err != nil {
+		return nil, err
+	}
+
+	c := e.Value.(*Chit)
+	oldName := c.Name
+
+	m.unindexChitElement(e)
+	c.Name = newName
+	m.indexChitElement(e)
+
+	for i := range m.moves {
+		if m.moves[i].Name == oldName {
+			m.moves[i].Name = newName
+		}
+	}
+
+	copied := *c
+	return &copied, nil
+}
+
+// CloneChit はチットを複製し、newName という名前で追加する。
+//
+// 複製したチットは、near から最も近い空いているマスに置く。
+// 色、グループ、形、画像、イニシアチブは元のチットと同じにし、移動の状態は引き継がない。
+// 追加したチットの複製を返す。
+func (m *SquareMap) CloneChit(name string, newName string, near image.Point) (*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	c, err := m.findChit(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mapRect := image.Rect(0, 0, m.width, m.height)
+	if !near.In(mapRect) {
+		return nil, fmt.Errorf("(%d, %d) is out of range", near.X+1, near.Y+1)
+	}
+
+	free := m.freeCells(cellsNear(near, mapRect), 1)
+	if len(free) < 1 {
+		return nil, fmt.Errorf("no free cells")
+	}
+
+	clone := *c
+	clone.Name = newName
+	clone.X = free[0].X
+	clone.Y = free[0].Y
+	clone.Moved = false
+	clone.PrevX = 0
+	clone.PrevY = 0
+
+	if err := m.validateNewChit(&clone); err != nil {
+		return nil, err
+	}
+
+	m.pushChit(&clone)
+
+	return &clone, nil
+}
@@ -0,0 +1,128 @@
+package rpgmap
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestSquareMap_RenameChit(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Color: red, Group: "PC", Initiative: 12})
+	m.AddChit(&Chit{Name: "B", X: 5, Y: 5})
+	m.MoveChit("A", 1, 2)
+
+	renamed, err := m.RenameChit("A", "Alice")
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expected := Chit{
+		Name:       "Alice",
+		X:          1,
+		Y:          2,
+		Color:      red,
+		Group:      "PC",
+		Initiative: 12,
+		Moved:      true,
+		PrevX:      0,
+		PrevY:      0,
+	}
+	if !reflect.DeepEqual(*renamed, expected) {
+		t.Errorf("got: %+v, want: %+v", *renamed, expected)
+	}
+
+	// 順番は変わらない
+	expectedNames := []string{"Alice", "B"}
+	if actual := chitNames(m.snapshotChits()); !reflect.DeepEqual(actual, expectedNames) {
+		t.Errorf("names: got: %v, want: %v", actual, expectedNames)
+	}
+
+	if _, found := m.FindChit("A"); found {
+		t.Error("old name is still found")
+	}
+
+	if moves := m.LastMoves(1); moves[0].Name != "Alice" {
+		t.Errorf("move name: got: %s, want: %s", moves[0].Name, "Alice")
+	}
+
+	testcases := []struct {
+		title   string
+		name    string
+		newName string
+	}{
+		{title: "not found", name: "A", newName: "C"},
+		{title: "existing name", name: "Alice", newName: "B"},
+		{title: "empty name", name: "Alice", newName: ""},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := m.RenameChit(test.name, test.newName); err == nil {
+				t.Error("expected err")
+			}
+		})
+	}
+}
+
+func TestSquareMap_CloneChit(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	m.AddChit(&Chit{Name: "A", X: 3, Y: 3, Color: red, Group: "PC", Shape: SHAPE_SQUARE})
+	m.MoveChit("A", 4, 4)
+
+	clone, err := m.CloneChit("A", "A'", image.Pt(4, 4))
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	expected := Chit{
+		Name:  "A'",
+		X:     3,
+		Y:     3,
+		Color: red,
+		Group: "PC",
+		Shape: SHAPE_SQUARE,
+	}
+	if !reflect.DeepEqual(*clone, expected) {
+		t.Errorf("got: %+v, want: %+v", *clone, expected)
+	}
+
+	if n := m.NumOfChits(); n != 2 {
+		t.Errorf("NumOfChits: got: %d, want: %d", n, 2)
+	}
+
+	clone, err = m.CloneChit("A", "A''", image.Pt(0, 0))
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if clone.X != 0 || clone.Y != 0 {
+		t.Errorf("coordinates: got: (%d, %d), want: (0, 0)", clone.X, clone.Y)
+	}
+
+	testcases := []struct {
+		title   string
+		name    string
+		newName string
+		near    image.Point
+	}{
+		{title: "not found", name: "B", newName: "C", near: image.Pt(0, 0)},
+		{title: "existing name", name: "A", newName: "A'", near: image.Pt(0, 0)},
+		{title: "out of range", name: "A", newName: "C", near: image.Pt(10, 0)},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := m.CloneChit(test.name, test.newName, test.near); err == nil {
+				t.Error("expected err")
+			}
+
+			if n := m.NumOfChits(); n != 3 {
+				t.Errorf("NumOfChits: got: %d, want: %d", n, 3)
+			}
+		})
+	}
+}
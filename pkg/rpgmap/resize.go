@@ -0,0 +1,199 @@
+package rpgmap
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+const (
+	// ANCHOR_TOP_LEFT は、マップの大きさを変える際に左上を固定することを表す。
+	ANCHOR_TOP_LEFT = "top-left"
+	// ANCHOR_TOP は、マップの大きさを変える際に上辺の中央を固定することを表す。
+	ANCHOR_TOP = "top"
+	// ANCHOR_TOP_RIGHT は、マップの大きさを変える際に右上を固定することを表す。
+	ANCHOR_TOP_RIGHT = "top-right"
+	// ANCHOR_LEFT は、マップの大きさを変える際に左辺の中央を固定することを表す。
+	ANCHOR_LEFT = "left"
+	// ANCHOR_CENTER は、マップの大きさを変える際に中央を固定することを表す。
+	ANCHOR_CENTER = "center"
+	// ANCHOR_RIGHT は、マップの大きさを変える際に右辺の中央を固定することを表す。
+	ANCHOR_RIGHT = "right"
+	// ANCHOR_BOTTOM_LEFT は、マップの大きさを変える際に左下を固定することを表す。
+	ANCHOR_BOTTOM_LEFT = "bottom-left"
+	// ANCHOR_BOTTOM は、マップの大きさを変える際に下辺の中央を固定することを表す。
+	ANCHOR_BOTTOM = "bottom"
+	// ANCHOR_BOTTOM_RIGHT は、マップの大きさを変える際に右下を固定することを表す。
+	ANCHOR_BOTTOM_RIGHT = "bottom-right"
+)
+
+// Anchors は、マップの大きさを変える際に固定する位置の一覧。
+var Anchors = []string{
+	ANCHOR_TOP_LEFT,
+	ANCHOR_TOP,
+	ANCHOR_TOP_RIGHT,
+	ANCHOR_LEFT,
+	ANCHOR_CENTER,
+	ANCHOR_RIGHT,
+	ANCHOR_BOTTOM_LEFT,
+	ANCHOR_BOTTOM,
+	ANCHOR_BOTTOM_RIGHT,
+}
+
+// anchorRatios は、固定する位置 -> 大きさの差のうち左上側に割り当てる割合（0〜2の半分単位）の対応。
+var anchorRatios = map[string]image.Point{
+	ANCHOR_TOP_LEFT:     {0, 0},
+	ANCHOR_TOP:          {1, 0},
+	ANCHOR_TOP_RIGHT:    {2, 0},
+	ANCHOR_LEFT:         {0, 1},
+	ANCHOR_CENTER:       {1, 1},
+	ANCHOR_RIGHT:        {2, 1},
+	ANCHOR_BOTTOM_LEFT:  {0, 2},
+	ANCHOR_BOTTOM:       {1, 2},
+	ANCHOR_BOTTOM_RIGHT: {2, 2},
+}
+
+// IsValidAnchor は、anchor がマップの大きさを変える際に固定する位置として正しいかを返す。
+func IsValidAnchor(anchor string) bool {
+	_, ok := anchorRatios[anchor]
+	return ok
+}
+
+// OutOfRangeError は、マップの範囲外に出るチットがあることを表すエラー。
+type OutOfRangeError struct {
+	// Names は範囲外に出るチットの名前。
+	Names []string
+}
+
+// Error はエラーメッセージを返す。
+func (e *OutOfRangeError) Error() string {
+	return fmt.Sprintf("chits would be out of range: %s", strings.Join(e.Names, ", "))
+}
+
+// Resize はマップの大きさを変える。
+//
+// anchor で指定した位置を固定し、チットと背景をそれに合わせて移動する。
+// 範囲外に出るチットがある場合、drop が false ならば何も変更せずに
+// *OutOfRangeError を返し、true ならばそのチットを削除してその名前の配列を返す。
+func (m *SquareMap) Resize(width int, height int, anchor string, drop bool) ([]string, error) {
+	if width < 2 {
+		return nil, fmt.Errorf("width must be greater than or equal to 2 (%d)", width)
+	}
+
+	if height < 2 {
+		return nil, fmt.Errorf("height must be greater than or equal to 2 (%d)", height)
+	}
+
+	ratio, ok := anchorRatios[anchor]
+	if !ok {
+		return nil, fmt.Errorf("unknown anchor: %s", anchor)
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	// 新しいマップの左上の、元のマップでの位置
+	min := image.Pt(
+		(m.width-width)*ratio.X/2,
+		(m.height-height)*ratio.Y/2,
+	)
+
+	return m.crop(image.Rectangle{Min: min, Max: min.Add(image.Pt(width, height))}, drop)
+}
+
+// Crop は、マップを r の範囲に切り抜く。
+//
+// r は元のマップのマス単位の矩形（Max は含まない）で、元のマップの範囲外を含んでもよい。
+// r の左上が新しいマップの左上になるように、チットと背景を移動する。
+// 範囲外に出るチットの扱いは Resize と同じ。
+func (m *SquareMap) Crop(r image.Rectangle, drop bool) ([]string, error) {
+	r = r.Canon()
+	if r.Dx() < 2 || r.Dy() < 2 {
+		return nil, fmt.Errorf("size must be greater than or equal to 2 x 2 (%d x %d)", r.Dx(), r.Dy())
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.crop(r, drop)
+}
+
+// Shift は、マップの大きさを変えずにチットと背景を (dx, dy) だけ移動する。
+//
+// 範囲外に出るチットの扱いは Resize と同じ。
+func (m *SquareMap) Shift(dx int, dy int, drop bool) ([]string, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	min := image.Pt(-dx, -dy)
+	return m.crop(image.Rectangle{Min: min, Max: min.Add(image.Pt(m.width, m.height))}, drop)
+}
+
+// crop は、マップを r の範囲に切り抜く。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) crop(r image.Rectangle, drop bool) ([]string, error) {
+	outside := []string{}
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		c := e.Value.(*Chit)
+		if !image.Pt(c.X, c.Y).In(r) {
+			outside = append(outside, c.Name)
+		}
+	}
+
+	if len(outside) > 0 && !drop {
+		return nil, &OutOfRangeError{Names: outside}
+	}
+
+	for _, name := range outside {
+		m.removeChitElement(m.nameToChitListElement[name])
+	}
+
+	if len(outside) > 0 {
+		m.pruneGroupColors()
+	}
+
+	dx, dy := -r.Min.X, -r.Min.Y
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		c := e.Value.(*Chit)
+
+		// 移動前のマスが範囲外に出る場合は、直前の移動を強調しない
+		if c.Moved && !image.Pt(c.PrevX, c.PrevY).In(r) {
+			c.Moved = false
+			c.PrevX = 0
+			c.PrevY = 0
+		} else {
+			c.PrevX += dx
+			c.PrevY += dy
+		}
+
+		c.X += dx
+		c.Y += dy
+	}
+
+	// 移動の記録は、移動前後のマスが範囲内に残るものだけを残す
+	moves := make([]Move, 0, len(m.moves))
+	for _, mv := range m.moves {
+		if !image.Pt(mv.FromX, mv.FromY).In(r) || !image.Pt(mv.ToX, mv.ToY).In(r) {
+			continue
+		}
+
+		mv.FromX += dx
+		mv.FromY += dy
+		mv.ToX += dx
+		mv.ToY += dy
+		moves = append(moves, mv)
+	}
+
+	m.moves = moves
+
+	if m.background != nil {
+		m.background.ShiftX += dx
+		m.background.ShiftY += dy
+	}
+
+	m.width = r.Dx()
+	m.height = r.Dy()
+
+	return outside, nil
+}
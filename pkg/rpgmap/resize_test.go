@@ -0,0 +1,188 @@
+package rpgmap
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestSquareMap_Resize(t *testing.T) {
+	testcases := []struct {
+		anchor   string
+		width    int
+		height   int
+		expected []image.Point
+	}{
+		{anchor: ANCHOR_TOP_LEFT, width: 12, height: 10, expected: []image.Point{{0, 0}, {4, 3}, {9, 7}}},
+		{anchor: ANCHOR_CENTER, width: 12, height: 10, expected: []image.Point{{1, 1}, {5, 4}, {10, 8}}},
+		{anchor: ANCHOR_BOTTOM_RIGHT, width: 12, height: 10, expected: []image.Point{{2, 2}, {6, 5}, {11, 9}}},
+		{anchor: ANCHOR_TOP, width: 14, height: 8, expected: []image.Point{{2, 0}, {6, 3}, {11, 7}}},
+		{anchor: ANCHOR_LEFT, width: 10, height: 8, expected: []image.Point{{0, 0}, {4, 3}, {9, 7}}},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.anchor, func(t *testing.T) {
+			m, _ := NewSquareMap(10, 8)
+			m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Group: "PC"})
+			m.AddChit(&Chit{Name: "B", X: 4, Y: 3})
+			m.AddChit(&Chit{Name: "C", X: 9, Y: 7, Group: "Enemies"})
+
+			dropped, err := m.Resize(test.width, test.height, test.anchor, false)
+			if err != nil {
+				t.Fatalf("got err: %s", err)
+			}
+
+			if len(dropped) != 0 {
+				t.Errorf("dropped: %v", dropped)
+			}
+
+			if m.Width() != test.width || m.Height() != test.height {
+				t.Errorf("size: got: %s, want: %d x %d", m.SizeStr(), test.width, test.height)
+			}
+
+			if actual := chitPoints(m.snapshotChits()); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("got: %v, want: %v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestSquareMap_Resize_OutOfRange(t *testing.T) {
+	m, _ := NewSquareMap(10, 8)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "B", X: 4, Y: 3})
+	m.AddChit(&Chit{Name: "C", X: 9, Y: 7, Group: "Enemies"})
+
+	_, err := m.Resize(6, 6, ANCHOR_TOP_LEFT, false)
+	outErr, ok := err.(*OutOfRangeError)
+	if !ok {
+		t.Fatalf("got err: %v", err)
+	}
+
+	expectedNames := []string{"C"}
+	if !reflect.DeepEqual(outErr.Names, expectedNames) {
+		t.Errorf("Names: got: %v, want: %v", outErr.Names, expectedNames)
+	}
+
+	// 何も変更されない
+	if m.SizeStr() != "10 x 8" || m.NumOfChits() != 3 {
+		t.Errorf("map was changed: %s, %d chits", m.SizeStr(), m.NumOfChits())
+	}
+
+	dropped, err := m.Resize(6, 6, ANCHOR_TOP_LEFT, true)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if !reflect.DeepEqual(dropped, expectedNames) {
+		t.Errorf("dropped: got: %v, want: %v", dropped, expectedNames)
+	}
+
+	if _, found := m.GroupColor("Enemies"); found {
+		t.Error("color of empty group is not released")
+	}
+
+	for _, test := range []struct {
+		width  int
+		height int
+		anchor string
+	}{
+		{width: 1, height: 6, anchor: ANCHOR_TOP_LEFT},
+		{width: 6, height: 1, anchor: ANCHOR_TOP_LEFT},
+		{width: 6, height: 6, anchor: "middle"},
+	} {
+		if _, err := m.Resize(test.width, test.height, test.anchor, true); err == nil {
+			t.Errorf("%d x %d %s: expected err", test.width, test.height, test.anchor)
+		}
+	}
+}
+
+func TestSquareMap_Shift(t *testing.T) {
+	m, _ := NewSquareMap(10, 8)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "B", X: 4, Y: 3})
+	m.AddChit(&Chit{Name: "C", X: 9, Y: 7, Group: "Enemies"})
+	m.SetBackground(NewBackground(image.NewRGBA(image.Rect(0, 0, 1, 1)), ""))
+	m.MoveChit("B", 5, 3)
+
+	if _, err := m.Shift(1, 0, false); err == nil {
+		t.Fatal("expected err")
+	}
+
+	dropped, err := m.Shift(-1, 0, true)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if expected := []string{"A"}; !reflect.DeepEqual(dropped, expected) {
+		t.Errorf("dropped: got: %v, want: %v", dropped, expected)
+	}
+
+	b, _ := m.FindChit("B")
+	if b.X != 4 || b.PrevX != 3 {
+		t.Errorf("B: got: X=%d, PrevX=%d, want: X=4, PrevX=3", b.X, b.PrevX)
+	}
+
+	expectedMoves := []Move{{Name: "B", FromX: 3, FromY: 3, ToX: 4, ToY: 3, Round: 1}}
+	if actual := m.LastMoves(1); !reflect.DeepEqual(actual, expectedMoves) {
+		t.Errorf("moves: got: %+v, want: %+v", actual, expectedMoves)
+	}
+
+	bg, _ := m.Background()
+	if bg.ShiftX != -1 || bg.ShiftY != 0 {
+		t.Errorf("background shift: got: (%d, %d), want: (-1, 0)", bg.ShiftX, bg.ShiftY)
+	}
+}
+
+func TestSquareMap_Crop(t *testing.T) {
+	m, _ := NewSquareMap(10, 8)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&Chit{Name: "B", X: 4, Y: 3})
+	m.AddChit(&Chit{Name: "C", X: 9, Y: 7, Group: "Enemies"})
+
+	dropped, err := m.Crop(image.Rect(3, 2, 10, 8), true)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if expected := []string{"A"}; !reflect.DeepEqual(dropped, expected) {
+		t.Errorf("dropped: got: %v, want: %v", dropped, expected)
+	}
+
+	if m.SizeStr() != "7 x 6" {
+		t.Errorf("size: got: %s, want: %s", m.SizeStr(), "7 x 6")
+	}
+
+	expected := []image.Point{{1, 1}, {6, 5}}
+	if actual := chitPoints(m.snapshotChits()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got: %v, want: %v", actual, expected)
+	}
+}
+
+func TestSquareMap_Crop_DropsMovesOutOfRange(t *testing.T) {
+	m, _ := NewSquareMap(10, 8)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 1})
+	m.AddChit(&Chit{Name: "B", X: 6, Y: 6})
+	m.MoveChit("A", 5, 5)
+	m.MoveChit("B", 7, 6)
+
+	if _, err := m.Crop(image.Rect(4, 4, 10, 8), false); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	// 移動前のマスが範囲外に出た移動は記録から除く
+	expectedMoves := []Move{{Name: "B", FromX: 2, FromY: 2, ToX: 3, ToY: 2, Round: 1}}
+	if actual := m.LastMoves(MAX_MOVE_HISTORY); !reflect.DeepEqual(actual, expectedMoves) {
+		t.Errorf("moves: got: %+v, want: %+v", actual, expectedMoves)
+	}
+
+	a, _ := m.FindChit("A")
+	if a.Moved {
+		t.Errorf("A: Moved: got: %t, want: %t (Prev: (%d, %d))", a.Moved, false, a.PrevX, a.PrevY)
+	}
+
+	b, _ := m.FindChit("B")
+	if !b.Moved || b.PrevX != 2 || b.PrevY != 2 {
+		t.Errorf("B: got: Moved=%t, Prev=(%d, %d), want: Moved=true, Prev=(2, 2)", b.Moved, b.PrevX, b.PrevY)
+	}
+}
@@ -4,7 +4,11 @@ package rpgmap
 import (
 	"container/list"
 	"fmt"
+	"image"
+	"image/color"
 	"sync"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
 )
 
 // stringChitMap は、文字列 -> チットの対応の型。
@@ -22,8 +26,22 @@ type SquareMap struct {
 	chitList *list.List
 	// nameToChitListElement はチットの名前とチットとの対応。
 	nameToChitListElement stringListElementMap
-	// mux は排他制御用のミューテックス。
-	mux sync.Mutex
+	// normalizedNameToChitListElement は正規化したチットの名前とチットとの対応。
+	normalizedNameToChitListElement stringListElementMap
+	// background は背景。設定されていなければ nil。
+	background *Background
+	// round は現在のラウンド。
+	round int
+	// moves はチットの移動の記録。
+	moves []Move
+	// colors はチットの色の割り当て器。
+	colors *colorutil.ChitColorAllocator
+	// paletteName はチットの色のパレットの名前。
+	paletteName string
+	// groupColors はチットのグループの名前 -> グループの色の対応。
+	groupColors map[string]color.RGBA
+	// mux は排他制御用の読み書きミューテックス。
+	mux sync.RWMutex
 }
 
 // NewSquareMap は新しいスクエアマップを返す。
@@ -37,21 +55,32 @@ func NewSquareMap(width int, height int) (*SquareMap, error) {
 	}
 
 	return &SquareMap{
-		width:                 width,
-		height:                height,
-		chits:                 []*Chit{},
-		chitList:              list.New(),
-		nameToChitListElement: stringListElementMap{},
+		width:                           width,
+		height:                          height,
+		chits:                           []*Chit{},
+		chitList:                        list.New(),
+		nameToChitListElement:           stringListElementMap{},
+		normalizedNameToChitListElement: stringListElementMap{},
+		round:                           1,
+		colors:                          colorutil.NewChitColorAllocator(0),
+		paletteName:                     colorutil.PALETTE_DEFAULT,
+		groupColors:                     map[string]color.RGBA{},
 	}, nil
 }
 
 // Width はマップの幅を返す。
 func (m *SquareMap) Width() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
 	return m.width
 }
 
 // Height はマップの高さを返す。
 func (m *SquareMap) Height() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
 	return m.height
 }
 
@@ -62,102 +91,329 @@ func (m *SquareMap) String() string {
 
 // SizeStr はマップの大きさを表す文字列を返す。
 func (m *SquareMap) SizeStr() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
 	return fmt.Sprintf("%d x %d", m.width, m.height)
 }
 
 // NumOfChits はマップに含まれるチット数を返す。
 func (m *SquareMap) NumOfChits() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
 	return m.chitList.Len()
 }
 
 // FindChit は名前からチットを検索する。
+//
+// 名前が完全に一致するか、正規化した名前が一致するチットを返す。
+// 名前の先頭だけが一致するチットは返さない。
+// 返り値はチットの複製であり、変更してもマップには反映されない。
 func (m *SquareMap) FindChit(name string) (*Chit, bool) {
-	e, found := m.nameToChitListElement[name]
-	if !found {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	c, err := m.findChit(name)
+	if err != nil {
 		return nil, false
 	}
 
-	return e.Value.(*Chit), true
+	copied := *c
+	return &copied, true
 }
 
-// 各チットに対して処理を行う。
+// ForEachChit は各チットに対して処理を行う。
+//
+// 呼び出し時点のチットの複製に対して処理を行うため、
+// f の中からマップを変更しても構わない。
 func (m *SquareMap) ForEachChit(f func(i int, c *Chit)) {
-	i := 0
+	for i, c := range m.snapshotChits() {
+		f(i, c)
+	}
+}
+
+// snapshotChits はチットの複製の配列を返す。
+func (m *SquareMap) snapshotChits() []*Chit {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	chits := make([]*Chit, 0, m.chitList.Len())
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		copied := *e.Value.(*Chit)
+		chits = append(chits, &copied)
+	}
+
+	return chits
+}
+
+// SetChitColorSeed は、チットの色の割り当てに使う乱数のシードを設定する。
+func (m *SquareMap) SetChitColorSeed(seed int64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.colors.Seed(seed)
+}
+
+// ChitPalette は、チットの色のパレットの名前と色の配列を返す。
+func (m *SquareMap) ChitPalette() (string, []color.RGBA) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.paletteName, m.colors.Palette()
+}
+
+// SetChitPalette はチットの色のパレットを変更する。
+//
+// 色が自動で割り当てられたチットには、新しいパレットの色を追加した順に割り当て直す。
+// ユーザーが指定した色のチットは、変更前のパレットの色であってもそのままにする。
+func (m *SquareMap) SetChitPalette(name string) error {
+	palette, err := colorutil.Palette(name)
+	if err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.colors.SetPalette(palette)
+	m.paletteName = name
+
+	// 割り当て直さないチットの色を先に使用中とする
+	used := []color.RGBA{}
+	recolored := []*Chit{}
 	for e := m.chitList.Front(); e != nil; e = e.Next() {
-		f(i, e.Value.(*Chit))
-		i++
+		c := e.Value.(*Chit)
+		if c.AutoColor {
+			recolored = append(recolored, c)
+		} else {
+			used = append(used, c.Color)
+		}
 	}
+
+	for _, c := range recolored {
+		c.Color = m.colors.Allocate(used)
+		used = append(used, c.Color)
+	}
+
+	return nil
 }
 
 // AddChit はチットを追加する。
+//
+// チットの色が設定されていない（ゼロ値の）場合は、マップ上のチットで
+// 使われていない色を割り当て、c の色と AutoColor も書き換える。
 func (m *SquareMap) AddChit(c *Chit) error {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	if _, found := m.FindChit(c.Name); found {
-		return fmt.Errorf(`chit "%s" already exists`, c.Name)
+	if err := m.validateNewChit(c); err != nil {
+		return err
+	}
+
+	m.pushChit(c)
+
+	return nil
+}
+
+// validateNewChit は、チットを追加できるかを確認する。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) validateNewChit(c *Chit) error {
+	if err := m.validateNewChitName(c.Name, nil); err != nil {
+		return err
 	}
 
-	if !m.XIsInRange(c.X) {
+	if !m.xIsInRange(c.X) {
 		return fmt.Errorf("X is out of range: %d", c.X)
 	}
 
-	if !m.YIsInRange(c.Y) {
+	if !m.yIsInRange(c.Y) {
 		return fmt.Errorf("Y is out of range: %d", c.Y)
 	}
 
-	e := m.chitList.PushBack(c)
-	m.nameToChitListElement[c.Name] = e
+	if c.Shape != "" && !IsValidChitShape(c.Shape) {
+		return fmt.Errorf("unknown shape: %s", c.Shape)
+	}
+
+	if c.Moved && (!m.xIsInRange(c.PrevX) || !m.yIsInRange(c.PrevY)) {
+		return fmt.Errorf("previous position is out of range: (%d, %d)", c.PrevX, c.PrevY)
+	}
 
 	return nil
 }
 
+// pushChit はチットを末尾に追加する。
+//
+// チットの色が設定されていない場合は色を割り当て、c の色と AutoColor も書き換える。
+// 呼び出し側でロックを取得し、validateNewChit で確認しておくこと。
+func (m *SquareMap) pushChit(c *Chit) {
+	if c.Color == (color.RGBA{}) {
+		c.Color = m.colors.Allocate(m.usedChitColors())
+		c.AutoColor = true
+	}
+
+	// 呼び出し側からの変更の影響を受けないように複製を格納する
+	copied := *c
+	e := m.chitList.PushBack(&copied)
+	m.indexChitElement(e)
+	m.ensureGroupColor(c.Group)
+}
+
+// usedChitColors はマップ上のチットの色の配列を返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) usedChitColors() []color.RGBA {
+	colors := make([]color.RGBA, 0, m.chitList.Len())
+	for e := m.chitList.Front(); e != nil; e = e.Next() {
+		colors = append(colors, e.Value.(*Chit).Color)
+	}
+
+	return colors
+}
+
 // DeleteChit はチットを削除する。
+//
+// 削除したチットの色は、次に追加するチットに再び割り当てられる。
 func (m *SquareMap) DeleteChit(name string) error {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	e, found := m.nameToChitListElement[name]
-	if !found {
-		return fmt.Errorf("chit not found: %s", name)
+	e, err := m.findChitElement(name)
+	if err != nil {
+		return err
 	}
 
-	m.chitList.Remove(e)
-	delete(m.nameToChitListElement, name)
+	m.removeChitElement(e)
+	m.pruneGroupColors()
 
 	return nil
 }
 
 // MoveChit はチットを移動する。
+//
+// 移動後のチットの複製を返す。
 func (m *SquareMap) MoveChit(name string, newX int, newY int) (*Chit, error) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	c, ok := m.FindChit(name)
-	if !ok {
-		return nil, fmt.Errorf("chit not found: %s", name)
+	c, err := m.findChit(name)
+	if err != nil {
+		return nil, err
 	}
 
-	if !m.XIsInRange(newX) {
+	if !m.xIsInRange(newX) {
 		return nil, fmt.Errorf("newX is out of range: %d", newX)
 	}
 
-	if !m.YIsInRange(newY) {
+	if !m.yIsInRange(newY) {
 		return nil, fmt.Errorf("newY is out of range: %d", newY)
 	}
 
+	m.recordMove(Move{
+		Name:  c.Name,
+		FromX: c.X,
+		FromY: c.Y,
+		ToX:   newX,
+		ToY:   newY,
+	})
+
+	c.Moved = true
+	c.PrevX = c.X
+	c.PrevY = c.Y
 	c.X = newX
 	c.Y = newY
 
-	return c, nil
+	copied := *c
+	return &copied, nil
+}
+
+// SetChitImage はチットの画像を設定する。
+//
+// img が nil の場合は画像を削除する。
+func (m *SquareMap) SetChitImage(name string, img image.Image, source string) (*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	c, err := m.findChit(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Image = img
+	c.ImageSource = source
+	if img == nil {
+		c.ImageSource = ""
+	}
+
+	copied := *c
+	return &copied, nil
+}
+
+// SetChitColor はチットの色を設定する。
+//
+// 設定した色は、パレットを変更しても割り当て直さない。
+// 設定後のチットの複製を返す。
+func (m *SquareMap) SetChitColor(name string, c color.RGBA) (*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	chit, err := m.findChit(name)
+	if err != nil {
+		return nil, err
+	}
+
+	chit.Color = c
+	chit.AutoColor = false
+
+	copied := *chit
+	return &copied, nil
+}
+
+// SetChitInitiative はチットのイニシアチブを設定する。
+//
+// 設定後のチットの複製を返す。
+func (m *SquareMap) SetChitInitiative(name string, initiative int) (*Chit, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	c, err := m.findChit(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Initiative = initiative
+
+	copied := *c
+	return &copied, nil
 }
 
 // XIsInRange は、x座標がマップの範囲内かを返す。
 func (m *SquareMap) XIsInRange(x int) bool {
-	return x >= 0 && x < m.width
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.xIsInRange(x)
 }
 
 // YIsInRange は、y座標がマップの範囲内かを返す。
 func (m *SquareMap) YIsInRange(y int) bool {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.yIsInRange(y)
+}
+
+// xIsInRange は、x座標がマップの範囲内かを返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) xIsInRange(x int) bool {
+	return x >= 0 && x < m.width
+}
+
+// yIsInRange は、y座標がマップの範囲内かを返す。
+//
+// 呼び出し側でロックを取得しておくこと。
+func (m *SquareMap) yIsInRange(y int) bool {
 	return y >= 0 && y < m.height
 }
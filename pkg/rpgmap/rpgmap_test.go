@@ -2,7 +2,12 @@ package rpgmap
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"sync"
 	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
 )
 
 func TestNewSquareMap(t *testing.T) {
@@ -401,3 +406,285 @@ func TestSquareMap_MoveChit_FailWhenChitNotFound(t *testing.T) {
 		t.Fatal("expected err")
 	}
 }
+
+func TestSquareMap_FindChit_ReturnsCopy(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 2})
+
+	c, _ := m.FindChit("A")
+	c.X = 5
+
+	actual, _ := m.FindChit("A")
+	if actual.X != 1 {
+		t.Fatalf("X: got %d, want %d", actual.X, 1)
+	}
+}
+
+func TestSquareMap_ForEachChit_CanModifyMapInCallback(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 2})
+	m.AddChit(&Chit{Name: "B", X: 2, Y: 3})
+
+	m.ForEachChit(func(_ int, c *Chit) {
+		m.DeleteChit(c.Name)
+	})
+
+	actual := m.NumOfChits()
+	if actual != 0 {
+		t.Fatalf("got: %d, want: %d", actual, 0)
+	}
+}
+
+// TestSquareMap_ConcurrentAccess は、読み書きを並行して行っても
+// データ競合が起こらないことを確かめる。
+//
+// go test -race で実行すること。
+func TestSquareMap_ConcurrentAccess(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+
+	const numOfWorkers = 8
+	const numOfIterations = 100
+
+	var wg sync.WaitGroup
+	for w := 0; w < numOfWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("C%d", w)
+			for i := 0; i < numOfIterations; i++ {
+				m.AddChit(&Chit{Name: name, X: i % 10, Y: w % 10})
+				m.MoveChit(name, (i+1)%10, w%10)
+				m.FindChit(name)
+				m.NumOfChits()
+				m.ForEachChit(func(_ int, c *Chit) {
+					_ = c.String()
+				})
+				m.DeleteChit(name)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	actual := m.NumOfChits()
+	if actual != 0 {
+		t.Fatalf("got: %d, want: %d", actual, 0)
+	}
+}
+
+func TestSquareMap_SetChitImage(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 2})
+
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	if _, err := m.SetChitImage("A", img, "a.png"); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	c, _ := m.FindChit("A")
+	if c.Image != img || c.ImageSource != "a.png" {
+		t.Fatalf("image is not set: %+v", c)
+	}
+
+	if _, err := m.SetChitImage("A", nil, "a.png"); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	c, _ = m.FindChit("A")
+	if c.Image != nil || c.ImageSource != "" {
+		t.Fatalf("image is not removed: %+v", c)
+	}
+
+	if _, err := m.SetChitImage("B", img, "b.png"); err == nil {
+		t.Fatal("expected err")
+	}
+}
+
+func TestSquareMap_SetChitInitiative(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 2})
+
+	c, err := m.SetChitInitiative("A", 15)
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if c.Initiative != 15 {
+		t.Errorf("returned Initiative: got %d, want %d", c.Initiative, 15)
+	}
+
+	found, _ := m.FindChit("A")
+	if found.Initiative != 15 {
+		t.Errorf("Initiative: got %d, want %d", found.Initiative, 15)
+	}
+
+	if _, err := m.SetChitInitiative("B", 3); err == nil {
+		t.Fatal("expected err")
+	}
+}
+
+func TestSquareMap_AddChit_AllocatesColor(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Color: colorutil.ChitColors[0]})
+
+	// 既存のチットの色と重複しない色を割り当てる
+	colors := map[color.RGBA]string{colorutil.ChitColors[0]: "A"}
+	for k := 1; k < len(colorutil.ChitColors); k++ {
+		c := &Chit{Name: fmt.Sprintf("C%d", k), X: k % 10, Y: 1}
+		if err := m.AddChit(c); err != nil {
+			t.Fatalf("got err: %s", err)
+		}
+
+		if other, found := colors[c.Color]; found {
+			t.Fatalf("%s has the same color as %s", c.Name, other)
+		}
+
+		colors[c.Color] = c.Name
+	}
+
+	// 削除したチットの色を再び割り当てる
+	deleted, _ := m.FindChit("C3")
+	m.DeleteChit("C3")
+
+	c := &Chit{Name: "D", X: 0, Y: 2}
+	m.AddChit(c)
+	if c.Color != deleted.Color {
+		t.Errorf("got: %v, want: %v", c.Color, deleted.Color)
+	}
+}
+
+func TestSquareMap_SetChitColorSeed(t *testing.T) {
+	newMap := func() *SquareMap {
+		m, _ := NewSquareMap(10, 10)
+		m.SetChitColorSeed(7)
+
+		for k := 0; k < 5; k++ {
+			m.AddChit(&Chit{Name: fmt.Sprintf("C%d", k), X: k, Y: 0})
+		}
+
+		return m
+	}
+
+	a := newMap()
+	b := newMap()
+	for k := 0; k < 5; k++ {
+		name := fmt.Sprintf("C%d", k)
+		ca, _ := a.FindChit(name)
+		cb, _ := b.FindChit(name)
+		if ca.Color != cb.Color {
+			t.Errorf("%s: got: %v, want: %v", name, cb.Color, ca.Color)
+		}
+	}
+}
+
+func TestSquareMap_SetChitPalette(t *testing.T) {
+	custom := color.RGBA{0x12, 0x34, 0x56, 0xFF}
+
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0})
+	m.AddChit(&Chit{Name: "B", X: 1, Y: 0, Color: custom})
+	m.AddChit(&Chit{Name: "C", X: 2, Y: 0})
+
+	if err := m.SetChitPalette(colorutil.PALETTE_OKABE_ITO); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	name, palette := m.ChitPalette()
+	if name != colorutil.PALETTE_OKABE_ITO {
+		t.Errorf("name: got %s, want %s", name, colorutil.PALETTE_OKABE_ITO)
+	}
+
+	inPalette := func(c color.RGBA) bool {
+		for _, p := range palette {
+			if c == p {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	a, _ := m.FindChit("A")
+	b, _ := m.FindChit("B")
+	c, _ := m.FindChit("C")
+
+	if !inPalette(a.Color) || !inPalette(c.Color) || a.Color == c.Color {
+		t.Errorf("chits are not recolored: A = %v, C = %v", a.Color, c.Color)
+	}
+
+	if b.Color != custom {
+		t.Errorf("custom color: got %v, want %v", b.Color, custom)
+	}
+
+	if err := m.SetChitPalette("rainbow"); err == nil {
+		t.Fatal("expected err")
+	}
+}
+
+func TestSquareMap_SetChitPalette_KeepsExplicitPaletteColor(t *testing.T) {
+	// 既定のパレットに含まれる色でも、ユーザーが指定した色は割り当て直さない
+	dodgerblue := colorutil.CSS3NameToRGBA("dodgerblue")
+
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 0, Y: 0, Color: dodgerblue})
+	m.AddChit(&Chit{Name: "B", X: 1, Y: 0})
+	m.AddChit(&Chit{Name: "C", X: 2, Y: 0})
+	m.SetChitColor("C", dodgerblue)
+
+	b, _ := m.FindChit("B")
+	if !b.AutoColor {
+		t.Error("B: AutoColor is false")
+	}
+
+	if err := m.SetChitPalette(colorutil.PALETTE_OKABE_ITO); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	for _, name := range []string{"A", "C"} {
+		c, _ := m.FindChit(name)
+		if c.Color != dodgerblue {
+			t.Errorf("%s: got: %v, want: %v", name, c.Color, dodgerblue)
+		}
+
+		if c.AutoColor {
+			t.Errorf("%s: AutoColor is true", name)
+		}
+	}
+
+	_, palette := m.ChitPalette()
+	b, _ = m.FindChit("B")
+	for _, c := range palette {
+		if b.Color == c {
+			return
+		}
+	}
+
+	t.Errorf("B is not recolored: %v", b.Color)
+}
+
+func TestSquareMap_SetChitShape(t *testing.T) {
+	m, _ := NewSquareMap(10, 10)
+	m.AddChit(&Chit{Name: "A", X: 1, Y: 2})
+
+	if _, err := m.SetChitShape("A", SHAPE_TRIANGLE); err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	c, _ := m.FindChit("A")
+	if c.Shape != SHAPE_TRIANGLE {
+		t.Errorf("got: %s, want: %s", c.Shape, SHAPE_TRIANGLE)
+	}
+
+	if _, err := m.SetChitShape("A", "star"); err == nil {
+		t.Error("expected err for unknown shape")
+	}
+
+	if _, err := m.SetChitShape("B", SHAPE_SQUARE); err == nil {
+		t.Error("expected err for unknown chit")
+	}
+
+	if err := m.AddChit(&Chit{Name: "B", X: 0, Y: 0, Shape: "star"}); err == nil {
+		t.Error("expected err when adding chit with unknown shape")
+	}
+}
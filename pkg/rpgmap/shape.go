@@ -0,0 +1,58 @@
+package rpgmap
+
+import (
+	"fmt"
+)
+
+// チットの形。
+const (
+	// SHAPE_CIRCLE は円を表す。
+	SHAPE_CIRCLE = "circle"
+	// SHAPE_SQUARE は正方形を表す。
+	SHAPE_SQUARE = "square"
+	// SHAPE_TRIANGLE は上向きの三角形を表す。
+	SHAPE_TRIANGLE = "triangle"
+	// SHAPE_DIAMOND はひし形を表す。
+	SHAPE_DIAMOND = "diamond"
+)
+
+// ChitShapes はチットの形の一覧。
+var ChitShapes = []string{
+	SHAPE_CIRCLE,
+	SHAPE_SQUARE,
+	SHAPE_TRIANGLE,
+	SHAPE_DIAMOND,
+}
+
+// IsValidChitShape は、チットの形として有効かどうかを返す。
+func IsValidChitShape(shape string) bool {
+	for _, s := range ChitShapes {
+		if shape == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetChitShape はチットの形を設定する。
+//
+// 設定後のチットの複製を返す。
+func (m *SquareMap) SetChitShape(name string, shape string) (*Chit, error) {
+	if !IsValidChitShape(shape) {
+		return nil, fmt.Errorf("unknown shape: %s", shape)
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	c, err := m.findChit(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Shape = shape
+
+	copied := *c
+	return &copied, nil
+}
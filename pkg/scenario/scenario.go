@@ -0,0 +1,532 @@
+// scenario はマップの状態をファイルに保存・読み込みする機能を提供するパッケージ。
+//
+// ファイルは人が編集しやすいTOML形式で、座標はコマンドと同じく1始まりで表す。
+package scenario
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+// Scenario はファイルに保存するマップの状態の構造体。
+type Scenario struct {
+	// Width はマップの幅。
+	Width int `toml:"width"`
+	// Height はマップの高さ。
+	Height int `toml:"height"`
+	// Chits はチットの配列。
+	Chits []Chit `toml:"chits"`
+	// Palette はチットの色のパレットの名前。
+	Palette string `toml:"palette,omitempty"`
+	// GroupColors はグループの名前 -> グループの色（#rrggbb 形式）の対応。
+	GroupColors map[string]string `toml:"groupColors,omitempty"`
+	// Background は背景。設定されていなければ nil。
+	Background *Background `toml:"background"`
+	// Round は現在のラウンド。省略した場合は1とする。
+	Round int `toml:"round,omitzero"`
+	// Moves はチットの移動の記録（古い順）。
+	Moves []Move `toml:"moves,omitempty"`
+}
+
+// Chit はファイルに保存するチットの状態の構造体。
+//
+// チットの画像は、背景画像と同様に ImagePath または ImageData に格納する。
+type Chit struct {
+	// Name はチットの名前。
+	Name string `toml:"name"`
+	// X はチットのx座標（1始まり）。
+	X int `toml:"x"`
+	// Y はチットのy座標（1始まり）。
+	Y int `toml:"y"`
+	// Color はチットの色。
+	//
+	// #rrggbb 形式の他、コマンドと同じくCSS3の色名などを使える。
+	// 空の場合は、マップ上のチットで使われていない色を割り当てる。
+	Color string `toml:"color"`
+	// AutoColor は、色がパレットから自動で割り当てられたものかどうか。
+	//
+	// 自動で割り当てられた色は、パレットを変更すると割り当て直される。
+	AutoColor bool `toml:"autoColor,omitempty"`
+	// Group はチットのグループの名前。
+	Group string `toml:"group,omitempty"`
+	// Shape はチットの形。
+	Shape string `toml:"shape,omitempty"`
+	// Initiative はチットのイニシアチブ。
+	Initiative int `toml:"initiative,omitzero"`
+	// ImagePath はチットの画像のファイル名。相対パスはシナリオファイルのディレクトリを基準とする。
+	ImagePath string `toml:"imagePath,omitempty"`
+	// ImageData はBase64で符号化したPNG形式のチットの画像。
+	ImageData string `toml:"imageData,omitempty"`
+	// Moved は、チットが移動したことがあるかどうか。
+	Moved bool `toml:"moved,omitempty"`
+	// PrevX は直前の移動の前のチットのx座標（1始まり）。
+	PrevX int `toml:"prevX,omitzero"`
+	// PrevY は直前の移動の前のチットのy座標（1始まり）。
+	PrevY int `toml:"prevY,omitzero"`
+}
+
+// Move はファイルに保存するチットの移動の記録の構造体。
+type Move struct {
+	// Name は移動したチットの名前。
+	Name string `toml:"name"`
+	// FromX は移動前のx座標（1始まり）。
+	FromX int `toml:"fromX"`
+	// FromY は移動前のy座標（1始まり）。
+	FromY int `toml:"fromY"`
+	// ToX は移動後のx座標（1始まり）。
+	ToX int `toml:"toX"`
+	// ToY は移動後のy座標（1始まり）。
+	ToY int `toml:"toY"`
+	// Round は移動したラウンド。
+	Round int `toml:"round"`
+}
+
+// Background はファイルに保存する背景の状態の構造体。
+//
+// 背景画像は、ファイルから読み込んだものであれば Path に、
+// それ以外（URLから取得したものなど）であれば Data にPNG形式で格納する。
+type Background struct {
+	// Path は背景画像のファイル名。相対パスはシナリオファイルのディレクトリを基準とする。
+	Path string `toml:"path,omitempty"`
+	// Data はBase64で符号化したPNG形式の背景画像。
+	Data string `toml:"data,omitempty"`
+	// Fit は、背景画像をマップ全体に合わせて拡大縮小するかどうか。
+	Fit bool `toml:"fit"`
+	// CellSize は背景画像上での1マスの大きさ（ピクセル）。
+	CellSize int `toml:"cellSize"`
+	// OffsetX は背景画像上でのグリッドの左端のx座標（ピクセル）。
+	OffsetX int `toml:"offsetX"`
+	// OffsetY は背景画像上でのグリッドの上端のy座標（ピクセル）。
+	OffsetY int `toml:"offsetY"`
+	// Opacity は背景画像の不透明度（0.0〜1.0）。
+	Opacity float64 `toml:"opacity"`
+	// ShiftX は、マップの移動に合わせて背景画像を右に移動したマス数。
+	ShiftX int `toml:"shiftX,omitzero"`
+	// ShiftY は、マップの移動に合わせて背景画像を下に移動したマス数。
+	ShiftY int `toml:"shiftY,omitzero"`
+}
+
+// FromSquareMap はスクエアマップの状態を返す。
+func FromSquareMap(m *rpgmap.SquareMap) (*Scenario, error) {
+	return fromSquareMap(m, "")
+}
+
+// fromSquareMap はスクエアマップの状態を返す。
+//
+// baseDir が空でなければ、画像のファイル名をそこからの相対パスにする。
+func fromSquareMap(m *rpgmap.SquareMap, baseDir string) (*Scenario, error) {
+	s := &Scenario{
+		Width:  m.Width(),
+		Height: m.Height(),
+		Chits:  []Chit{},
+		Round:  m.Round(),
+	}
+
+	if name, _ := m.ChitPalette(); name != colorutil.PALETTE_DEFAULT {
+		s.Palette = name
+	}
+
+	var chitErr error
+	m.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		sc := Chit{
+			Name:       c.Name,
+			X:          c.X + 1,
+			Y:          c.Y + 1,
+			Color:      colorutil.RGBAToHex(c.Color),
+			AutoColor:  c.AutoColor,
+			Group:      c.Group,
+			Shape:      c.Shape,
+			Initiative: c.Initiative,
+		}
+
+		if c.Moved {
+			sc.Moved = true
+			sc.PrevX = c.PrevX + 1
+			sc.PrevY = c.PrevY + 1
+		}
+
+		if c.Image != nil && chitErr == nil {
+			sc.ImagePath, sc.ImageData, chitErr = encodeImage(c.Image, c.ImageSource, baseDir)
+		}
+
+		s.Chits = append(s.Chits, sc)
+	})
+
+	if chitErr != nil {
+		return nil, chitErr
+	}
+
+	for _, g := range m.Groups() {
+		if c, found := m.GroupColor(g); found {
+			if s.GroupColors == nil {
+				s.GroupColors = map[string]string{}
+			}
+
+			s.GroupColors[g] = colorutil.RGBAToHex(c)
+		}
+	}
+
+	for _, mv := range m.LastMoves(rpgmap.MAX_MOVE_HISTORY) {
+		s.Moves = append(s.Moves, Move{
+			Name:  mv.Name,
+			FromX: mv.FromX + 1,
+			FromY: mv.FromY + 1,
+			ToX:   mv.ToX + 1,
+			ToY:   mv.ToY + 1,
+			Round: mv.Round,
+		})
+	}
+
+	if bg, found := m.Background(); found {
+		sBg, err := fromBackground(bg, baseDir)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Background = sBg
+	}
+
+	return s, nil
+}
+
+// fromBackground は背景の状態を返す。
+func fromBackground(bg *rpgmap.Background, baseDir string) (*Background, error) {
+	sBg := &Background{
+		Fit:      bg.Fit,
+		CellSize: bg.CellSize,
+		OffsetX:  bg.OffsetX,
+		OffsetY:  bg.OffsetY,
+		Opacity:  bg.Opacity,
+		ShiftX:   bg.ShiftX,
+		ShiftY:   bg.ShiftY,
+	}
+
+	var err error
+	sBg.Path, sBg.Data, err = encodeImage(bg.Image, bg.Source, baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return sBg, nil
+}
+
+// encodeImage は、画像をファイル名またはBase64で符号化したPNG形式のデータにして返す。
+//
+// 取得元がファイルであればそのファイル名を、そうでなければデータを返す。
+func encodeImage(img image.Image, source string, baseDir string) (path string, data string, err error) {
+	if source != "" && !strings.Contains(source, "://") {
+		return relativePath(source, baseDir), "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", "", err
+	}
+
+	return "", base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decodeImage は、ファイル名またはBase64で符号化されたデータから画像を読み込む。
+//
+// ファイルから読み込んだ場合は、そのファイル名を取得元として返す。
+func decodeImage(path string, data string, baseDir string) (image.Image, string, error) {
+	var r io.Reader
+	source := ""
+
+	switch {
+	case path != "":
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+
+		r = f
+		source = path
+	case data != "":
+		r = base64.NewDecoder(base64.StdEncoding, strings.NewReader(data))
+	default:
+		return nil, "", fmt.Errorf("neither path nor data is set")
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return img, source, nil
+}
+
+// relativePath は、可能であれば path を baseDir からの相対パスにして返す。
+func relativePath(path string, baseDir string) string {
+	if baseDir == "" {
+		return path
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	absBaseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(absBaseDir, absPath)
+	if err != nil {
+		return absPath
+	}
+
+	return rel
+}
+
+// SquareMap は状態からスクエアマップを構築する。
+//
+// 画像のファイル名が相対パスの場合は、baseDir を基準とする。
+func (s *Scenario) SquareMap(baseDir string) (*rpgmap.SquareMap, error) {
+	m, err := rpgmap.NewSquareMap(s.Width, s.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Palette != "" {
+		if err := m.SetChitPalette(s.Palette); err != nil {
+			return nil, err
+		}
+	}
+
+	_, palette := m.ChitPalette()
+
+	for _, c := range s.Chits {
+		chit := &rpgmap.Chit{
+			Name:       c.Name,
+			X:          c.X - 1,
+			Y:          c.Y - 1,
+			Group:      c.Group,
+			Shape:      c.Shape,
+			Initiative: c.Initiative,
+			AutoColor:  c.AutoColor,
+		}
+
+		if c.Moved {
+			chit.Moved = true
+			chit.PrevX = c.PrevX - 1
+			chit.PrevY = c.PrevY - 1
+		}
+
+		// 色が空の場合は、AddChit で割り当てる
+		var err error
+		if c.Color != "" {
+			chit.Color, err = colorutil.ParseColorWithPalette(c.Color, palette)
+			if err != nil {
+				return nil, fmt.Errorf("chit %q: %s", c.Name, err)
+			}
+		}
+
+		if c.ImagePath != "" || c.ImageData != "" {
+			chit.Image, chit.ImageSource, err = decodeImage(c.ImagePath, c.ImageData, baseDir)
+			if err != nil {
+				return nil, fmt.Errorf("chit %q: %s", c.Name, err)
+			}
+		}
+
+		err = m.AddChit(chit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for g, hex := range s.GroupColors {
+		c, err := colorutil.ParseColor(hex)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %s", g, err)
+		}
+
+		// チットがいないグループの色は無視する
+		m.SetGroupColor(g, c)
+	}
+
+	if err := m.SetMoveHistory(s.round(), s.moves()); err != nil {
+		return nil, err
+	}
+
+	if s.Background != nil {
+		bg, err := s.Background.background(baseDir)
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.SetBackground(bg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// round は現在のラウンドを返す。省略された場合は1を返す。
+func (s *Scenario) round() int {
+	if s.Round == 0 {
+		return 1
+	}
+
+	return s.Round
+}
+
+// moves はチットの移動の記録を返す。座標は0始まりに直す。
+func (s *Scenario) moves() []rpgmap.Move {
+	moves := make([]rpgmap.Move, 0, len(s.Moves))
+	for _, mv := range s.Moves {
+		moves = append(moves, rpgmap.Move{
+			Name:  mv.Name,
+			FromX: mv.FromX - 1,
+			FromY: mv.FromY - 1,
+			ToX:   mv.ToX - 1,
+			ToY:   mv.ToY - 1,
+			Round: mv.Round,
+		})
+	}
+
+	return moves
+}
+
+// background は状態から背景を構築する。
+func (sBg *Background) background(baseDir string) (*rpgmap.Background, error) {
+	bg := &rpgmap.Background{
+		Fit:      sBg.Fit,
+		CellSize: sBg.CellSize,
+		OffsetX:  sBg.OffsetX,
+		OffsetY:  sBg.OffsetY,
+		Opacity:  sBg.Opacity,
+		ShiftX:   sBg.ShiftX,
+		ShiftY:   sBg.ShiftY,
+	}
+
+	img, source, err := decodeImage(sBg.Path, sBg.Data, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("background: %s", err)
+	}
+
+	bg.Image = img
+	bg.Source = source
+
+	return bg, nil
+}
+
+// Encode はスクエアマップの状態をTOML形式でwに書き込む。
+func Encode(w io.Writer, m *rpgmap.SquareMap) error {
+	return encode(w, m, "")
+}
+
+// encode はスクエアマップの状態をTOML形式でwに書き込む。
+func encode(w io.Writer, m *rpgmap.SquareMap, baseDir string) error {
+	s, err := fromSquareMap(m, baseDir)
+	if err != nil {
+		return err
+	}
+
+	return toml.NewEncoder(w).Encode(s)
+}
+
+// Decode はrからTOML形式の状態を読み込み、スクエアマップを構築する。
+//
+// 画像のファイル名が相対パスの場合は、作業ディレクトリを基準とする。
+func Decode(r io.Reader) (*rpgmap.SquareMap, error) {
+	return decode(r, "")
+}
+
+// decode はrからTOML形式の状態を読み込み、スクエアマップを構築する。
+func decode(r io.Reader, baseDir string) (*rpgmap.SquareMap, error) {
+	s := Scenario{}
+
+	md, err := toml.DecodeReader(r, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	// 不透明度が省略された場合は不透明とする
+	if s.Background != nil && !md.IsDefined("background", "opacity") {
+		s.Background.Opacity = 1.0
+	}
+
+	return s.SquareMap(baseDir)
+}
+
+// SaveFile はスクエアマップの状態をファイルに保存する。
+//
+// 同じディレクトリの一時ファイルに書き込んでから置き換えるため、
+// 保存に失敗しても既存のファイルは壊れない。
+func SaveFile(filename string, m *rpgmap.SquareMap) error {
+	dir := filepath.Dir(filename)
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+
+	err = encode(f, m, dir)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	// 一時ファイルは所有者しか読み書きできないため、既存のファイルか通常のファイルと同じ権限にする
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	err = f.Chmod(mode)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	err = os.Rename(tmpName, filename)
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return nil
+}
+
+// LoadFile はファイルから状態を読み込み、スクエアマップを構築する。
+func LoadFile(filename string) (*rpgmap.SquareMap, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decode(f, filepath.Dir(filename))
+}
@@ -0,0 +1,515 @@
+package scenario
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ochaochaocha3/mapbot/pkg/colorutil"
+	"github.com/ochaochaocha3/mapbot/pkg/rpgmap"
+)
+
+func TestEncodeDecode_Palette(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	m.SetChitPalette(colorutil.PALETTE_TOL)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	actual, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if name, _ := actual.ChitPalette(); name != colorutil.PALETTE_TOL {
+		t.Errorf("palette: got %s, want %s", name, colorutil.PALETTE_TOL)
+	}
+
+	var expectedChits, actualChits []rpgmap.Chit
+	m.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		expectedChits = append(expectedChits, *c)
+	})
+	actual.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		actualChits = append(actualChits, *c)
+	})
+
+	if !reflect.DeepEqual(actualChits, expectedChits) {
+		t.Errorf("chits: got %v, want %v", actualChits, expectedChits)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	actual, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if actual.SizeStr() != m.SizeStr() {
+		t.Errorf("size: got %s, want %s", actual.SizeStr(), m.SizeStr())
+	}
+
+	var expectedChits, actualChits []rpgmap.Chit
+	m.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		expectedChits = append(expectedChits, *c)
+	})
+	actual.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		actualChits = append(actualChits, *c)
+	})
+
+	if !reflect.DeepEqual(actualChits, expectedChits) {
+		t.Errorf("chits: got %v, want %v", actualChits, expectedChits)
+	}
+}
+
+func TestSaveFileLoadFile(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	filename := filepath.Join(t.TempDir(), "map.toml")
+
+	if err := SaveFile(filename, m); err != nil {
+		t.Fatalf("SaveFile: %s", err)
+	}
+
+	actual, err := LoadFile(filename)
+	if err != nil {
+		t.Fatalf("LoadFile: %s", err)
+	}
+
+	var expectedChits, actualChits []rpgmap.Chit
+	m.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		expectedChits = append(expectedChits, *c)
+	})
+	actual.ForEachChit(func(_ int, c *rpgmap.Chit) {
+		actualChits = append(actualChits, *c)
+	})
+
+	if !reflect.DeepEqual(actualChits, expectedChits) {
+		t.Errorf("chits: got %v, want %v", actualChits, expectedChits)
+	}
+}
+
+func TestDecode_HandWritten(t *testing.T) {
+	input := `
+width = 10
+height = 10
+
+[[chits]]
+name = "A"
+x = 1
+y = 2
+color = "#1E90FF"
+`
+
+	m, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	c, found := m.FindChit("A")
+	if !found {
+		t.Fatal("chit not found")
+	}
+
+	expected := rpgmap.Chit{Name: "A", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")}
+	if *c != expected {
+		t.Fatalf("got %v, want %v", *c, expected)
+	}
+}
+
+func TestDecode_HandWrittenColors(t *testing.T) {
+	input := `
+width = 10
+height = 10
+
+[[chits]]
+name = "A"
+x = 1
+y = 1
+color = "dodgerblue"
+
+[[chits]]
+name = "B"
+x = 2
+y = 1
+`
+
+	m, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	a, _ := m.FindChit("A")
+	if expected := colorutil.CSS3NameToRGBA("dodgerblue"); a.Color != expected {
+		t.Errorf("A: got %v, want %v", a.Color, expected)
+	}
+
+	// 色が省略されたチットには色を割り当てる
+	b, _ := m.FindChit("B")
+	if b.Color == (color.RGBA{}) {
+		t.Error("B: color is not allocated")
+	}
+}
+
+func TestEncodeDecode_GroupColorsAndInitiative(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0, Group: "PC"})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 1, Y: 0, Group: "Enemies", Initiative: 12})
+
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	if err := m.SetGroupColor("PC", red); err != nil {
+		t.Fatalf("SetGroupColor: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	// イニシアチブが0のチットには書き込まない
+	if n := strings.Count(buf.String(), "initiative"); n != 1 {
+		t.Errorf("initiative is written %d times: %s", n, buf.String())
+	}
+
+	actual, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	for _, g := range []string{"PC", "Enemies"} {
+		expected, _ := m.GroupColor(g)
+		if c, _ := actual.GroupColor(g); c != expected {
+			t.Errorf("%s: got %v, want %v", g, c, expected)
+		}
+	}
+}
+
+func TestEncodeDecode_AutoColor(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 2", X: 10, Y: 7})
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	if n := strings.Count(buf.String(), "autoColor"); n != 1 {
+		t.Errorf("autoColor is written %d times: %s", n, buf.String())
+	}
+
+	actual, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	// 読み込んだ後も、自動で割り当てた色だけがパレットの変更で割り当て直される
+	actual.SetChitPalette(colorutil.PALETTE_OKABE_ITO)
+
+	hero, _ := actual.FindChit("ゆうしゃ")
+	if expected := colorutil.CSS3NameToRGBA("dodgerblue"); hero.Color != expected {
+		t.Errorf("ゆうしゃ: got %v, want %v", hero.Color, expected)
+	}
+
+	before, _ := m.FindChit("Goblin 2")
+	after, _ := actual.FindChit("Goblin 2")
+	if !after.AutoColor || after.Color == before.Color {
+		t.Errorf("Goblin 2 is not recolored: %v", after.Color)
+	}
+}
+
+func TestEncodeDecode_Moves(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(10, 10)
+	m.AddChit(&rpgmap.Chit{Name: "A", X: 0, Y: 0})
+	m.AddChit(&rpgmap.Chit{Name: "B", X: 5, Y: 5})
+	m.MoveChit("A", 1, 2)
+	m.NextRound()
+	m.MoveChit("B", 6, 5)
+	m.MoveChit("A", 3, 3)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	actual, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if r := actual.Round(); r != 2 {
+		t.Errorf("Round: got %d, want %d", r, 2)
+	}
+
+	expectedMoves := m.LastMoves(rpgmap.MAX_MOVE_HISTORY)
+	if moves := actual.LastMoves(rpgmap.MAX_MOVE_HISTORY); !reflect.DeepEqual(moves, expectedMoves) {
+		t.Errorf("moves: got %+v, want %+v", moves, expectedMoves)
+	}
+
+	// 直前の移動の前の位置も読み込む
+	for _, name := range []string{"A", "B"} {
+		expected, _ := m.FindChit(name)
+		c, _ := actual.FindChit(name)
+		if *c != *expected {
+			t.Errorf("%s: got %+v, want %+v", name, *c, *expected)
+		}
+	}
+}
+
+func TestDecode_RoundDefaultsToOne(t *testing.T) {
+	m, err := Decode(strings.NewReader("width = 10\nheight = 10\n"))
+	if err != nil {
+		t.Fatalf("got err: %s", err)
+	}
+
+	if r := m.Round(); r != 1 {
+		t.Errorf("Round: got %d, want %d", r, 1)
+	}
+}
+
+func TestSaveFile_KeepsFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "scenario.toml")
+
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	if err := SaveFile(filename, m); err != nil {
+		t.Fatalf("SaveFile: %s", err)
+	}
+
+	// 大きさが0の画像はPNG形式に符号化できない
+	broken, _ := rpgmap.NewSquareMap(10, 10)
+	broken.AddChit(&rpgmap.Chit{
+		Name:        "A",
+		Image:       image.NewRGBA(image.Rect(0, 0, 0, 0)),
+		ImageSource: "https://example.com/a.png",
+	})
+
+	if err := SaveFile(filename, broken); err == nil {
+		t.Fatal("expected err")
+	}
+
+	if _, err := LoadFile(filename); err != nil {
+		t.Errorf("LoadFile: %s", err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("temporary file is left: %d files", len(files))
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	testcases := []struct {
+		Name  string
+		Input string
+	}{
+		{Name: "invalid size", Input: "width = 1\nheight = 10\n"},
+		{Name: "chit out of range", Input: "width = 10\nheight = 10\n[[chits]]\nname = \"A\"\nx = 11\ny = 1\ncolor = \"#000000\"\n"},
+		{Name: "invalid color", Input: "width = 10\nheight = 10\n[[chits]]\nname = \"A\"\nx = 1\ny = 1\ncolor = \"nocolor\"\n"},
+		{Name: "invalid TOML", Input: "width = "},
+		{Name: "move out of range", Input: "width = 10\nheight = 10\n[[moves]]\nname = \"A\"\nfromX = 1\nfromY = 1\ntoX = 11\ntoY = 1\nround = 1\n"},
+		{Name: "move in later round", Input: "width = 10\nheight = 10\nround = 1\n[[moves]]\nname = \"A\"\nfromX = 1\nfromY = 1\ntoX = 2\ntoY = 1\nround = 2\n"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(test.Input))
+			if err == nil {
+				t.Fatal("expected err")
+			}
+		})
+	}
+}
+
+// writeTestPNG は、dirに4x4の赤い画像をPNG形式で書き込み、ファイル名を返す。
+func writeTestPNG(t *testing.T, dir string, name string) string {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0xFF, 0, 0, 0xFF}), image.ZP, draw.Src)
+
+	filename := filepath.Join(dir, name)
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+func TestSaveFileLoadFile_BackgroundPath(t *testing.T) {
+	dir := t.TempDir()
+	imgFilename := writeTestPNG(t, dir, "bg.png")
+
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	img, _ := os.Open(imgFilename)
+	decoded, _, _ := image.Decode(img)
+	img.Close()
+
+	bg := rpgmap.NewBackground(decoded, imgFilename)
+	bg.Fit = true
+	bg.OffsetX = 2
+	bg.Opacity = 0.5
+	m.SetBackground(bg)
+
+	filename := filepath.Join(dir, "map.toml")
+	if err := SaveFile(filename, m); err != nil {
+		t.Fatalf("SaveFile: %s", err)
+	}
+
+	content, _ := ioutil.ReadFile(filename)
+	if !strings.Contains(string(content), `path = "bg.png"`) {
+		t.Errorf("path is not relative: %s", content)
+	}
+
+	// ずらしていない場合は書き込まない
+	if strings.Contains(string(content), "shift") {
+		t.Errorf("zero shift is written: %s", content)
+	}
+
+	actual, err := LoadFile(filename)
+	if err != nil {
+		t.Fatalf("LoadFile: %s", err)
+	}
+
+	actualBg, found := actual.Background()
+	if !found {
+		t.Fatal("background is not loaded")
+	}
+
+	if !actualBg.Fit || actualBg.OffsetX != 2 || actualBg.Opacity != 0.5 {
+		t.Errorf("options: got %+v", actualBg)
+	}
+
+	if actualBg.Image.Bounds().Dx() != 4 {
+		t.Errorf("image width: got %d, want %d", actualBg.Image.Bounds().Dx(), 4)
+	}
+}
+
+func TestEncodeDecode_BackgroundData(t *testing.T) {
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	bg := rpgmap.NewBackground(img, "https://example.com/bg.png")
+	bg.ShiftX = 2
+	bg.ShiftY = -1
+	m.SetBackground(bg)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	if !strings.Contains(buf.String(), "data = ") {
+		t.Fatalf("image data is not embedded: %s", buf.String())
+	}
+
+	actual, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	actualBg, found := actual.Background()
+	if !found {
+		t.Fatal("background is not loaded")
+	}
+
+	if actualBg.Image.Bounds().Size() != image.Pt(3, 2) {
+		t.Errorf("image size: got %v", actualBg.Image.Bounds().Size())
+	}
+
+	if actualBg.ShiftX != 2 || actualBg.ShiftY != -1 {
+		t.Errorf("shift: got (%d, %d), want (2, -1)", actualBg.ShiftX, actualBg.ShiftY)
+	}
+}
+
+func TestLoadFile_BackgroundOpacityDefaultsToOpaque(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPNG(t, dir, "bg.png")
+
+	filename := filepath.Join(dir, "map.toml")
+	content := "width = 10\nheight = 10\n[background]\npath = \"bg.png\"\n"
+	ioutil.WriteFile(filename, []byte(content), 0644)
+
+	m, err := LoadFile(filename)
+	if err != nil {
+		t.Fatalf("LoadFile: %s", err)
+	}
+
+	bg, _ := m.Background()
+	if bg.Opacity != 1.0 {
+		t.Fatalf("Opacity: got %g, want %g", bg.Opacity, 1.0)
+	}
+}
+
+func TestSaveFileLoadFile_ChitImage(t *testing.T) {
+	dir := t.TempDir()
+	imgFilename := writeTestPNG(t, dir, "avatar.png")
+
+	m, _ := rpgmap.NewSquareMap(12, 8)
+	m.AddChit(&rpgmap.Chit{Name: "ゆうしゃ", X: 0, Y: 1, Color: colorutil.CSS3NameToRGBA("dodgerblue")})
+	m.AddChit(&rpgmap.Chit{Name: "Goblin 1", X: 11, Y: 7, Color: colorutil.CSS3NameToRGBA("red"), Group: "敵", Shape: rpgmap.SHAPE_TRIANGLE, Initiative: 12})
+	m.SetChitImage("ゆうしゃ", image.NewRGBA(image.Rect(0, 0, 4, 4)), imgFilename)
+	m.SetChitImage("Goblin 1", image.NewRGBA(image.Rect(0, 0, 3, 2)), "https://example.com/goblin.png")
+
+	filename := filepath.Join(dir, "map.toml")
+	if err := SaveFile(filename, m); err != nil {
+		t.Fatalf("SaveFile: %s", err)
+	}
+
+	content, _ := ioutil.ReadFile(filename)
+	if !strings.Contains(string(content), `imagePath = "avatar.png"`) {
+		t.Errorf("imagePath is not relative: %s", content)
+	}
+
+	if !strings.Contains(string(content), "imageData = ") {
+		t.Errorf("image data is not embedded: %s", content)
+	}
+
+	actual, err := LoadFile(filename)
+	if err != nil {
+		t.Fatalf("LoadFile: %s", err)
+	}
+
+	hero, _ := actual.FindChit("ゆうしゃ")
+	if hero.Image == nil || hero.ImageSource != imgFilename {
+		t.Errorf("ゆうしゃ: got Image=%v, ImageSource=%q", hero.Image, hero.ImageSource)
+	}
+
+	goblin, _ := actual.FindChit("Goblin 1")
+	if goblin.Image == nil || goblin.Image.Bounds().Size() != image.Pt(3, 2) {
+		t.Errorf("Goblin 1: got Image=%v", goblin.Image)
+	}
+}